@@ -5,21 +5,34 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"sync"
 
 	"github.com/alitto/pond/v2"
 	"github.com/doodlescheduling/flux-build/internal/build"
+	memcache "github.com/doodlescheduling/flux-build/internal/cache"
+	"github.com/doodlescheduling/flux-build/internal/changes"
+	"github.com/doodlescheduling/flux-build/internal/charts"
+	"github.com/doodlescheduling/flux-build/internal/conflict"
+	"github.com/doodlescheduling/flux-build/internal/deprecation"
+	"github.com/doodlescheduling/flux-build/internal/filter"
 	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
+	"github.com/doodlescheduling/flux-build/internal/images"
+	"github.com/doodlescheduling/flux-build/internal/policy"
+	"github.com/doodlescheduling/flux-build/internal/redact"
+	"github.com/doodlescheduling/flux-build/internal/rendercache"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"github.com/doodlescheduling/flux-build/internal/validate"
+	"github.com/doodlescheduling/flux-build/internal/values"
 	helmv2 "github.com/fluxcd/helm-controller/api/v2"
 	"github.com/go-logr/logr"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 )
 
 type Action struct {
 	Output           io.Writer
-	AllowFailure     bool
 	FailFast         bool
 	Workers          int
 	Cache            chartcache.Interface
@@ -28,6 +41,147 @@ type Action struct {
 	IncludeHelmHooks bool
 	KubeVersion      *chartutil.KubeVersion
 	Logger           logr.Logger
+	Redactor         *redact.Redactor
+	Filter           *filter.Filter
+	ConflictMode     conflict.Mode
+	AllowConflicts   conflict.Allowlist
+	Validate         bool
+	Policies         bool
+	PolicyDir        string
+	// CheckDeprecations reports objects using deprecated or removed APIs at KubeVersion or UpgradeKubeVersion.
+	CheckDeprecations  bool
+	UpgradeKubeVersion *chartutil.KubeVersion
+	// Images collects the container images of all output workloads if set.
+	Images *images.Inventory
+	// ImagePolicy reports images which are not allowed if set.
+	ImagePolicy *images.Policy
+	// Charts collects the charts of all rendered HelmReleases if set.
+	Charts *charts.Inventory
+	// UnknownValues defines how values which are not declared by the chart are reported.
+	UnknownValues       values.Mode
+	IgnoreUnknownValues values.Ignore
+	// Variables are substituted in HelmReleases in addition to the environment variables.
+	Variables map[string]string
+	// IgnoreEnv disables the substitution of environment variables, only Variables are substituted.
+	IgnoreEnv bool
+	// Repositories caches chart repositories, it can be shared by multiple actions.
+	Repositories *memcache.Cache[build.CacheKey]
+	// RenderCache caches the rendered output of HelmReleases across runs if set.
+	RenderCache *rendercache.Cache
+	// Changes restricts rendering to the HelmReleases depending on one of the changed files if set.
+	// All paths are still built to resolve the chart sources and values of these HelmReleases.
+	Changes *changes.Set
+	// Kustomize are the options of the kustomize builds of the paths.
+	Kustomize build.KustomizeOpts
+	// KustomizeHelm inflates the helmCharts of kustomizations with the chart cache and renderer of HelmReleases.
+	KustomizeHelm bool
+}
+
+// newHelmBuilder creates the builder rendering the HelmReleases with the options of the action.
+func (a *Action) newHelmBuilder() *build.Helm {
+	return build.NewHelmBuilder(a.Logger, build.HelmOpts{
+		APIVersions:      a.APIVersions,
+		KubeVersion:      a.KubeVersion,
+		IncludeHelmHooks: a.IncludeHelmHooks,
+		Cache:            a.Cache,
+		CheckValues:      a.UnknownValues == values.ModeWarn || a.UnknownValues == values.ModeFail,
+		Variables:        a.Variables,
+		IgnoreEnv:        a.IgnoreEnv,
+		Repositories:     a.Repositories,
+		RenderCache:      a.RenderCache,
+	})
+}
+
+// kustomizeOpts returns the options of the kustomize builds, helmCharts are inflated by helm if enabled.
+func (a *Action) kustomizeOpts(helm *build.Helm) build.KustomizeOpts {
+	opts := a.Kustomize
+	if a.KustomizeHelm {
+		opts.Helm = helm
+	}
+
+	return opts
+}
+
+// targetObject is an output object and the build target which produced it.
+type targetObject struct {
+	target report.Target
+	res    *resource.Resource
+}
+
+// targetManifests are the objects produced by a single build target.
+type targetManifests struct {
+	target report.Target
+	index  resmap.ResMap
+	// namespace is the namespace of namespaced objects without one, the release namespace of HelmReleases.
+	namespace string
+}
+
+// policyEvaluator creates a policy evaluator including the policies of PolicyDir if policies are enabled.
+func (a *Action) policyEvaluator(errs chan<- error) *policy.Evaluator {
+	if !a.Policies && a.PolicyDir == "" {
+		return nil
+	}
+
+	evaluator, err := policy.New()
+	if err != nil {
+		errs <- &report.Error{Phase: phasePolicy, Message: err.Error()}
+		return nil
+	}
+
+	if a.PolicyDir != "" {
+		if err := evaluator.LoadDir(a.PolicyDir); err != nil {
+			errs <- &report.Error{Phase: phasePolicy, Path: a.PolicyDir, Message: err.Error()}
+		}
+	}
+
+	return evaluator
+}
+
+// validator creates a validator for the APIs served by KubeVersion if validation is enabled.
+func (a *Action) validator(errs chan<- error) *validate.Validator {
+	if !a.Validate {
+		return nil
+	}
+
+	var kubeVersion string
+	if a.KubeVersion != nil {
+		kubeVersion = a.KubeVersion.Version
+	}
+
+	validator, err := validate.New(kubeVersion)
+	if err != nil {
+		errs <- &report.Error{Phase: phaseValidate, Message: err.Error()}
+		return nil
+	}
+
+	return validator
+}
+
+// deprecationChecker creates a checker for the built-in deprecated API list if deprecation checks are enabled.
+func (a *Action) deprecationChecker(errs chan<- error) *deprecation.Checker {
+	if !a.CheckDeprecations && a.UpgradeKubeVersion == nil {
+		return nil
+	}
+
+	checker, err := deprecation.NewChecker(deprecation.APIs)
+	if err != nil {
+		errs <- &report.Error{Phase: phaseDeprecation, Message: err.Error()}
+		return nil
+	}
+
+	return checker
+}
+
+// kubeVersions returns the Kubernetes versions objects are checked against for deprecated APIs.
+func (a *Action) kubeVersions() []string {
+	var versions []string
+	for _, v := range []*chartutil.KubeVersion{a.KubeVersion, a.UpgradeKubeVersion} {
+		if v != nil {
+			versions = append(versions, v.Version)
+		}
+	}
+
+	return versions
 }
 
 // submit forwards task panics (captured by pond) to errs, matching pre-pond-v2 PanicHandler behavior.
@@ -42,33 +196,38 @@ func submit(p pond.Pool, task func(), errs chan<- error, panicForward *sync.Wait
 	}()
 }
 
-func (a *Action) Run(ctx context.Context) error {
+// Run builds all paths and renders all HelmReleases found within them.
+// Failures do not abort the build unless FailFast is set, all of them are collected in the returned report.
+func (a *Action) Run(ctx context.Context) *report.Report {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	errs := make(chan error)
 	var panicForward sync.WaitGroup
 
-	var lastErr error
+	rep := report.New()
 	helmResultPool := pond.NewPool(1, pond.WithContext(ctx))
 	kustomizePool := pond.NewPool(len(a.Paths), pond.WithContext(ctx))
 	helmPool := pond.NewPool(a.Workers, pond.WithContext(ctx))
 	resourcePool := pond.NewPool(1, pond.WithContext(ctx))
 
-	defer func() {
-		if lastErr != nil && !a.AllowFailure {
-			fmt.Fprintln(os.Stderr, lastErr.Error())
-			os.Exit(1)
-		}
-	}()
-
+	collected := make(chan struct{})
 	go func() {
+		defer close(collected)
 		for err := range errs {
 			if err == nil {
 				continue
 			}
 
-			lastErr = err
+			var reportErr *report.Error
+			if !errors.As(err, &reportErr) {
+				reportErr = &report.Error{
+					Phase:   phaseInternal,
+					Message: err.Error(),
+				}
+			}
+
+			rep.Add(reportErr)
 
 			if a.FailFast {
 				cancel()
@@ -77,42 +236,128 @@ func (a *Action) Run(ctx context.Context) error {
 	}()
 
 	resources := make(chan resmap.ResMap, len(a.Paths))
-	manifests := make(chan resmap.ResMap, a.Workers)
-	helmBuilder := build.NewHelmBuilder(a.Logger, build.HelmOpts{
-		APIVersions:      a.APIVersions,
-		KubeVersion:      a.KubeVersion,
-		IncludeHelmHooks: a.IncludeHelmHooks,
-		Cache:            a.Cache,
-	})
+	manifests := make(chan targetManifests, a.Workers)
+	conflicts := conflict.NewDetector(a.AllowConflicts)
+	validator := a.validator(errs)
+	evaluator := a.policyEvaluator(errs)
+	deprecations := a.deprecationChecker(errs)
+	kubeVersions := a.kubeVersions()
+	var outputObjects []targetObject
+	helmBuilder := a.newHelmBuilder()
 
 	submit(helmResultPool, func() {
-		for index := range manifests {
+		for m := range manifests {
+			index := m.index
+			if a.ConflictMode != conflict.ModeIgnore {
+				if err := conflicts.Add(m.target.String(), m.namespace, index.Resources()); err != nil {
+					errs <- outputError(err)
+				}
+			}
+
+			if validator != nil {
+				for _, res := range index.Resources() {
+					if validate.IsCRD(res) {
+						if err := validator.AddCRD(res); err != nil {
+							errs <- targetError(m.target, phaseValidate, err.Error())
+						}
+					}
+				}
+			}
+
+			if evaluator != nil {
+				for _, res := range index.Resources() {
+					if err := evaluator.Add(res); err != nil {
+						errs <- targetError(m.target, phasePolicy, err.Error())
+					}
+				}
+			}
+
+			if a.Filter != nil {
+				filtered := resmap.New()
+				for _, res := range index.Resources() {
+					if a.Filter.MatchObject(res) {
+						if err := filtered.Append(res); err != nil {
+							errs <- outputError(err)
+						}
+					}
+				}
+
+				if filtered.Size() == 0 {
+					continue
+				}
+
+				index = filtered
+			}
+
+			if deprecations != nil {
+				for _, res := range index.Resources() {
+					if err := checkDeprecation(deprecations, kubeVersions, targetObject{target: m.target, res: res}); err != nil {
+						errs <- err
+					}
+				}
+			}
+
+			if a.Images != nil || a.ImagePolicy != nil {
+				for _, res := range index.Resources() {
+					for _, err := range checkImages(a.Images, a.ImagePolicy, targetObject{target: m.target, res: res}) {
+						errs <- err
+					}
+				}
+			}
+
+			if validator != nil || evaluator != nil {
+				for _, res := range index.Resources() {
+					outputObjects = append(outputObjects, targetObject{target: m.target, res: res})
+				}
+			}
+
+			if a.Redactor != nil {
+				// The objects are shared with the resource index, redact a copy only
+				index = index.DeepCopy()
+				for _, res := range index.Resources() {
+					a.Redactor.Redact(res)
+				}
+			}
+
 			y, err := index.AsYaml()
 			if err != nil {
 				a.Logger.Error(err, "failed to encode as yaml")
-				errs <- err
+				errs <- outputError(err)
 				continue
 			}
 
 			_, err = a.Output.Write(append([]byte("---\n"), y...))
 			if err != nil {
 				a.Logger.Error(err, "failed to write helm manifests to output")
-				errs <- err
+				errs <- outputError(err)
 				continue
 			}
 		}
 	}, errs, &panicForward)
 
+	origins := make(build.Origins)
+	inputs := make(map[string]build.Inputs)
+	var originsMu sync.Mutex
+
+	kustomizeOpts := a.kustomizeOpts(helmBuilder)
 	for _, path := range a.Paths {
 		p := path
 		a.Logger.Info("build kustomize path", "path", p)
+		rep.AddTarget(report.Target{Path: p})
 
 		submit(kustomizePool, func() {
-			if index, err := build.Kustomize(ctx, p); err != nil {
+			if index, pathOrigins, pathInputs, err := build.KustomizeWithInputs(ctx, p, kustomizeOpts); err != nil {
 				a.Logger.Error(err, "failed build kustomization", "path", p)
-				errs <- err
+				errs <- pathError(p, err)
 			} else {
-				manifests <- index
+				originsMu.Lock()
+				for id, file := range pathOrigins {
+					origins[id] = file
+				}
+				inputs[p] = pathInputs
+				originsMu.Unlock()
+
+				manifests <- targetManifests{target: report.Target{Path: p}, index: index}
 				resources <- index
 			}
 		}, errs, &panicForward)
@@ -120,9 +365,9 @@ func (a *Action) Run(ctx context.Context) error {
 
 	index := make(build.ResourceIndex)
 	submit(resourcePool, func() {
-		for build := range resources {
-			if err := index.Push(build.Resources()); err != nil {
-				errs <- err
+		for m := range resources {
+			if err := index.Push(m.Resources()); err != nil {
+				errs <- &report.Error{Phase: string(build.PhaseKustomize), Message: err.Error()}
 				continue
 			}
 		}
@@ -132,6 +377,11 @@ func (a *Action) Run(ctx context.Context) error {
 	close(resources)
 	resourcePool.StopAndWait()
 
+	var changed map[resid.ResId]bool
+	if a.Changes != nil {
+		changed = a.changedResources(inputs)
+	}
+
 	for _, r := range index {
 		res := r
 		if r.GetKind() != helmv2.HelmReleaseKind {
@@ -142,24 +392,72 @@ func (a *Action) Run(ctx context.Context) error {
 			break
 		}
 
+		if a.Filter != nil && !a.Filter.MatchRelease(r.GetNamespace(), r.GetName()) {
+			a.Logger.V(1).Info("skip filtered helm release", "namespace", r.GetNamespace(), "name", r.GetName())
+			continue
+		}
+
+		if a.Changes != nil && a.skipUnchanged(rep, index, changed, res) {
+			continue
+		}
+
+		rep.AddTarget(report.Target{HelmRelease: releaseID(res)})
 		submit(helmPool, func() {
 			a.Logger.Info("build helm release", "namespace", res.GetNamespace(), "name", res.GetName())
-			index, err := helmBuilder.Build(ctx, res, index)
+			result, err := helmBuilder.BuildRelease(ctx, res, index)
+			if result.Chart != nil && a.Charts != nil {
+				result.Chart.HelmRelease = releaseID(res)
+				a.Charts.Add(*result.Chart)
+			}
+
+			for _, e := range unknownValues(res, origins[res.CurId()], result.UnknownValues, a.UnknownValues, a.IgnoreUnknownValues) {
+				errs <- e
+			}
+
+			if result.StaleRenderCache {
+				errs <- staleRenderCacheError(res)
+			}
+
 			if err != nil {
 				a.Logger.Error(err, "failed build helmrelease", "namespace", res.GetNamespace(), "name", res.GetName())
-				errs <- err
+				errs <- releaseError(res, origins[res.CurId()], err)
 				return
 			}
 
-			manifests <- index
+			manifests <- targetManifests{target: report.Target{HelmRelease: releaseID(res)}, index: result.Index, namespace: releaseNamespace(res)}
 		}, errs, &panicForward)
 	}
 
 	helmPool.StopAndWait()
 	close(manifests)
 	helmResultPool.StopAndWait()
+
+	if a.ConflictMode != conflict.ModeIgnore {
+		for _, c := range conflicts.Conflicts() {
+			errs <- conflictError(c, a.ConflictMode)
+		}
+	}
+
+	// Custom resources can only be validated once all CustomResourceDefinitions are known,
+	// policies once all policies, bindings and params are known.
+	for _, obj := range outputObjects {
+		if validator != nil {
+			for _, err := range validateObject(validator, obj) {
+				errs <- err
+			}
+		}
+
+		if evaluator != nil {
+			for _, err := range evaluatePolicies(evaluator, obj) {
+				errs <- err
+			}
+		}
+	}
+
 	panicForward.Wait()
 	close(errs)
+	<-collected
 
-	return nil
+	rep.Sort()
+	return rep
 }
@@ -0,0 +1,83 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doodlescheduling/flux-build/internal/conflict"
+	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+)
+
+func TestRunConflictsReleaseNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	url := chartRepository(t, &helmchart.Chart{
+		Metadata: &helmchart.Metadata{APIVersion: helmchart.APIVersionV2, Name: "app", Version: "1.0.0"},
+		Templates: []*helmchart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n")},
+		},
+	})
+
+	// The same chart is installed into the namespace of the release and a target namespace
+	path := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(path, "releases.yaml"), []byte(`apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: HelmRepository
+metadata:
+  name: app
+  namespace: dev
+spec:
+  url: `+url+`
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app
+  namespace: dev
+spec:
+  chart:
+    spec:
+      chart: app
+      version: 1.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: app
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app-prod
+  namespace: dev
+spec:
+  targetNamespace: prod
+  chart:
+    spec:
+      chart: app
+      version: 1.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: app
+`), 0644)).To(Succeed())
+
+	cache, err := chartcache.New("fs", t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var out bytes.Buffer
+	a := &Action{
+		Output:       &out,
+		Cache:        cache,
+		Workers:      1,
+		Logger:       logr.Discard(),
+		Paths:        []string{path},
+		ConflictMode: conflict.ModeFail,
+	}
+
+	rep := a.Run(context.TODO())
+	g.Expect(rep.Errors).To(BeEmpty())
+	g.Expect(out.String()).To(ContainSubstring("namespace: prod"))
+}
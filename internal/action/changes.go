@@ -0,0 +1,81 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/doodlescheduling/flux-build/internal/build"
+	"github.com/doodlescheduling/flux-build/internal/changes"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+// manifestExtensions are the extensions of files kustomize reads manifests and patches from.
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// changedResources returns the objects of all paths which have been built from one of the changed files.
+// All objects of a path are considered changed if a changed manifest within the path or next to one of its
+// inputs is not an input itself, e.g. a kustomization file or a patch.
+func (a *Action) changedResources(paths map[string]build.Inputs) map[resid.ResId]bool {
+	changed := make(map[resid.ResId]bool)
+	for path, inputs := range paths {
+		var files []string
+		dirs := make(map[string]bool)
+		for id, idFiles := range inputs {
+			for _, file := range idFiles {
+				files = append(files, file)
+				dirs[filepath.Dir(file)] = true
+				if a.Changes.Contains(file) {
+					changed[id] = true
+				}
+			}
+		}
+
+		var candidates []string
+		if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+			candidates = a.Changes.Within(path)
+		}
+
+		for dir := range dirs {
+			for _, file := range a.Changes.Within(dir) {
+				if filepath.Dir(file) == dir {
+					candidates = append(candidates, file)
+				}
+			}
+		}
+
+		known := changes.New(files)
+		for _, file := range candidates {
+			if !manifestExtensions[filepath.Ext(file)] || known.Contains(file) {
+				continue
+			}
+
+			a.Logger.Info("changed file is not the input of a single object, all objects of the path are considered changed", "path", path, "file", file)
+			for id := range inputs {
+				changed[id] = true
+			}
+
+			break
+		}
+	}
+
+	return changed
+}
+
+// skipUnchanged records the HelmRelease as skipped and returns true if none of its dependencies changed.
+func (a *Action) skipUnchanged(rep *report.Report, index build.ResourceIndex, changed map[resid.ResId]bool, res *resource.Resource) bool {
+	for _, dep := range index.ReleaseDependencies(res) {
+		if changed[dep.CurId()] {
+			return false
+		}
+	}
+
+	a.Logger.V(1).Info("skip helm release not affected by the changed files", "namespace", res.GetNamespace(), "name", res.GetName())
+	rep.Skip(report.Target{HelmRelease: releaseID(res)})
+	return true
+}
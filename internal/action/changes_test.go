@@ -0,0 +1,105 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doodlescheduling/flux-build/internal/build"
+	"github.com/doodlescheduling/flux-build/internal/changes"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+func TestChangedResources(t *testing.T) {
+	configMap := resid.NewGvk("", "v1", "ConfigMap")
+	app := resid.NewResIdWithNamespace(configMap, "app", "default")
+	db := resid.NewResIdWithNamespace(configMap, "db", "default")
+	infra := resid.NewResIdWithNamespace(configMap, "infra", "default")
+
+	tests := []struct {
+		name     string
+		changed  []string
+		expected []resid.ResId
+	}{
+		{
+			name: "no changes",
+		},
+		{
+			name:     "changed input",
+			changed:  []string{"apps/app.yaml"},
+			expected: []resid.ResId{app},
+		},
+		{
+			name:     "changed inputs of multiple paths",
+			changed:  []string{"apps/db.yaml", "base/infra.yaml"},
+			expected: []resid.ResId{db, infra},
+		},
+		{
+			name:     "changed kustomization within the path",
+			changed:  []string{"apps/kustomization.yaml"},
+			expected: []resid.ResId{app, db},
+		},
+		{
+			name:     "changed patch next to an input outside of the path",
+			changed:  []string{"base/patch.yaml"},
+			expected: []resid.ResId{infra},
+		},
+		{
+			name:    "changed file which is not a manifest",
+			changed: []string{"apps/README.md"},
+		},
+		{
+			name:    "changed file of another path",
+			changed: []string{"clusters/kustomization.yaml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			root := t.TempDir()
+			for _, name := range []string{
+				"apps/kustomization.yaml",
+				"apps/app.yaml",
+				"apps/db.yaml",
+				"apps/README.md",
+				"infrastructure/kustomization.yaml",
+				"base/infra.yaml",
+				"base/patch.yaml",
+				"clusters/kustomization.yaml",
+			} {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(root, name), nil, 0644)).To(Succeed())
+			}
+
+			paths := map[string]build.Inputs{
+				filepath.Join(root, "apps"): {
+					app: {filepath.Join(root, "apps/app.yaml")},
+					db:  {filepath.Join(root, "apps/db.yaml")},
+				},
+				filepath.Join(root, "infrastructure"): {
+					infra: {filepath.Join(root, "base/infra.yaml")},
+				},
+			}
+
+			var changed []string
+			for _, file := range test.changed {
+				changed = append(changed, filepath.Join(root, file))
+			}
+
+			a := &Action{
+				Logger:  logr.Discard(),
+				Changes: changes.New(changed),
+			}
+
+			result := a.changedResources(paths)
+			g.Expect(result).To(HaveLen(len(test.expected)))
+			for _, id := range test.expected {
+				g.Expect(result).To(HaveKeyWithValue(id, true))
+			}
+		})
+	}
+}
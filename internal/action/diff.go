@@ -0,0 +1,135 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doodlescheduling/flux-build/internal/diff"
+	"github.com/doodlescheduling/flux-build/internal/git"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// RunDiff builds all paths at the git ref baseRef and in the working tree and writes
+// a markdown summary of the differences to the configured output.
+// The returned report contains the errors of the working tree build, errors of the base
+// build are included as warnings.
+func (a *Action) RunDiff(ctx context.Context, baseRef string) (*report.Report, error) {
+	if len(a.Paths) == 0 {
+		return nil, fmt.Errorf("no paths to diff")
+	}
+
+	repo, err := git.TopLevel(ctx, pathDir(a.Paths[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := git.AddWorktree(ctx, repo, baseRef)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := wt.Remove(context.Background()); err != nil {
+			a.Logger.Error(err, "failed to remove git worktree", "path", wt.Path)
+		}
+	}()
+
+	var basePaths []string
+	for _, path := range a.Paths {
+		rel, err := repoRelativePath(repo, path)
+		if err != nil {
+			return nil, err
+		}
+
+		basePath := filepath.Join(wt.Path, rel)
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			a.Logger.Info("path does not exist at base ref, skipping", "path", path, "ref", baseRef)
+			continue
+		}
+
+		basePaths = append(basePaths, basePath)
+	}
+
+	a.Logger.Info("build base ref", "ref", baseRef, "worktree", wt.Path)
+	// The image and chart inventories describe the working tree only
+	baseAction := *a
+	baseAction.Images = nil
+	baseAction.Charts = nil
+	base, baseReport, err := baseAction.buildResources(ctx, basePaths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse build of base ref %s: %w", baseRef, err)
+	}
+
+	a.Logger.Info("build working tree")
+	head, rep, err := a.buildResources(ctx, a.Paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse build of working tree: %w", err)
+	}
+
+	for _, baseErr := range baseReport.Errors {
+		e := *baseErr
+		e.Severity = report.SeverityWarning
+		e.Message = fmt.Sprintf("[base %s] %s", baseRef, e.Message)
+		rep.Add(&e)
+	}
+
+	result, err := diff.Compare(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	return rep, result.Markdown(a.Output, baseRef)
+}
+
+// buildResources runs a build of the given paths and returns all written objects.
+func (a *Action) buildResources(ctx context.Context, paths []string) ([]*resource.Resource, *report.Report, error) {
+	if len(paths) == 0 {
+		return nil, report.New(), nil
+	}
+
+	var buf bytes.Buffer
+	b := *a
+	b.Paths = paths
+	b.Output = &buf
+
+	rep := b.Run(ctx)
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes(buf.Bytes())
+	return resources, rep, err
+}
+
+func repoRelativePath(repo, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	rel, err := filepath.Rel(repo, abs)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is not within git repository %s", path, repo)
+	}
+
+	return rel, nil
+}
+
+func pathDir(path string) string {
+	if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+		return path
+	}
+
+	return filepath.Dir(path)
+}
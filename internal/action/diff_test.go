@@ -0,0 +1,66 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+)
+
+func TestRunDiffFailedBaseBuild(t *testing.T) {
+	g := NewWithT(t)
+
+	repo := t.TempDir()
+	writeFiles := func(files map[string]string) {
+		for name, content := range files {
+			g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(repo, name)), 0755)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(repo, name), []byte(content), 0644)).To(Succeed())
+		}
+	}
+
+	gitRun := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		g.Expect(err).ToNot(HaveOccurred(), string(out))
+	}
+
+	// The base ref references a manifest which does not exist
+	writeFiles(map[string]string{
+		"apps/kustomization.yaml": "resources:\n- missing.yaml\n",
+	})
+
+	gitRun("init", "-q")
+	gitRun("add", "-A")
+	gitRun("commit", "-q", "-m", "base")
+
+	writeFiles(map[string]string{
+		"apps/kustomization.yaml": "resources:\n- configmap.yaml\n",
+		"apps/configmap.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  namespace: default\n",
+	})
+
+	var out bytes.Buffer
+	a := &Action{
+		Output:  &out,
+		Workers: 1,
+		Logger:  logr.Discard(),
+		Paths:   []string{filepath.Join(repo, "apps")},
+	}
+
+	rep, err := a.RunDiff(context.TODO(), "HEAD")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rep.HasErrors()).To(BeFalse())
+	g.Expect(rep.Errors).To(HaveLen(1))
+	g.Expect(rep.Errors[0].Severity).To(Equal(report.SeverityWarning))
+	g.Expect(rep.Errors[0].Message).To(HavePrefix("[base HEAD] "))
+	g.Expect(rep.Errors[0].Message).To(ContainSubstring("missing.yaml"))
+
+	g.Expect(out.String()).To(HavePrefix("## flux-build diff against `HEAD`"))
+	g.Expect(out.String()).To(ContainSubstring("added <code>ConfigMap default/app</code>"))
+}
@@ -0,0 +1,279 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doodlescheduling/flux-build/internal/build"
+	"github.com/doodlescheduling/flux-build/internal/conflict"
+	"github.com/doodlescheduling/flux-build/internal/deprecation"
+	"github.com/doodlescheduling/flux-build/internal/images"
+	"github.com/doodlescheduling/flux-build/internal/policy"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"github.com/doodlescheduling/flux-build/internal/validate"
+	"github.com/doodlescheduling/flux-build/internal/values"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+const (
+	phaseInternal    = "internal"
+	phaseOutput      = "output"
+	phaseConflict    = "conflict"
+	phaseValidate    = "validate"
+	phasePolicy      = "policy"
+	phaseDeprecation = "deprecation"
+	phaseImage       = "image"
+	phaseOutdated    = "outdated"
+	phaseKubeVersion = "kubeversion"
+	phaseVendor      = "vendor"
+)
+
+func releaseID(res *resource.Resource) string {
+	return fmt.Sprintf("%s/%s", res.GetNamespace(), res.GetName())
+}
+
+// releaseNamespace returns the namespace the HelmRelease installs its objects into.
+func releaseNamespace(res *resource.Resource) string {
+	if ns, err := res.GetString("spec.targetNamespace"); err == nil && ns != "" {
+		return ns
+	}
+
+	return res.GetNamespace()
+}
+
+// releaseError enriches a HelmRelease build error with the release, source and chart context
+// as well as the manifest file and line the HelmRelease is declared in.
+func releaseError(res *resource.Resource, file string, err error) *report.Error {
+	e := &report.Error{
+		Phase:       string(build.ErrorPhase(err, build.PhaseRender)),
+		HelmRelease: releaseID(res),
+		Message:     err.Error(),
+	}
+
+	if chart, _ := res.GetString("spec.chart.spec.chart"); chart != "" {
+		e.Chart = chart
+		if version, _ := res.GetString("spec.chart.spec.version"); version != "" {
+			e.Chart = fmt.Sprintf("%s@%s", chart, version)
+		}
+	}
+
+	if kind, _ := res.GetString("spec.chart.spec.sourceRef.kind"); kind != "" {
+		name, _ := res.GetString("spec.chart.spec.sourceRef.name")
+		namespace, _ := res.GetString("spec.chart.spec.sourceRef.namespace")
+		if namespace == "" {
+			namespace = res.GetNamespace()
+		}
+
+		e.Source = fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+	}
+
+	if file != "" {
+		e.File = relativeFile(file)
+		if line, err := build.DeclarationLine(file, res.GetKind(), res.GetName()); err == nil {
+			e.Line = line
+		}
+	}
+
+	return e
+}
+
+// relativeFile returns file relative to the working directory if it is within it.
+func relativeFile(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return file
+	}
+
+	return rel
+}
+
+func pathError(path string, err error) *report.Error {
+	return &report.Error{
+		Phase:   string(build.ErrorPhase(err, build.PhaseKustomize)),
+		Path:    path,
+		Message: err.Error(),
+	}
+}
+
+func outputError(err error) *report.Error {
+	return &report.Error{
+		Phase:   phaseOutput,
+		Message: err.Error(),
+	}
+}
+
+func conflictError(c conflict.Conflict, mode conflict.Mode) *report.Error {
+	e := &report.Error{
+		Severity: report.SeverityWarning,
+		Phase:    phaseConflict,
+		Message:  c.String(),
+	}
+
+	if mode == conflict.ModeFail {
+		e.Severity = report.SeverityError
+	}
+
+	return e
+}
+
+// staleRenderCacheError reports a HelmRelease whose cached render output differs from its rendered output.
+func staleRenderCacheError(res *resource.Resource) *report.Error {
+	return targetError(report.Target{HelmRelease: releaseID(res)}, string(build.PhaseRender),
+		"cached render output differs from the rendered output, the cache entry has been replaced")
+}
+
+func targetError(t report.Target, phase, msg string) *report.Error {
+	return &report.Error{
+		Phase:       phase,
+		Path:        t.Path,
+		HelmRelease: t.HelmRelease,
+		Message:     msg,
+	}
+}
+
+// validateObject validates an output object and returns an error for each violation.
+// Objects without a known schema are reported as warning.
+func validateObject(v *validate.Validator, obj targetObject) []*report.Error {
+	id := objectID(obj.res)
+	violations, err := v.Validate(obj.res)
+	if errors.Is(err, validate.ErrNoSchema) {
+		e := targetError(obj.target, phaseValidate, fmt.Sprintf("%s: no schema found for %s", id, obj.res.GetApiVersion()))
+		e.Severity = report.SeverityWarning
+		return []*report.Error{e}
+	}
+
+	if err != nil {
+		return []*report.Error{targetError(obj.target, phaseValidate, fmt.Sprintf("%s: %s", id, err))}
+	}
+
+	var errs []*report.Error
+	for _, violation := range violations {
+		errs = append(errs, targetError(obj.target, phaseValidate, fmt.Sprintf("%s: %s", id, violation)))
+	}
+
+	return errs
+}
+
+func objectID(res *resource.Resource) string {
+	if res.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", res.GetKind(), res.GetName())
+	}
+
+	return fmt.Sprintf("%s/%s/%s", res.GetKind(), res.GetNamespace(), res.GetName())
+}
+
+// evaluatePolicies evaluates the ValidatingAdmissionPolicies against an output object.
+// Denials are reported as error, warnings as warning.
+func evaluatePolicies(e *policy.Evaluator, obj targetObject) []*report.Error {
+	id := objectID(obj.res)
+	results, err := e.Evaluate(obj.res)
+	if err != nil {
+		return []*report.Error{targetError(obj.target, phasePolicy, fmt.Sprintf("%s: %s", id, err))}
+	}
+
+	var errs []*report.Error
+	for _, result := range results {
+		e := targetError(obj.target, phasePolicy, fmt.Sprintf("%s: %s", id, result))
+		if result.Action == admissionregistrationv1.Warn {
+			e.Severity = report.SeverityWarning
+		}
+
+		errs = append(errs, e)
+	}
+
+	return errs
+}
+
+// checkDeprecation checks the apiVersion of an output object against each Kubernetes version.
+// An API which is removed at any of the versions is reported as error, a deprecated one as warning.
+func checkDeprecation(c *deprecation.Checker, kubeVersions []string, obj targetObject) *report.Error {
+	var deprecated *deprecation.Finding
+	for _, v := range kubeVersions {
+		finding, err := c.Check(obj.res.GetApiVersion(), obj.res.GetKind(), v)
+		if err != nil {
+			return targetError(obj.target, phaseDeprecation, err.Error())
+		}
+
+		if finding == nil {
+			continue
+		}
+
+		if finding.Removed {
+			return targetError(obj.target, phaseDeprecation, fmt.Sprintf("%s: %s", objectID(obj.res), finding))
+		}
+
+		if deprecated == nil {
+			deprecated = finding
+		}
+	}
+
+	if deprecated == nil {
+		return nil
+	}
+
+	e := targetError(obj.target, phaseDeprecation, fmt.Sprintf("%s: %s", objectID(obj.res), deprecated))
+	e.Severity = report.SeverityWarning
+	return e
+}
+
+// checkImages adds the images of an output object to the inventory and checks them against the image policy.
+func checkImages(inventory *images.Inventory, imagePolicy *images.Policy, obj targetObject) []*report.Error {
+	imgs, err := images.Extract(obj.res)
+	if err != nil {
+		return []*report.Error{targetError(obj.target, phaseImage, err.Error())}
+	}
+
+	var errs []*report.Error
+	for i := range imgs {
+		imgs[i].Path = obj.target.Path
+		imgs[i].HelmRelease = obj.target.HelmRelease
+
+		if imagePolicy == nil {
+			continue
+		}
+
+		for _, violation := range imagePolicy.Check(imgs[i]) {
+			errs = append(errs, targetError(obj.target, phaseImage, fmt.Sprintf("%s: container %s: %s", objectID(obj.res), imgs[i].Container, violation)))
+		}
+	}
+
+	if inventory != nil {
+		inventory.Add(imgs...)
+	}
+
+	return errs
+}
+
+// unknownValues reports the values of a HelmRelease which are not declared by its chart.
+func unknownValues(res *resource.Resource, file string, paths []string, mode values.Mode, ignore values.Ignore) []*report.Error {
+	var errs []*report.Error
+	for _, p := range paths {
+		if ignore.Match(releaseID(res), p) {
+			continue
+		}
+
+		e := releaseError(res, file, fmt.Errorf("value %s is not declared in the chart values or schema and has no effect", p))
+		e.Phase = string(build.PhaseValues)
+		if mode == values.ModeWarn {
+			e.Severity = report.SeverityWarning
+		}
+
+		errs = append(errs, e)
+	}
+
+	return errs
+}
@@ -0,0 +1,172 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doodlescheduling/flux-build/internal/build"
+	memcache "github.com/doodlescheduling/flux-build/internal/cache"
+	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
+	"github.com/doodlescheduling/flux-build/internal/matrix"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// RunMatrix builds all paths once per Kubernetes version and writes a markdown summary of the HelmReleases
+// which fail or render a different output between the versions to the configured output.
+// Charts and chart repositories are fetched once and reused for all versions.
+// If outputDir is set the build output of each version is written to outputDir/<version>.yaml.
+// Errors which only occur at some versions are prefixed with these versions, releases which render
+// differently are reported as warnings.
+func (a *Action) RunMatrix(ctx context.Context, kubeVersions []*chartutil.KubeVersion, outputDir string) (*report.Report, error) {
+	if len(kubeVersions) == 0 {
+		return nil, fmt.Errorf("no kube versions to build")
+	}
+
+	cache := a.Cache
+	if _, ok := cache.(*chartcache.Null); ok || cache == nil {
+		var err error
+		if cache, err = chartcache.New("inmemory", ""); err != nil {
+			return nil, err
+		}
+	}
+
+	repositories := a.Repositories
+	if repositories == nil {
+		repositories = memcache.New[build.CacheKey]()
+	}
+
+	if outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	var builds []matrix.Build
+	var reports []*report.Report
+	var versions []string
+	for i, kubeVersion := range kubeVersions {
+		a.Logger.Info("build kube version", "version", kubeVersion.Version)
+		b := *a
+		b.KubeVersion = kubeVersion
+		b.Cache = cache
+		b.Repositories = repositories
+		if i > 0 {
+			// The image and chart inventories describe the first version only
+			b.Images = nil
+			b.Charts = nil
+		}
+
+		resources, rep, err := b.buildResources(ctx, a.Paths)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse build of kube version %s: %w", kubeVersion.Version, err)
+		}
+
+		if outputDir != "" {
+			if err := writeResources(filepath.Join(outputDir, kubeVersion.Version+".yaml"), resources); err != nil {
+				return nil, err
+			}
+		}
+
+		versionBuild := matrix.Build{
+			KubeVersion: kubeVersion.Version,
+			Resources:   resources,
+		}
+
+		for _, t := range rep.Targets {
+			if t.HelmRelease == "" {
+				continue
+			}
+
+			for _, e := range rep.TargetErrors(t) {
+				if e.Severity == report.SeverityError {
+					versionBuild.Failed = append(versionBuild.Failed, t.HelmRelease)
+					break
+				}
+			}
+		}
+
+		builds = append(builds, versionBuild)
+		reports = append(reports, rep)
+		versions = append(versions, kubeVersion.Version)
+	}
+
+	result, err := matrix.Compare(builds)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := mergeVersionReports(versions, reports)
+	for _, r := range result.Releases {
+		for i, status := range r.Status {
+			if status != matrix.StatusChanged {
+				continue
+			}
+
+			e := targetError(report.Target{HelmRelease: r.ID}, phaseKubeVersion,
+				fmt.Sprintf("%d object(s) rendered differently with kube version %s than with %s", r.Changes[i], versions[i], versions[i-1]))
+			e.Severity = report.SeverityWarning
+			rep.Add(e)
+		}
+	}
+
+	rep.Sort()
+	return rep, result.Markdown(a.Output)
+}
+
+// mergeVersionReports merges the reports of builds at multiple kube versions.
+// Errors which occur at all versions are added once, others are prefixed with the versions they occur at.
+func mergeVersionReports(versions []string, reports []*report.Report) *report.Report {
+	rep := report.New()
+	targets := make(map[report.Target]bool)
+	var errs []report.Error
+	errVersions := make(map[report.Error][]string)
+
+	for i, r := range reports {
+		for _, t := range r.Targets {
+			if !targets[t] {
+				targets[t] = true
+				rep.AddTarget(t)
+			}
+		}
+
+		for _, e := range r.Errors {
+			if _, ok := errVersions[*e]; !ok {
+				errs = append(errs, *e)
+			}
+
+			errVersions[*e] = append(errVersions[*e], versions[i])
+		}
+	}
+
+	for _, e := range errs {
+		e := e
+		if at := errVersions[e]; len(at) < len(versions) {
+			e.Message = fmt.Sprintf("[kube %s] %s", strings.Join(at, ","), e.Message)
+		}
+
+		rep.Add(&e)
+	}
+
+	return rep
+}
+
+// writeResources writes the resources as multi document YAML to path.
+func writeResources(path string, resources []*resource.Resource) error {
+	var b strings.Builder
+	for _, res := range resources {
+		y, err := res.AsYAML()
+		if err != nil {
+			return err
+		}
+
+		b.WriteString("---\n")
+		b.Write(y)
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
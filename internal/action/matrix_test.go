@@ -0,0 +1,54 @@
+package action
+
+import (
+	"testing"
+
+	"github.com/doodlescheduling/flux-build/internal/report"
+	. "github.com/onsi/gomega"
+)
+
+func TestMergeVersionReports(t *testing.T) {
+	g := NewWithT(t)
+
+	app := report.Target{Path: "apps"}
+	release := report.Target{HelmRelease: "default/app"}
+	infra := report.Target{Path: "infrastructure"}
+
+	versions := []string{"v1.30.0", "v1.31.0", "v1.32.0"}
+	reports := []*report.Report{report.New(), report.New(), report.New()}
+	for i, r := range reports {
+		r.AddTarget(app)
+		r.AddTarget(release)
+		r.Add(&report.Error{Phase: "kustomize", Path: "apps", Message: "invalid kustomization"})
+
+		if i > 0 {
+			r.Add(&report.Error{Phase: "render", HelmRelease: "default/app", Message: "unsupported kube version"})
+		}
+	}
+
+	reports[2].AddTarget(infra)
+	reports[1].Add(&report.Error{Phase: "validate", Path: "apps", Severity: report.SeverityWarning, Message: "no schema found"})
+
+	rep := mergeVersionReports(versions, reports)
+	g.Expect(rep.Targets).To(Equal([]report.Target{app, release, infra}))
+	g.Expect(rep.Errors).To(Equal([]*report.Error{
+		{Severity: report.SeverityError, Phase: "kustomize", Path: "apps", Message: "invalid kustomization"},
+		{Severity: report.SeverityError, Phase: "render", HelmRelease: "default/app", Message: "[kube v1.31.0,v1.32.0] unsupported kube version"},
+		{Severity: report.SeverityWarning, Phase: "validate", Path: "apps", Message: "[kube v1.31.0] no schema found"},
+	}))
+}
+
+func TestMergeVersionReportsDistinctTargets(t *testing.T) {
+	g := NewWithT(t)
+
+	versions := []string{"v1.30.0", "v1.31.0"}
+	reports := []*report.Report{report.New(), report.New()}
+	reports[0].Add(&report.Error{Phase: "render", HelmRelease: "default/a", Message: "failed"})
+	reports[1].Add(&report.Error{Phase: "render", HelmRelease: "default/b", Message: "failed"})
+
+	rep := mergeVersionReports(versions, reports)
+	g.Expect(rep.Errors).To(Equal([]*report.Error{
+		{Severity: report.SeverityError, Phase: "render", HelmRelease: "default/a", Message: "[kube v1.30.0] failed"},
+		{Severity: report.SeverityError, Phase: "render", HelmRelease: "default/b", Message: "[kube v1.31.0] failed"},
+	}))
+}
@@ -0,0 +1,133 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/alitto/pond/v2"
+	"github.com/doodlescheduling/flux-build/internal/build"
+	"github.com/doodlescheduling/flux-build/internal/outdated"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+)
+
+// RunOutdated builds all paths and compares the chart version of each HelmRelease against the latest
+// version available in its chart repository. Charts are neither pulled nor rendered.
+// Outdated charts are reported as warnings, as errors if the update is at least failOn.
+func (a *Action) RunOutdated(ctx context.Context, failOn outdated.Update) (*outdated.Report, *report.Report) {
+	rep := report.New()
+	result := &outdated.Report{}
+
+	index, origins := a.buildIndex(ctx, rep)
+	helmBuilder := build.NewHelmBuilder(a.Logger, build.HelmOpts{
+		Cache:        a.Cache,
+		Variables:    a.Variables,
+		IgnoreEnv:    a.IgnoreEnv,
+		Repositories: a.Repositories,
+	})
+
+	helmPool := pond.NewPool(a.Workers, pond.WithContext(ctx))
+	releases := helmPool.NewGroup()
+	for _, r := range index {
+		res := r
+		if r.GetKind() != helmv2.HelmReleaseKind {
+			continue
+		}
+
+		if a.Filter != nil && !a.Filter.MatchRelease(r.GetNamespace(), r.GetName()) {
+			continue
+		}
+
+		rep.AddTarget(report.Target{HelmRelease: releaseID(res)})
+		releases.Submit(func() {
+			a.Logger.Info("lookup latest chart version", "namespace", res.GetNamespace(), "name", res.GetName())
+			versions, err := helmBuilder.ChartVersions(ctx, res, index)
+			if err != nil {
+				rep.Add(releaseError(res, origins[res.CurId()], err))
+				return
+			}
+
+			update, err := outdated.Classify(versions.Current, versions.Latest)
+			if err != nil {
+				rep.Add(targetError(report.Target{HelmRelease: releaseID(res)}, phaseOutdated, err.Error()))
+				return
+			}
+
+			release := outdated.Release{
+				HelmRelease: releaseID(res),
+				Chart:       versions.Chart,
+				Repository:  versions.Repository,
+				Constraint:  versions.Constraint,
+				Current:     versions.Current,
+				Latest:      versions.Latest,
+				Update:      update,
+			}
+
+			result.Add(release)
+			if update == outdated.UpdateNone {
+				return
+			}
+
+			e := releaseError(res, origins[res.CurId()], errors.New(release.String()))
+			e.Phase = phaseOutdated
+			if failOn == outdated.UpdateNone || !update.AtLeast(failOn) {
+				e.Severity = report.SeverityWarning
+			}
+
+			rep.Add(e)
+		})
+	}
+
+	if err := releases.Wait(); err != nil {
+		rep.Add(&report.Error{Phase: phaseInternal, Message: err.Error()})
+	}
+
+	helmPool.StopAndWait()
+
+	result.Sort()
+	rep.Sort()
+	return result, rep
+}
+
+// buildIndex builds all paths into a single index without rendering any HelmRelease.
+func (a *Action) buildIndex(ctx context.Context, rep *report.Report) (build.ResourceIndex, build.Origins) {
+	index := make(build.ResourceIndex)
+	origins := make(build.Origins)
+	var indexMu sync.Mutex
+
+	kustomizePool := pond.NewPool(len(a.Paths), pond.WithContext(ctx))
+	paths := kustomizePool.NewGroup()
+	kustomizeOpts := a.kustomizeOpts(a.newHelmBuilder())
+	for _, path := range a.Paths {
+		p := path
+		a.Logger.Info("build kustomize path", "path", p)
+		rep.AddTarget(report.Target{Path: p})
+
+		paths.Submit(func() {
+			resources, pathOrigins, err := build.KustomizeWithOrigins(ctx, p, kustomizeOpts)
+			if err != nil {
+				a.Logger.Error(err, "failed build kustomization", "path", p)
+				rep.Add(pathError(p, err))
+				return
+			}
+
+			indexMu.Lock()
+			defer indexMu.Unlock()
+			for id, file := range pathOrigins {
+				origins[id] = file
+			}
+
+			if err := index.Push(resources.Resources()); err != nil {
+				rep.Add(&report.Error{Phase: string(build.PhaseKustomize), Path: p, Message: err.Error()})
+			}
+		})
+	}
+
+	if err := paths.Wait(); err != nil {
+		rep.Add(&report.Error{Phase: phaseInternal, Message: err.Error()})
+	}
+
+	kustomizePool.StopAndWait()
+	return index, origins
+}
@@ -0,0 +1,50 @@
+package action
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doodlescheduling/flux-build/internal/build"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"github.com/doodlescheduling/flux-build/internal/values"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+)
+
+// RunValues builds all paths and merges the values of the HelmRelease namespace/name with the defaults of its chart
+// including the origin of each value. The chart is fetched but not rendered.
+// Values set by a Secret are redacted if a Redactor is configured.
+func (a *Action) RunValues(ctx context.Context, release string) (*build.ReleaseValues, *report.Report) {
+	rep := report.New()
+	index, origins := a.buildIndex(ctx, rep)
+	rep.AddTarget(report.Target{HelmRelease: release})
+
+	helmBuilder := build.NewHelmBuilder(a.Logger, build.HelmOpts{
+		Cache:        a.Cache,
+		Variables:    a.Variables,
+		IgnoreEnv:    a.IgnoreEnv,
+		Repositories: a.Repositories,
+	})
+
+	for _, res := range index {
+		if res.GetKind() != helmv2.HelmReleaseKind || releaseID(res) != release {
+			continue
+		}
+
+		a.Logger.Info("compose helmrelease values", "namespace", res.GetNamespace(), "name", res.GetName())
+		result, err := helmBuilder.ReleaseValues(ctx, res, index)
+		if err != nil {
+			rep.Add(releaseError(res, origins[res.CurId()], err))
+		}
+
+		if result != nil && a.Redactor != nil {
+			values.Redact(result.Values, result.Origins, a.Redactor.RedactString)
+		}
+
+		rep.Sort()
+		return result, rep
+	}
+
+	rep.Add(targetError(report.Target{HelmRelease: release}, phaseInternal, fmt.Sprintf("helmrelease %s not found", release)))
+	rep.Sort()
+	return nil, rep
+}
@@ -0,0 +1,112 @@
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
+	"github.com/doodlescheduling/flux-build/internal/redact"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// chartRepository serves a helm repository containing the chart and returns its url.
+func chartRepository(t *testing.T, c *helmchart.Chart) string {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(srv.Close)
+
+	_, err := chartutil.Save(c, dir)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	index, err := repo.IndexDirectory(dir, srv.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(index.WriteFile(filepath.Join(dir, "index.yaml"), 0644)).To(Succeed())
+
+	return srv.URL
+}
+
+func TestRunValuesRedactSecrets(t *testing.T) {
+	g := NewWithT(t)
+
+	url := chartRepository(t, &helmchart.Chart{
+		Metadata: &helmchart.Metadata{APIVersion: helmchart.APIVersionV2, Name: "app", Version: "1.0.0"},
+		Raw: []*helmchart.File{
+			{Name: chartutil.ValuesfileName, Data: []byte("image: nginx\npassword: \"\"\n")},
+		},
+	})
+
+	path := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(path, "release.yaml"), []byte(`apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: HelmRepository
+metadata:
+  name: app
+  namespace: apps
+spec:
+  url: `+url+`
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+  namespace: apps
+stringData:
+  values.yaml: |
+    password: secret
+    hosts:
+    - db
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: app
+      version: 1.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: app
+  valuesFrom:
+  - kind: Secret
+    name: app
+  values:
+    replicas: 2
+`), 0644)).To(Succeed())
+
+	cache, err := chartcache.New("fs", t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	a := &Action{
+		Cache:    cache,
+		Workers:  1,
+		Logger:   logr.Discard(),
+		Paths:    []string{path},
+		Redactor: redact.New(redact.ModePlaceholder, nil, nil),
+	}
+
+	result, rep := a.RunValues(context.TODO(), "apps/app")
+	g.Expect(rep.HasErrors()).To(BeFalse())
+	g.Expect(result.Values).To(Equal(chartutil.Values{
+		"image":    "nginx",
+		"password": redact.Placeholder,
+		"hosts":    []interface{}{redact.Placeholder},
+		"replicas": float64(2),
+	}))
+
+	a.Redactor = redact.New(redact.ModeNone, nil, nil)
+	result, rep = a.RunValues(context.TODO(), "apps/app")
+	g.Expect(rep.HasErrors()).To(BeFalse())
+	g.Expect(result.Values).To(HaveKeyWithValue("password", "secret"))
+}
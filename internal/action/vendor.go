@@ -0,0 +1,96 @@
+package action
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/doodlescheduling/flux-build/internal/remote"
+	"github.com/doodlescheduling/flux-build/internal/report"
+)
+
+// RunVendor fetches the remote bases and files of all kustomizations within the paths into the vendor directory
+// for offline builds and reports all remotes in use. Mirrored and already vendored remotes are not fetched again.
+// The vendored remotes are recorded within the vendor directory.
+func (a *Action) RunVendor(ctx context.Context) (*remote.Report, *report.Report) {
+	rep := report.New()
+	result := &remote.Report{}
+
+	resolver := a.Kustomize.Remotes
+	if resolver == nil || resolver.VendorDir == "" {
+		rep.Add(&report.Error{Phase: phaseVendor, Message: "vendor directory required"})
+		return result, rep
+	}
+
+	recordFile := filepath.Join(resolver.VendorDir, remote.RecordFile)
+	record, err := remote.ReadReport(recordFile)
+	if err != nil {
+		rep.Add(&report.Error{Phase: phaseVendor, Message: err.Error()})
+		return result, rep
+	}
+
+	vendorer := &remote.Vendorer{
+		Resolver: resolver,
+		Logger:   a.Logger,
+		Record:   record,
+	}
+
+	for _, path := range a.Paths {
+		a.Logger.Info("vendor remotes of kustomize path", "path", path)
+		rep.AddTarget(report.Target{Path: path})
+		if err := vendorer.Vendor(ctx, path, result); err != nil {
+			for _, err := range unwrapJoined(err) {
+				rep.Add(&report.Error{Phase: phaseVendor, Path: path, Message: err.Error()})
+			}
+		}
+	}
+
+	for i, base := range result.Bases {
+		result.Bases[i].Dir = relativeFile(base.Dir)
+		for j, file := range base.ReferencedBy {
+			result.Bases[i].ReferencedBy[j] = relativeFile(file)
+		}
+	}
+
+	result.Sort()
+	rep.Sort()
+
+	if err := writeRecord(recordFile, result); err != nil {
+		rep.Add(&report.Error{Phase: phaseVendor, Message: err.Error()})
+	}
+
+	return result, rep
+}
+
+// unwrapJoined flattens errors joined by errors.Join into the single errors.
+func unwrapJoined(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, unwrapJoined(err)...)
+	}
+
+	return errs
+}
+
+func writeRecord(path string, result *remote.Report) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := result.WriteJSON(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
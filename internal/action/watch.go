@@ -0,0 +1,315 @@
+package action
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond/v2"
+	"github.com/doodlescheduling/flux-build/internal/build"
+	"github.com/doodlescheduling/flux-build/internal/diff"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	"github.com/fsnotify/fsnotify"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// watchDebounce is the time to wait for further file changes before rebuilding.
+const watchDebounce = 200 * time.Millisecond
+
+// watchedPath is the last build of a kustomize path.
+type watchedPath struct {
+	resources []*resource.Resource
+	// dirs are the directories the resources of the path have been read from.
+	dirs map[string]bool
+	errs []*report.Error
+}
+
+// watchedRelease is the last render of a HelmRelease.
+type watchedRelease struct {
+	resources []*resource.Resource
+	errs      []*report.Error
+}
+
+// watcher keeps the builds of all paths and HelmReleases in memory and rebuilds the affected ones on changes.
+type watcher struct {
+	a        *Action
+	helm     *build.Helm
+	index    build.ResourceIndex
+	origins  build.Origins
+	paths    map[string]*watchedPath
+	releases map[string]*watchedRelease
+	objects  []*resource.Resource
+	mu       sync.Mutex
+}
+
+// RunWatch builds all paths and watches them for file changes until ctx is done.
+// Only the kustomize paths and HelmReleases affected by a change are rebuilt, charts and chart repositories are kept
+// in memory. After the initial build the changed objects of each rebuild are written to the output as unified diffs.
+// onBuild is called with the errors of the complete build after each rebuild.
+func (a *Action) RunWatch(ctx context.Context, onBuild func(*report.Report)) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	defer fsWatcher.Close()
+
+	w := &watcher{
+		a:        a,
+		helm:     a.newHelmBuilder(),
+		index:    make(build.ResourceIndex),
+		origins:  make(build.Origins),
+		paths:    make(map[string]*watchedPath),
+		releases: make(map[string]*watchedRelease),
+	}
+
+	onBuild(w.rebuild(ctx, a.Paths, true))
+	w.watch(fsWatcher)
+
+	var changed []string
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-fsWatcher.Errors:
+			a.Logger.Error(err, "file watcher failed")
+		case event := <-fsWatcher.Events:
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			changed = append(changed, event.Name)
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			paths := w.affectedPaths(changed)
+			changed = nil
+			if len(paths) == 0 {
+				continue
+			}
+
+			a.Logger.Info("rebuild changed paths", "paths", paths)
+			onBuild(w.rebuild(ctx, paths, false))
+			w.watch(fsWatcher)
+		}
+	}
+}
+
+// watch adds all directories of the paths and the directories their resources originate from to the file watcher.
+func (w *watcher) watch(fsWatcher *fsnotify.Watcher) {
+	dirs := make(map[string]bool)
+	for _, p := range w.a.Paths {
+		_ = filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			switch {
+			case d.IsDir() && d.Name() == ".git":
+				return filepath.SkipDir
+			case d.IsDir():
+				dirs[path] = true
+			case path == p:
+				dirs[filepath.Dir(path)] = true
+			}
+
+			return nil
+		})
+
+		for dir := range w.paths[p].dirs {
+			dirs[dir] = true
+		}
+	}
+
+	for dir := range dirs {
+		if err := fsWatcher.Add(dir); err != nil {
+			w.a.Logger.V(1).Info("failed to watch directory", "dir", dir, "error", err.Error())
+		}
+	}
+}
+
+// affectedPaths returns the kustomize paths which contain one of the changed files or read resources
+// from the directory of a changed file.
+func (w *watcher) affectedPaths(changed []string) []string {
+	var affected []string
+	for _, p := range w.a.Paths {
+		for _, file := range changed {
+			if within(file, p) || w.paths[p].dirs[filepath.Dir(absPath(file))] {
+				affected = append(affected, p)
+				break
+			}
+		}
+	}
+
+	return affected
+}
+
+func within(file, path string) bool {
+	file, path = absPath(file), absPath(path)
+	return file == path || strings.HasPrefix(file, path+string(filepath.Separator))
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return path
+}
+
+// rebuild builds the given kustomize paths and renders the HelmReleases affected by their changes.
+// Unless initial is set the differences to the previous build are written to the output.
+func (w *watcher) rebuild(ctx context.Context, paths []string, initial bool) *report.Report {
+	for _, p := range paths {
+		w.buildPath(ctx, p)
+	}
+
+	index := make(build.ResourceIndex)
+	rep := report.New()
+	for _, p := range w.a.Paths {
+		rep.AddTarget(report.Target{Path: p})
+		if err := index.Push(w.paths[p].resources); err != nil {
+			rep.Add(&report.Error{Phase: string(build.PhaseKustomize), Path: p, Message: err.Error()})
+		}
+	}
+
+	affected, err := index.AffectedReleases(w.index)
+	if err != nil {
+		rep.Add(&report.Error{Phase: phaseInternal, Message: err.Error()})
+	}
+
+	for id := range w.releases {
+		if !releaseExists(index, id) {
+			delete(w.releases, id)
+		}
+	}
+
+	w.index = index
+	w.renderReleases(ctx, affected)
+
+	for _, p := range w.a.Paths {
+		for _, e := range w.paths[p].errs {
+			rep.Add(e)
+		}
+	}
+
+	var objects []*resource.Resource
+	for _, p := range w.a.Paths {
+		objects = append(objects, w.output(w.paths[p].resources)...)
+	}
+
+	for id, r := range w.releases {
+		rep.AddTarget(report.Target{HelmRelease: id})
+		for _, e := range r.errs {
+			rep.Add(e)
+		}
+
+		objects = append(objects, w.output(r.resources)...)
+	}
+
+	if !initial {
+		result, err := diff.Compare(w.objects, objects)
+		switch {
+		case err != nil:
+			rep.Add(outputError(err))
+		case result.Empty():
+			w.a.Logger.Info("no changes")
+		default:
+			if err := result.Unified(w.a.Output); err != nil {
+				rep.Add(outputError(err))
+			}
+		}
+	}
+
+	w.objects = objects
+	rep.Sort()
+	return rep
+}
+
+func releaseExists(index build.ResourceIndex, id string) bool {
+	for _, res := range index {
+		if res.GetKind() == helmv2.HelmReleaseKind && releaseID(res) == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *watcher) buildPath(ctx context.Context, p string) {
+	path := &watchedPath{dirs: make(map[string]bool)}
+	w.paths[p] = path
+
+	index, origins, err := build.KustomizeWithOrigins(ctx, p, w.a.kustomizeOpts(w.helm))
+	if err != nil {
+		w.a.Logger.Error(err, "failed build kustomization", "path", p)
+		path.errs = append(path.errs, pathError(p, err))
+		return
+	}
+
+	path.resources = index.Resources()
+	for id, file := range origins {
+		w.origins[id] = file
+		path.dirs[filepath.Dir(absPath(file))] = true
+	}
+}
+
+func (w *watcher) renderReleases(ctx context.Context, releases []*resource.Resource) {
+	pool := pond.NewPool(w.a.Workers, pond.WithContext(ctx))
+	group := pool.NewGroup()
+	for _, r := range releases {
+		res := r
+		if w.a.Filter != nil && !w.a.Filter.MatchRelease(res.GetNamespace(), res.GetName()) {
+			continue
+		}
+
+		group.Submit(func() {
+			w.a.Logger.Info("build helm release", "namespace", res.GetNamespace(), "name", res.GetName())
+			release := &watchedRelease{}
+			result, err := w.helm.BuildRelease(ctx, res, w.index)
+			release.errs = unknownValues(res, w.origins[res.CurId()], result.UnknownValues, w.a.UnknownValues, w.a.IgnoreUnknownValues)
+			if result.StaleRenderCache {
+				release.errs = append(release.errs, staleRenderCacheError(res))
+			}
+			if err != nil {
+				w.a.Logger.Error(err, "failed build helmrelease", "namespace", res.GetNamespace(), "name", res.GetName())
+				release.errs = append(release.errs, releaseError(res, w.origins[res.CurId()], err))
+			} else {
+				release.resources = result.Index.Resources()
+			}
+
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.releases[releaseID(res)] = release
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		w.a.Logger.Error(err, "failed to render helm releases")
+	}
+
+	pool.StopAndWait()
+}
+
+// output returns the objects which are written to the output after filtering and redaction.
+func (w *watcher) output(resources []*resource.Resource) []*resource.Resource {
+	var objects []*resource.Resource
+	for _, res := range resources {
+		if w.a.Filter != nil && !w.a.Filter.MatchObject(res) {
+			continue
+		}
+
+		if w.a.Redactor != nil {
+			res = res.DeepCopy()
+			w.a.Redactor.Redact(res)
+		}
+
+		objects = append(objects, res)
+	}
+
+	return objects
+}
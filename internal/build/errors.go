@@ -0,0 +1,70 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// Phase describes the step of a build in which an error occurred.
+type Phase string
+
+const (
+	PhaseKustomize  Phase = "kustomize"
+	PhaseDecode     Phase = "decode"
+	PhaseFetch      Phase = "fetch"
+	PhaseValues     Phase = "values"
+	PhaseRender     Phase = "render"
+	PhasePostRender Phase = "postrender"
+)
+
+// PhaseError is an error which occurred in a specific build phase.
+type PhaseError struct {
+	Phase Phase
+	Err   error
+}
+
+func (e *PhaseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPhase returns the build phase of the given error.
+// It falls back to the given phase if err does not carry one.
+func ErrorPhase(err error, fallback Phase) Phase {
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) {
+		return phaseErr.Phase
+	}
+
+	return fallback
+}
+
+// withPhase wraps err with the phase unless it already carries one.
+func withPhase(phase Phase, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) {
+		return err
+	}
+
+	return &PhaseError{Phase: phase, Err: err}
+}
+
+// phasePostRenderer marks errors from post renderers so they can be distinguished from
+// template rendering errors once returned by helm.
+type phasePostRenderer struct {
+	postrender.PostRenderer
+}
+
+func (r phasePostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	out, err := r.PostRenderer.Run(renderedManifests)
+	return out, withPhase(PhasePostRender, err)
+}
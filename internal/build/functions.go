@@ -0,0 +1,144 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+)
+
+// functionsHook rejects kustomizations whose generators, transformers or validators declare plugins other
+// than the builtin plugins and exec functions matching one of the allowed patterns.
+// Kustomize runs every plugin once they are enabled, this restricts them to the allowed executables.
+type functionsHook struct {
+	fs    filesys.FileSystem
+	allow []string
+	rf    *resource.Factory
+}
+
+// newFunctionsHook returns the hook allowing the exec functions matching one of the glob patterns,
+// relative patterns containing a path separator are resolved against the working directory.
+func newFunctionsHook(fs filesys.FileSystem, patterns []string) (*functionsHook, error) {
+	allow := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if strings.ContainsRune(pattern, filepath.Separator) && !filepath.IsAbs(pattern) {
+			abs, err := filepath.Abs(pattern)
+			if err != nil {
+				return nil, err
+			}
+
+			pattern = abs
+		}
+
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exec function pattern %q: %w", pattern, err)
+		}
+
+		allow = append(allow, pattern)
+	}
+
+	return &functionsHook{
+		fs:    fs,
+		allow: allow,
+		rf:    provider.NewDefaultDepProvider().GetResourceFactory(),
+	}, nil
+}
+
+func (h *functionsHook) rewrite(k *kustomization) error {
+	// Exec functions run within the directory of the kustomization declaring them
+	for _, entries := range [][]string{k.kus.Generators, k.kus.Transformers, k.kus.Validators} {
+		for _, entry := range entries {
+			if err := h.checkEntry(k.dir, entry); err != nil {
+				return fmt.Errorf("%s: %w", k.path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkEntry checks the plugins of an inline plugin config, a plugin config file or all files of a directory.
+func (h *functionsHook) checkEntry(dir, entry string) error {
+	// Entries which can be parsed are inline plugin configs like kustomize decides
+	if resources, err := h.rf.SliceFromBytes([]byte(entry)); err == nil {
+		return h.checkPlugins(dir, resources)
+	}
+
+	path := entry
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, entry)
+	}
+
+	if !h.fs.IsDir(path) {
+		return h.checkFile(dir, path)
+	}
+
+	return h.fs.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		return h.checkFile(dir, path)
+	})
+}
+
+// checkFile checks the plugins declared in a file, files which can not be read or parsed are left to kustomize.
+func (h *functionsHook) checkFile(dir, path string) error {
+	b, err := h.fs.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	resources, err := h.rf.SliceFromBytes(b)
+	if err != nil {
+		return nil
+	}
+
+	return h.checkPlugins(dir, resources)
+}
+
+func (h *functionsHook) checkPlugins(dir string, resources []*resource.Resource) error {
+	for _, res := range resources {
+		gvk := res.GetGvk()
+		if gvk.Group == "" && gvk.Version == konfig.BuiltinPluginApiVersion {
+			continue
+		}
+
+		spec, err := runtimeutil.GetFunctionSpec(&res.RNode)
+		if err != nil {
+			return err
+		}
+
+		if spec == nil || spec.Exec.Path == "" {
+			return fmt.Errorf("plugin %s is not allowed, only builtin plugins and allowed exec functions are supported", res.OrgId())
+		}
+
+		if !h.allowed(dir, spec.Exec.Path) {
+			return fmt.Errorf("exec function %s of plugin %s is not allowed", spec.Exec.Path, res.OrgId())
+		}
+	}
+
+	return nil
+}
+
+// allowed returns true if the executable matches one of the patterns. Relative paths are resolved against the
+// directory, names without a path separator are looked up in PATH and matched as is.
+func (h *functionsHook) allowed(dir, executable string) bool {
+	if strings.ContainsRune(executable, filepath.Separator) && !filepath.IsAbs(executable) {
+		executable = filepath.Join(dir, executable)
+	}
+
+	for _, pattern := range h.allow {
+		if ok, _ := filepath.Match(pattern, executable); ok {
+			return true
+		}
+	}
+
+	return false
+}
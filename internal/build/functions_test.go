@@ -0,0 +1,142 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const execFunction = `#!/bin/sh
+cat > /dev/null
+cat <<EOF
+apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: generated
+EOF
+`
+
+const execFunctionConfig = `apiVersion: example.com/v1
+kind: Generator
+metadata:
+  name: generator
+  annotations:
+    config.kubernetes.io/function: |
+      exec:
+        path: ./fn/generate.sh
+`
+
+func TestKustomizeExecFunctions(t *testing.T) {
+	tests := []struct {
+		name          string
+		kustomization string
+		files         map[string]string
+		allow         []string
+		expected      []string
+		err           string
+	}{
+		{
+			name:          "allowed exec function",
+			kustomization: "generators:\n- generator.yaml\n",
+			files:         map[string]string{"generator.yaml": execFunctionConfig},
+			allow:         []string{"fn/*.sh"},
+			expected:      []string{"ConfigMap/generated"},
+		},
+		{
+			name:          "inline exec function",
+			kustomization: "generators:\n- |\n  apiVersion: example.com/v1\n  kind: Generator\n  metadata:\n    name: generator\n    annotations:\n      config.kubernetes.io/function: |\n        exec:\n          path: ./fn/generate.sh\n",
+			allow:         []string{"fn/generate.sh"},
+			expected:      []string{"ConfigMap/generated"},
+		},
+		{
+			name:          "exec function not allowed",
+			kustomization: "generators:\n- generator.yaml\n",
+			files:         map[string]string{"generator.yaml": execFunctionConfig},
+			allow:         []string{"other/*"},
+			err:           "exec function ./fn/generate.sh of plugin Generator.v1.example.com/generator.[noNs] is not allowed",
+		},
+		{
+			name:          "exec function within a directory",
+			kustomization: "generators:\n- generators\n",
+			files:         map[string]string{"generators/kustomization.yaml": "resources:\n- generator.yaml\n", "generators/generator.yaml": execFunctionConfig},
+			allow:         []string{"other/*"},
+			err:           "is not allowed",
+		},
+		{
+			name:          "container function",
+			kustomization: "transformers:\n- transformer.yaml\n",
+			files:         map[string]string{"transformer.yaml": "apiVersion: example.com/v1\nkind: Transformer\nmetadata:\n  name: transformer\n  annotations:\n    config.kubernetes.io/function: |\n      container:\n        image: example.com/fn:v1\n"},
+			allow:         []string{"*"},
+			err:           "only builtin plugins and allowed exec functions are supported",
+		},
+		{
+			name:          "builtin plugin",
+			kustomization: "resources:\n- configmap.yaml\ntransformers:\n- labels.yaml\n",
+			files: map[string]string{
+				"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+				"labels.yaml":    "apiVersion: builtin\nkind: LabelTransformer\nmetadata:\n  name: labels\nlabels:\n  app: a\nfieldSpecs:\n- path: metadata/labels\n  create: true\n",
+			},
+			allow:    []string{"fn/*.sh"},
+			expected: []string{"ConfigMap/a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			files := map[string]string{"kustomization.yaml": test.kustomization}
+			for name, content := range test.files {
+				files[name] = content
+			}
+
+			for name, content := range files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			g.Expect(os.MkdirAll(filepath.Join(dir, "fn"), 0755)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(dir, "fn", "generate.sh"), []byte(execFunction), 0755)).To(Succeed())
+
+			var allow []string
+			for _, pattern := range test.allow {
+				allow = append(allow, filepath.Join(dir, pattern))
+			}
+
+			index, err := Kustomize(context.TODO(), dir, KustomizeOpts{ExecFunctions: allow})
+			if test.err != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(test.err))
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, res := range index.Resources() {
+				names = append(names, res.GetKind()+"/"+res.GetName())
+			}
+
+			g.Expect(names).To(ConsistOf(test.expected))
+		})
+	}
+}
+
+func TestKustomizeExecFunctionsDisabled(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("generators:\n- generator.yaml\n"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "generator.yaml"), []byte(execFunctionConfig), 0644)).To(Succeed())
+
+	_, err := Kustomize(context.TODO(), dir, KustomizeOpts{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("external plugins disabled"))
+}
@@ -1,17 +1,21 @@
 package build
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	memcache "github.com/doodlescheduling/flux-build/internal/cache"
+	"github.com/doodlescheduling/flux-build/internal/charts"
 	"github.com/doodlescheduling/flux-build/internal/helm/chart"
 	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
 	"github.com/doodlescheduling/flux-build/internal/helm/getter"
@@ -19,6 +23,8 @@ import (
 	"github.com/doodlescheduling/flux-build/internal/helm/registry"
 	"github.com/doodlescheduling/flux-build/internal/helm/repository"
 	soci "github.com/doodlescheduling/flux-build/internal/oci"
+	"github.com/doodlescheduling/flux-build/internal/rendercache"
+	chartvalues "github.com/doodlescheduling/flux-build/internal/values"
 	"github.com/drone/envsubst"
 	helmv2 "github.com/fluxcd/helm-controller/api/v2"
 	authaws "github.com/fluxcd/pkg/auth/aws"
@@ -31,6 +37,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	helmaction "helm.sh/helm/v3/pkg/action"
+	helmchart "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	helmgetter "helm.sh/helm/v3/pkg/getter"
@@ -43,6 +50,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/helm/pkg/strvals"
+	"sigs.k8s.io/kustomize/api/provider"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/resource"
 	"sigs.k8s.io/kustomize/kyaml/resid"
@@ -56,10 +64,23 @@ type HelmOpts struct {
 	Getters          helmgetter.Providers
 	Decoder          runtime.Decoder
 	IncludeHelmHooks bool
+	// CheckValues looks up values which are not declared by the chart.
+	CheckValues bool
+	// Variables are substituted in HelmReleases, they take precedence over environment variables.
+	Variables map[string]string
+	// IgnoreEnv disables the substitution of environment variables, only Variables are substituted.
+	IgnoreEnv bool
+	// Repositories caches chart repositories across builders if set.
+	Repositories *memcache.Cache[CacheKey]
+	// RenderCache caches the rendered output of HelmReleases if set.
+	RenderCache *rendercache.Cache
 }
 
+// CacheKey identifies a cached chart repository downloader.
 type CacheKey struct {
 	Repo string
+	// Login is true if the downloader was logged in to the registry.
+	Login bool
 }
 
 // errRegistryAuthOptional indicates cloud auto-login is unavailable (e.g. flux-build
@@ -98,15 +119,286 @@ func NewHelmBuilder(logger logr.Logger, opts HelmOpts) *Helm {
 		opts.Decoder = deserializer
 	}
 
+	if opts.Repositories == nil {
+		opts.Repositories = memcache.New[CacheKey]()
+	}
+
 	return &Helm{
 		Logger:    logger,
 		opts:      opts,
 		cache:     opts.Cache,
-		repoCache: memcache.New[CacheKey](),
+		repoCache: opts.Repositories,
 	}
 }
 
 func (h *Helm) Build(ctx context.Context, r *resource.Resource, db map[ref]*resource.Resource) (resmap.ResMap, error) {
+	result, err := h.BuildRelease(ctx, r, db)
+	return result.Index, err
+}
+
+// HelmResult is the outcome of building a HelmRelease.
+type HelmResult struct {
+	Index resmap.ResMap
+	// Chart is set once the HelmRelease has been decoded, even if fetching or rendering the chart fails afterwards.
+	Chart *charts.Chart
+	// UnknownValues are the paths of values which are not declared by the chart, only set if HelmOpts.CheckValues is set.
+	UnknownValues []string
+	// StaleRenderCache is set if the render cache is verified and its output differs from the rendered output.
+	StaleRenderCache bool
+}
+
+// BuildRelease builds the HelmRelease like Build and additionally returns what it has been rendered from.
+// The returned result is never nil.
+func (h *Helm) BuildRelease(ctx context.Context, r *resource.Resource, db map[ref]*resource.Resource) (*HelmResult, error) {
+	result := &HelmResult{}
+	hr, repository, err := h.decodeRelease(r, db)
+	if err != nil {
+		return result, err
+	}
+
+	chartBuild := &chart.Build{}
+	info := &charts.Chart{
+		Name:       hr.Spec.Chart.Spec.Chart,
+		Constraint: hr.Spec.Chart.Spec.Version,
+	}
+	result.Chart = info
+
+	err = h.buildChart(ctx, repository, *hr, chartBuild, info, db)
+	if err != nil {
+		return result, withPhase(PhaseFetch, err)
+	}
+
+	info.Version = chartBuild.Version
+	if info.Digest, err = fileDigest(chartBuild.Path); err != nil {
+		return result, withPhase(PhaseFetch, err)
+	}
+
+	loadedChart, err := loader.Load(chartBuild.Path)
+	if err != nil {
+		return result, withPhase(PhaseRender, err)
+	}
+
+	info.Dependencies = chartDependencies(loadedChart)
+	values, err := h.composeValues(ctx, db, *hr, nil)
+	if err != nil {
+		return result, withPhase(PhaseValues, err)
+	}
+
+	if h.opts.CheckValues {
+		if result.UnknownValues, err = chartvalues.Unknown(loadedChart, values); err != nil {
+			return result, withPhase(PhaseValues, err)
+		}
+	}
+
+	var cacheKey string
+	if h.opts.RenderCache != nil {
+		if cacheKey, err = h.opts.RenderCache.Key(h.renderInputs(*hr, info.Digest, values)); err != nil {
+			return result, withPhase(PhaseRender, err)
+		}
+
+		if h.opts.RenderCache.Mode() == rendercache.ModeReadWrite {
+			cached, ok, err := h.opts.RenderCache.Get(cacheKey)
+			if err != nil {
+				return result, withPhase(PhaseRender, err)
+			}
+
+			if ok {
+				h.Logger.V(1).Info("use cached render output", "namespace", hr.Namespace, "name", hr.Name, "key", cacheKey)
+				result.Index, err = resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory()).NewResMapFromBytes(cached)
+				return result, withPhase(PhaseRender, err)
+			}
+		}
+	}
+
+	result.Index, err = h.render(ctx, *hr, values, loadedChart)
+	if err != nil || cacheKey == "" {
+		return result, err
+	}
+
+	output, err := result.Index.AsYaml()
+	if err != nil {
+		return result, withPhase(PhaseRender, err)
+	}
+
+	if h.opts.RenderCache.Mode() == rendercache.ModeVerify {
+		cached, ok, err := h.opts.RenderCache.Get(cacheKey)
+		if err != nil {
+			return result, withPhase(PhaseRender, err)
+		}
+
+		// The cached output is kept so it is reported again until the cache is refreshed
+		result.StaleRenderCache = ok && !bytes.Equal(cached, output)
+		return result, nil
+	}
+
+	return result, withPhase(PhaseRender, h.opts.RenderCache.Set(cacheKey, output))
+}
+
+// renderInputs returns the inputs the rendered output of the HelmRelease depends on.
+func (h *Helm) renderInputs(hr helmv2.HelmRelease, chartDigest string, values chartutil.Values) rendercache.Inputs {
+	inputs := rendercache.Inputs{
+		ChartDigest:      chartDigest,
+		Values:           values,
+		Name:             hr.Name,
+		Namespace:        hr.Namespace,
+		ReleaseName:      hr.GetReleaseName(),
+		ReleaseNamespace: hr.GetReleaseNamespace(),
+		APIVersions:      h.opts.APIVersions,
+		IncludeHelmHooks: h.opts.IncludeHelmHooks,
+		Install:          hr.Spec.Install,
+		PostRenderers:    hr.Spec.PostRenderers,
+	}
+
+	if h.opts.KubeVersion != nil {
+		inputs.KubeVersion = h.opts.KubeVersion.Version
+	}
+
+	return inputs
+}
+
+// render renders the chart and post renders the manifests.
+func (h *Helm) render(ctx context.Context, hr helmv2.HelmRelease, values chartutil.Values, loadedChart *helmchart.Chart) (resmap.ResMap, error) {
+	release, err := h.renderRelease(ctx, hr, values, loadedChart)
+	if err != nil {
+		return nil, withPhase(PhaseRender, err)
+	}
+
+	manifests := map[string][]byte{
+		"manifest.yaml": []byte(release.Manifest),
+	}
+
+	if h.opts.IncludeHelmHooks {
+		for i, hook := range release.Hooks {
+			manifests[fmt.Sprintf("hook_%d.yaml", i)] = []byte(hook.Manifest)
+		}
+	}
+
+	index, err := KustomizeManifests(manifests)
+	return index, withPhase(PhasePostRender, err)
+}
+
+// ReleaseValues are the values a HelmRelease is rendered with.
+type ReleaseValues struct {
+	// Values are the chart defaults merged with the values of the HelmRelease.
+	Values chartutil.Values
+	// Origins are the sources which set each leaf of Values.
+	Origins chartvalues.Origins
+}
+
+// ReleaseValues fetches the chart of the HelmRelease and merges its defaults with the values of the HelmRelease
+// the same way Helm does before rendering. The chart is not rendered.
+func (h *Helm) ReleaseValues(ctx context.Context, r *resource.Resource, db map[ref]*resource.Resource) (*ReleaseValues, error) {
+	hr, repository, err := h.decodeRelease(r, db)
+	if err != nil {
+		return nil, err
+	}
+
+	chartBuild := &chart.Build{}
+	if err := h.buildChart(ctx, repository, *hr, chartBuild, &charts.Chart{}, db); err != nil {
+		return nil, withPhase(PhaseFetch, err)
+	}
+
+	loadedChart, err := loader.Load(chartBuild.Path)
+	if err != nil {
+		return nil, withPhase(PhaseRender, err)
+	}
+
+	origins := make(chartvalues.Origins)
+	if err := origins.MergeChart(loadedChart, hr.Spec.Chart.Spec.ValuesFiles); err != nil {
+		return nil, withPhase(PhaseValues, err)
+	}
+
+	values, err := h.composeValues(ctx, db, *hr, origins)
+	if err != nil {
+		return nil, withPhase(PhaseValues, err)
+	}
+
+	if err := chartutil.ProcessDependenciesWithMerge(loadedChart, values); err != nil {
+		return nil, withPhase(PhaseValues, err)
+	}
+
+	merged, err := chartutil.CoalesceValues(loadedChart, values)
+	if err != nil {
+		return nil, withPhase(PhaseValues, err)
+	}
+
+	return &ReleaseValues{
+		Values:  merged,
+		Origins: origins,
+	}, nil
+}
+
+// ChartVersions are the current and latest version of the chart of a HelmRelease.
+type ChartVersions struct {
+	Chart      string
+	Repository string
+	// Constraint is the version or semver range requested by the HelmRelease.
+	Constraint string
+	// Current is the version the constraint resolves to.
+	Current string
+	// Latest is the latest stable version available in the chart repository.
+	Latest string
+}
+
+// ChartVersions resolves the chart version requested by the HelmRelease and looks up the latest stable version
+// from the repository index or the OCI tag list. The chart itself is not pulled.
+func (h *Helm) ChartVersions(ctx context.Context, r *resource.Resource, db map[ref]*resource.Resource) (*ChartVersions, error) {
+	hr, source, err := h.decodeRelease(r, db)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, ok := source.(*sourcev1beta2.HelmRepository)
+	if !ok {
+		return nil, withPhase(PhaseFetch, fmt.Errorf("unsupported chart repository `%T`", source))
+	}
+
+	normalizedURL, err := repository.NormalizeURL(repo.Spec.URL)
+	if err != nil {
+		return nil, withPhase(PhaseFetch, fmt.Errorf("failed to normalize url: %w", err))
+	}
+
+	var chartRepo repository.Downloader
+	repoCacheKey := CacheKey{Repo: normalizedURL, Login: true}
+	cached, ok := h.repoCache.GetOrLock(repoCacheKey)
+	if ok && cached != nil {
+		chartRepo = cached.(repository.Downloader)
+	}
+
+	defer func() {
+		h.repoCache.SetUnlock(repoCacheKey, chartRepo)
+	}()
+
+	if chartRepo == nil {
+		chartRepo, err = h.newChartRepository(ctx, repo, normalizedURL, true, db)
+		if err != nil {
+			return nil, withPhase(PhaseFetch, err)
+		}
+	}
+
+	versions := &ChartVersions{
+		Chart:      hr.Spec.Chart.Spec.Chart,
+		Repository: normalizedURL,
+		Constraint: hr.Spec.Chart.Spec.Version,
+	}
+
+	current, err := chartRepo.GetChartVersion(versions.Chart, versions.Constraint)
+	if err != nil {
+		return nil, withPhase(PhaseFetch, err)
+	}
+
+	latest, err := chartRepo.GetChartVersion(versions.Chart, "")
+	if err != nil {
+		return nil, withPhase(PhaseFetch, err)
+	}
+
+	versions.Current = current.Version
+	versions.Latest = latest.Version
+	return versions, nil
+}
+
+// decodeRelease decodes the HelmRelease with substituted envs and looks up its chart source.
+func (h *Helm) decodeRelease(r *resource.Resource, db map[ref]*resource.Resource) (*helmv2.HelmRelease, runtime.Object, error) {
 	r = r.DeepCopy()
 	r.SetGvk(resid.Gvk{
 		Group:   helmv2.GroupVersion.Group,
@@ -116,29 +408,29 @@ func (h *Helm) Build(ctx context.Context, r *resource.Resource, db map[ref]*reso
 
 	raw, err := r.AsYAML()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal helmrelease as yaml: %w", err)
+		return nil, nil, withPhase(PhaseDecode, fmt.Errorf("failed to marshal helmrelease as yaml: %w", err))
 	}
 
-	substituted, err := envsubst.EvalEnv(string(raw))
+	substituted, err := envsubst.Eval(string(raw), h.lookupVariable)
 	if err != nil {
-		return nil, fmt.Errorf("failed to substitute envs: %w", err)
+		return nil, nil, withPhase(PhaseDecode, fmt.Errorf("failed to substitute envs: %w", err))
 	}
 
 	obj, _, err := h.opts.Decoder.Decode([]byte(substituted), nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed decode resource to helmrelease: %w", err)
+		return nil, nil, withPhase(PhaseDecode, fmt.Errorf("failed decode resource to helmrelease: %w", err))
 	}
 
 	hr, ok := obj.(*helmv2.HelmRelease)
 	if !ok {
-		return nil, fmt.Errorf("expected type %T", helmv2.HelmRelease{})
+		return nil, nil, withPhase(PhaseDecode, fmt.Errorf("expected type %T", helmv2.HelmRelease{}))
 	}
 
 	if hr.Spec.Chart == nil {
 		if hr.Spec.ChartRef != nil {
-			return nil, fmt.Errorf("helmrelease %s/%s uses spec.chartRef, which flux-build does not support; use spec.chart (inline HelmChart template)", hr.Namespace, hr.Name)
+			return nil, nil, withPhase(PhaseDecode, fmt.Errorf("helmrelease %s/%s uses spec.chartRef, which flux-build does not support; use spec.chart (inline HelmChart template)", hr.Namespace, hr.Name))
 		}
-		return nil, fmt.Errorf("helmrelease %s/%s: spec.chart is required", hr.Namespace, hr.Name)
+		return nil, nil, withPhase(PhaseDecode, fmt.Errorf("helmrelease %s/%s: spec.chart is required", hr.Namespace, hr.Name))
 	}
 
 	namespace := hr.Spec.Chart.Spec.SourceRef.Namespace
@@ -156,50 +448,28 @@ func (h *Helm) Build(ctx context.Context, r *resource.Resource, db map[ref]*reso
 	source, ok := db[lookupRef]
 
 	if !ok {
-		return nil, fmt.Errorf("no source `%v` found for helmrelease `%s/%s`", lookupRef, hr.GetNamespace(), hr.GetName())
+		return nil, nil, withPhase(PhaseFetch, fmt.Errorf("no source `%v` found for helmrelease `%s/%s`", lookupRef, hr.GetNamespace(), hr.GetName()))
 	}
 
 	repository, err := h.getRepository(source)
 	if err != nil {
-		return nil, err
+		return nil, nil, withPhase(PhaseFetch, err)
 	}
 
-	chartBuild := &chart.Build{}
-	err = h.buildChart(ctx, repository, *hr, chartBuild, db)
-	if err != nil {
-		return nil, err
-	}
-
-	values, err := h.composeValues(ctx, db, *hr)
-	if err != nil {
-		return nil, err
-	}
-
-	release, err := h.renderRelease(ctx, *hr, values, chartBuild)
-	if err != nil {
-		return nil, err
-	}
+	return hr, repository, nil
+}
 
-	ksDir, err := os.MkdirTemp("", "helmrelease")
-	if err != nil {
-		return nil, err
+// lookupVariable returns the value of a substitution variable.
+func (h *Helm) lookupVariable(name string) string {
+	if v, ok := h.opts.Variables[name]; ok {
+		return v
 	}
 
-	err = os.WriteFile(filepath.Join(ksDir, "manifest.yaml"), []byte(release.Manifest), 0644)
-	if err != nil {
-		return nil, err
+	if h.opts.IgnoreEnv {
+		return ""
 	}
 
-	if h.opts.IncludeHelmHooks {
-		for i, hook := range release.Hooks {
-			err := os.WriteFile(filepath.Join(ksDir, fmt.Sprintf("hook_%d.yaml", i)), []byte(hook.Manifest), 0644)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	return Kustomize(ctx, ksDir)
+	return os.Getenv(name)
 }
 
 func (h *Helm) getRepository(repository *resource.Resource) (runtime.Object, error) {
@@ -224,7 +494,7 @@ func (h *Helm) getRepository(repository *resource.Resource) (runtime.Object, err
 	return r, nil
 }
 
-func (h *Helm) buildChart(ctx context.Context, repository runtime.Object, release helmv2.HelmRelease, b *chart.Build, db map[ref]*resource.Resource) error {
+func (h *Helm) buildChart(ctx context.Context, repository runtime.Object, release helmv2.HelmRelease, b *chart.Build, info *charts.Chart, db map[ref]*resource.Resource) error {
 	chart := &sourcev1beta2.HelmChart{
 		Spec: sourcev1beta2.HelmChartSpec{
 			Chart:   release.Spec.Chart.Spec.Chart,
@@ -241,19 +511,14 @@ func (h *Helm) buildChart(ctx context.Context, repository runtime.Object, releas
 
 	switch repository := repository.(type) {
 	case *sourcev1beta2.HelmRepository:
-		return h.buildFromHelmRepository(ctx, chart, repository, b, db)
+		return h.buildFromHelmRepository(ctx, chart, repository, b, info, db)
 
 	}
 
 	return fmt.Errorf("unsupported chart repository `%T`", repository)
 }
 
-func (h *Helm) renderRelease(ctx context.Context, hr helmv2.HelmRelease, values chartutil.Values, b *chart.Build) (*release.Release, error) {
-	chart, err := loader.Load(b.Path)
-	if err != nil {
-		return nil, err
-	}
-
+func (h *Helm) renderRelease(ctx context.Context, hr helmv2.HelmRelease, values chartutil.Values, chart *helmchart.Chart) (*release.Release, error) {
 	ns := hr.GetReleaseNamespace()
 	if ns == "" {
 		ns = "default"
@@ -304,6 +569,51 @@ func (h *Helm) renderRelease(ctx context.Context, hr helmv2.HelmRelease, values
 	return client.RunWithContext(ctx, chart, values)
 }
 
+// fileDigest returns the sha256 digest of the file at path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// chartDependencies returns the subcharts packaged with the chart.
+// The constraint and repository are taken from the dependencies declared in Chart.yaml.
+func chartDependencies(c *helmchart.Chart) []charts.Dependency {
+	var deps []charts.Dependency
+	for _, sub := range c.Dependencies() {
+		dep := charts.Dependency{
+			Name:    sub.Name(),
+			Version: sub.Metadata.Version,
+		}
+
+		for _, d := range c.Metadata.Dependencies {
+			if d.Name == sub.Name() {
+				dep.Constraint = d.Version
+				dep.Repository = d.Repository
+				break
+			}
+		}
+
+		deps = append(deps, dep)
+	}
+
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Name < deps[j].Name
+	})
+
+	return deps
+}
+
 // Create post renderer instances from HelmRelease and combine them into
 // a single combined post renderer.
 func (h *Helm) postRenderers(hr helmv2.HelmRelease) (postrender.PostRenderer, error) {
@@ -311,7 +621,7 @@ func (h *Helm) postRenderers(hr helmv2.HelmRelease) (postrender.PostRenderer, er
 
 	for _, r := range hr.Spec.PostRenderers {
 		if r.Kustomize != nil {
-			combinedRenderer.AddRenderer(postrenderer.NewPostRendererKustomize(r.Kustomize))
+			combinedRenderer.AddRenderer(postrenderer.NewPostRendererKustomize(r.Kustomize, buildPostRenderer))
 		}
 	}
 	combinedRenderer.AddRenderer(postrenderer.NewPostRendererOriginLabels(&hr))
@@ -320,7 +630,7 @@ func (h *Helm) postRenderers(hr helmv2.HelmRelease) (postrender.PostRenderer, er
 	if combinedRenderer.Len() == 0 {
 		return nil, nil
 	}
-	return &combinedRenderer, nil
+	return phasePostRenderer{&combinedRenderer}, nil
 }
 
 func (h *Helm) validateCRDsPolicy(policy helmv2.CRDsPolicy, defaultValue helmv2.CRDsPolicy) (helmv2.CRDsPolicy, error) {
@@ -344,7 +654,8 @@ func (h *Helm) validateCRDsPolicy(policy helmv2.CRDsPolicy, defaultValue helmv2.
 // composeValues attempts to resolve all ValuesReference resources
 // and merges them as defined. Referenced resources are only retrieved once
 // to ensure a single version is taken into account during the merge.
-func (h *Helm) composeValues(_ context.Context, db map[ref]*resource.Resource, hr helmv2.HelmRelease) (chartutil.Values, error) {
+// The source of each merged value is recorded in origins unless it is nil.
+func (h *Helm) composeValues(_ context.Context, db map[ref]*resource.Resource, hr helmv2.HelmRelease, origins chartvalues.Origins) (chartutil.Values, error) {
 	result := chartutil.Values{}
 
 	for _, v := range hr.Spec.ValuesFrom {
@@ -403,6 +714,7 @@ func (h *Helm) composeValues(_ context.Context, db map[ref]*resource.Resource, h
 			return nil, fmt.Errorf("unsupported ValuesReference kind '%s'", v.Kind)
 		}
 
+		source := fmt.Sprintf("%s %s key %s", v.Kind, namespacedName, v.GetValuesKey())
 		switch v.TargetPath {
 		case "":
 			values, err := chartutil.ReadValues(valuesData)
@@ -410,6 +722,7 @@ func (h *Helm) composeValues(_ context.Context, db map[ref]*resource.Resource, h
 				return nil, fmt.Errorf("unable to read values from key '%s' in %s '%s': %w", v.GetValuesKey(), v.Kind, namespacedName, err)
 			}
 			result = transform.MergeMaps(result, values)
+			origins.Merge(source, values)
 		default:
 			// TODO(hidde): this is a bit of hack, as it mimics the way the option string is passed
 			// 	to Helm from a CLI perspective. Given the parser is however not publicly accessible
@@ -417,21 +730,26 @@ func (h *Helm) composeValues(_ context.Context, db map[ref]*resource.Resource, h
 			stringValuesData := string(valuesData)
 			const singleQuote = "'"
 			const doubleQuote = "\""
-			var err error
+			parse := strvals.ParseInto
 			if (strings.HasPrefix(stringValuesData, singleQuote) && strings.HasSuffix(stringValuesData, singleQuote)) || (strings.HasPrefix(stringValuesData, doubleQuote) && strings.HasSuffix(stringValuesData, doubleQuote)) {
 				stringValuesData = strings.Trim(stringValuesData, singleQuote+doubleQuote)
-				singleValue := v.TargetPath + "=" + stringValuesData
-				err = strvals.ParseIntoString(singleValue, result)
-			} else {
-				singleValue := v.TargetPath + "=" + stringValuesData
-				err = strvals.ParseInto(singleValue, result)
+				parse = strvals.ParseIntoString
 			}
-			if err != nil {
+			singleValue := v.TargetPath + "=" + stringValuesData
+			if err := parse(singleValue, result); err != nil {
 				return nil, fmt.Errorf("unable to merge value from key '%s' in %s '%s' into target path '%s': %w", v.GetValuesKey(), v.Kind, namespacedName, v.TargetPath, err)
 			}
+
+			if origins != nil {
+				layer := make(map[string]interface{})
+				if err := parse(singleValue, layer); err == nil {
+					origins.Merge(fmt.Sprintf("%s targetPath %s", source, v.TargetPath), layer)
+				}
+			}
 		}
 	}
 
+	origins.Merge(fmt.Sprintf("HelmRelease %s/%s spec.values", hr.GetNamespace(), hr.GetName()), hr.GetValues())
 	return transform.MergeMaps(result, hr.GetValues()), nil
 }
 
@@ -486,17 +804,7 @@ func (h *Helm) clientOptionsFromSecret(secret *corev1.Secret, normalizedURL stri
 // In case of a failure it records v1beta2.FetchFailedCondition on the chart
 // object, and returns early.
 func (h *Helm) buildFromHelmRepository(ctx context.Context, obj *sourcev1beta2.HelmChart,
-	repo *sourcev1beta2.HelmRepository, b *chart.Build, db map[ref]*resource.Resource) error {
-	var (
-		tlsConfig     *tls.Config
-		authenticator authn.Authenticator
-		keychain      authn.Keychain
-	)
-
-	// Used to login with the repository declared provider
-	ctxTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
-	defer cancel()
-
+	repo *sourcev1beta2.HelmRepository, b *chart.Build, info *charts.Chart, db map[ref]*resource.Resource) error {
 	normalizedURL, err := repository.NormalizeURL(repo.Spec.URL)
 	if err != nil {
 		return fmt.Errorf("failed to normalize url: %w", err)
@@ -515,9 +823,12 @@ func (h *Helm) buildFromHelmRepository(ctx context.Context, obj *sourcev1beta2.H
 
 	_, err = os.Stat(path)
 	uncachedChart := os.IsNotExist(err)
+	info.Repository = normalizedURL
+	info.FromCache = !uncachedChart
 
 	var chartRepo repository.Downloader
-	repoCacheKey := CacheKey{Repo: normalizedURL}
+	// Downloaders for cached charts are not logged in, they must not be reused for pulls
+	repoCacheKey := CacheKey{Repo: normalizedURL, Login: uncachedChart}
 	r, ok := h.repoCache.GetOrLock(repoCacheKey)
 	if ok && r != nil {
 		chartRepo = r.(repository.Downloader)
@@ -526,115 +837,9 @@ func (h *Helm) buildFromHelmRepository(ctx context.Context, obj *sourcev1beta2.H
 	defer h.repoCache.SetUnlock(repoCacheKey, chartRepo)
 
 	if chartRepo == nil {
-		h.Logger.V(1).Info("using chart repo", "chartrepo", normalizedURL)
-
-		// Construct the Getter options from the HelmRepository data
-		clientOpts := []helmgetter.Option{
-			helmgetter.WithURL(normalizedURL),
-			helmgetter.WithTimeout(1 * time.Minute),
-			helmgetter.WithPassCredentialsAll(repo.Spec.PassCredentials),
-		}
-
-		if secret, err := h.getHelmRepositorySecret(repo, db); secret != nil || err != nil {
-			if err != nil {
-				return err
-			}
-
-			// Build client options from secret
-			opts, tlsCfg, err := h.clientOptionsFromSecret(secret, normalizedURL)
-			if err != nil {
-				return err
-			}
-			clientOpts = append(clientOpts, opts...)
-			tlsConfig = tlsCfg
-
-			// Build registryClient options from secret
-			keychain, err = registry.LoginOptionFromSecret(normalizedURL, *secret)
-			if err != nil {
-				return fmt.Errorf("failed to configure Helm client with secret data: %w", err)
-			}
-		} else if repo.Spec.Provider != sourcev1beta2.GenericOCIProvider && repo.Spec.Type == sourcev1beta2.HelmRepositoryTypeOCI && uncachedChart {
-			auth, authErr := oidcAuth(ctxTimeout, repo.Spec.URL, repo.Spec.Provider)
-			if authErr != nil && !errors.Is(authErr, errRegistryAuthOptional) {
-				return fmt.Errorf("failed to get credential from %s: %w", repo.Spec.Provider, authErr)
-			}
-			if auth != nil {
-				authenticator = auth
-			}
-		}
-
-		var loginOpt helmreg.LoginOption
-		if uncachedChart {
-			loginOpt, err = makeLoginOption(authenticator, keychain, normalizedURL)
-			if err != nil {
-				return err
-			}
-		}
-
-		// Initialize the chart repository
-		switch repo.Spec.Type {
-		case sourcev1beta2.HelmRepositoryTypeOCI:
-			if !helmreg.IsOCI(normalizedURL) {
-				return fmt.Errorf("invalid OCI registry URL: %s", normalizedURL)
-			}
-
-			// with this function call, we create a temporary file to store the credentials if needed.
-			// this is needed because otherwise the credentials are stored in ~/.docker/config.json.
-			// TODO@souleb: remove this once the registry move to Oras v2
-			// or rework to enable reusing credentials to avoid the unneccessary handshake operations
-			registryClient, _, err := registry.ClientGenerator(loginOpt != nil)
-			if err != nil {
-				return fmt.Errorf("failed to construct Helm client: %w", err)
-			}
-
-			/*if credentialsFile != "" {
-				defer func() {
-					if err := os.Remove(credentialsFile); err != nil {
-						//r.eventLogf(ctx, obj, corev1.EventTypeWarning, meta.FailedReason,
-						//		"failed to delete temporary credentials file: %s", err)
-					}
-				}()
-			}*/
-
-			var verifiers []soci.Verifier
-			/*if obj.Spec.Verify != nil {
-				provider := obj.Spec.Verify.Provider
-				verifiers, err = h.makeVerifiers(ctx, obj, authenticator, keychain)
-				if err != nil {
-					if obj.Spec.Verify.SecretRef == nil {
-						provider = fmt.Sprintf("%s keyless", provider)
-					}
-					return fmt.Errorf("failed to verify the signature using provider '%s': %w", provider, err)
-				}
-			}*/
-
-			// Tell the chart repository to use the OCI client with the configured getter
-			clientOpts = append(clientOpts, helmgetter.WithRegistryClient(registryClient))
-			ociChartRepo, err := repository.NewOCIChartRepository(normalizedURL,
-				repository.WithOCIGetter(h.opts.Getters),
-				repository.WithOCIGetterOptions(clientOpts),
-				repository.WithOCIRegistryClient(registryClient),
-				repository.WithVerifiers(verifiers))
-			if err != nil {
-				return err
-			}
-			chartRepo = ociChartRepo
-
-			// If login options are configured, use them to login to the registry
-			// The OCIGetter will later retrieve the stored credentials to pull the chart
-			if loginOpt != nil {
-				err = ociChartRepo.Login(loginOpt)
-				if err != nil {
-					return fmt.Errorf("failed to login to OCI registry: %w", err)
-				}
-			}
-		default:
-			httpChartRepo, err := repository.NewChartRepository(normalizedURL, os.TempDir(), h.opts.Getters, tlsConfig, clientOpts...)
-			if err != nil {
-				return err
-			}
-
-			chartRepo = httpChartRepo
+		chartRepo, err = h.newChartRepository(ctx, repo, normalizedURL, uncachedChart, db)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -674,6 +879,133 @@ func (h *Helm) buildFromHelmRepository(ctx context.Context, obj *sourcev1beta2.H
 	return nil
 }
 
+// newChartRepository constructs a chart repository downloader for the HelmRepository.
+// Registry credentials are only resolved if login is true.
+func (h *Helm) newChartRepository(ctx context.Context, repo *sourcev1beta2.HelmRepository, normalizedURL string, login bool, db map[ref]*resource.Resource) (repository.Downloader, error) {
+	var (
+		tlsConfig     *tls.Config
+		authenticator authn.Authenticator
+		keychain      authn.Keychain
+	)
+
+	// Used to login with the repository declared provider
+	ctxTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	h.Logger.V(1).Info("using chart repo", "chartrepo", normalizedURL)
+
+	// Construct the Getter options from the HelmRepository data
+	clientOpts := []helmgetter.Option{
+		helmgetter.WithURL(normalizedURL),
+		helmgetter.WithTimeout(1 * time.Minute),
+		helmgetter.WithPassCredentialsAll(repo.Spec.PassCredentials),
+	}
+
+	if secret, err := h.getHelmRepositorySecret(repo, db); secret != nil || err != nil {
+		if err != nil {
+			return nil, err
+		}
+
+		// Build client options from secret
+		opts, tlsCfg, err := h.clientOptionsFromSecret(secret, normalizedURL)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, opts...)
+		tlsConfig = tlsCfg
+
+		// Build registryClient options from secret
+		keychain, err = registry.LoginOptionFromSecret(normalizedURL, *secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure Helm client with secret data: %w", err)
+		}
+	} else if repo.Spec.Provider != sourcev1beta2.GenericOCIProvider && repo.Spec.Type == sourcev1beta2.HelmRepositoryTypeOCI && login {
+		auth, authErr := oidcAuth(ctxTimeout, repo.Spec.URL, repo.Spec.Provider)
+		if authErr != nil && !errors.Is(authErr, errRegistryAuthOptional) {
+			return nil, fmt.Errorf("failed to get credential from %s: %w", repo.Spec.Provider, authErr)
+		}
+		if auth != nil {
+			authenticator = auth
+		}
+	}
+
+	var loginOpt helmreg.LoginOption
+	if login {
+		var err error
+		loginOpt, err = makeLoginOption(authenticator, keychain, normalizedURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize the chart repository
+	switch repo.Spec.Type {
+	case sourcev1beta2.HelmRepositoryTypeOCI:
+		if !helmreg.IsOCI(normalizedURL) {
+			return nil, fmt.Errorf("invalid OCI registry URL: %s", normalizedURL)
+		}
+
+		// with this function call, we create a temporary file to store the credentials if needed.
+		// this is needed because otherwise the credentials are stored in ~/.docker/config.json.
+		// TODO@souleb: remove this once the registry move to Oras v2
+		// or rework to enable reusing credentials to avoid the unneccessary handshake operations
+		registryClient, _, err := registry.ClientGenerator(loginOpt != nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct Helm client: %w", err)
+		}
+
+		/*if credentialsFile != "" {
+			defer func() {
+				if err := os.Remove(credentialsFile); err != nil {
+					//r.eventLogf(ctx, obj, corev1.EventTypeWarning, meta.FailedReason,
+					//		"failed to delete temporary credentials file: %s", err)
+				}
+			}()
+		}*/
+
+		var verifiers []soci.Verifier
+		/*if obj.Spec.Verify != nil {
+			provider := obj.Spec.Verify.Provider
+			verifiers, err = h.makeVerifiers(ctx, obj, authenticator, keychain)
+			if err != nil {
+				if obj.Spec.Verify.SecretRef == nil {
+					provider = fmt.Sprintf("%s keyless", provider)
+				}
+				return nil, fmt.Errorf("failed to verify the signature using provider '%s': %w", provider, err)
+			}
+		}*/
+
+		// Tell the chart repository to use the OCI client with the configured getter
+		clientOpts = append(clientOpts, helmgetter.WithRegistryClient(registryClient))
+		ociChartRepo, err := repository.NewOCIChartRepository(normalizedURL,
+			repository.WithOCIGetter(h.opts.Getters),
+			repository.WithOCIGetterOptions(clientOpts),
+			repository.WithOCIRegistryClient(registryClient),
+			repository.WithVerifiers(verifiers))
+		if err != nil {
+			return nil, err
+		}
+
+		// If login options are configured, use them to login to the registry
+		// The OCIGetter will later retrieve the stored credentials to pull the chart
+		if loginOpt != nil {
+			err = ociChartRepo.Login(loginOpt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to login to OCI registry: %w", err)
+			}
+		}
+
+		return ociChartRepo, nil
+	default:
+		httpChartRepo, err := repository.NewChartRepository(normalizedURL, os.TempDir(), h.opts.Getters, tlsConfig, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return httpChartRepo, nil
+	}
+}
+
 // oidcAuth generates registry credentials using fluxcd/pkg/auth (controller/workload identity).
 func oidcAuth(ctx context.Context, url, provider string) (authn.Authenticator, error) {
 	u := strings.TrimPrefix(url, sourcev1beta2.OCIRepositoryPrefix)
@@ -0,0 +1,309 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	memcache "github.com/doodlescheduling/flux-build/internal/cache"
+	"github.com/doodlescheduling/flux-build/internal/charts"
+	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
+	"github.com/doodlescheduling/flux-build/internal/helm/repository"
+	"github.com/doodlescheduling/flux-build/internal/rendercache"
+	chartvalues "github.com/doodlescheduling/flux-build/internal/values"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+func TestChartDependencies(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{
+			Name:    "app",
+			Version: "1.0.0",
+			Dependencies: []*helmchart.Dependency{
+				{Name: "redis", Version: "~18.1", Repository: "oci://registry-1.docker.io/bitnamicharts"},
+				{Name: "common", Version: "2.x.x", Repository: "https://charts.bitnami.com/bitnami"},
+			},
+		},
+	}
+
+	c.AddDependency(
+		&helmchart.Chart{Metadata: &helmchart.Metadata{Name: "redis", Version: "18.1.6"}},
+		&helmchart.Chart{Metadata: &helmchart.Metadata{Name: "common", Version: "2.13.0"}},
+		&helmchart.Chart{Metadata: &helmchart.Metadata{Name: "local", Version: "0.1.0"}},
+	)
+
+	g.Expect(chartDependencies(c)).To(Equal([]charts.Dependency{
+		{Name: "common", Constraint: "2.x.x", Version: "2.13.0", Repository: "https://charts.bitnami.com/bitnami"},
+		{Name: "local", Version: "0.1.0"},
+		{Name: "redis", Constraint: "~18.1", Version: "18.1.6", Repository: "oci://registry-1.docker.io/bitnamicharts"},
+	}))
+}
+
+func TestFileDigest(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "chart.tgz")
+	g.Expect(os.WriteFile(path, []byte("chart"), 0644)).To(Succeed())
+
+	digest, err := fileDigest(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(digest).To(Equal("sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb"))
+}
+
+const valuesManifests = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: values
+  namespace: apps
+data:
+  values.yaml: |
+    image:
+      repository: nginx
+      tag: v1
+    replicas: 2
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: tag
+  namespace: apps
+stringData:
+  tag: v2
+`
+
+func TestComposeValuesOrigins(t *testing.T) {
+	g := NewWithT(t)
+
+	resMap, err := resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory()).NewResMapFromBytes([]byte(valuesManifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := make(ResourceIndex)
+	g.Expect(db.Push(resMap.Resources())).To(Succeed())
+
+	hr := helmv2.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec: helmv2.HelmReleaseSpec{
+			ValuesFrom: []helmv2.ValuesReference{
+				{Kind: "ConfigMap", Name: "values"},
+				{Kind: "Secret", Name: "tag", ValuesKey: "tag", TargetPath: "image.tag"},
+			},
+			Values: &apiextensionsv1.JSON{Raw: []byte(`{"replicas":3}`)},
+		},
+	}
+
+	origins := make(chartvalues.Origins)
+	values, err := NewHelmBuilder(logr.Discard(), HelmOpts{}).composeValues(context.TODO(), db, hr, origins)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(values).To(Equal(chartutil.Values{
+		"image": map[string]interface{}{
+			"repository": "nginx",
+			"tag":        "v2",
+		},
+		"replicas": float64(3),
+	}))
+	g.Expect(origins).To(Equal(chartvalues.Origins{
+		"image.repository": "ConfigMap apps/values key values.yaml",
+		"image.tag":        "Secret apps/tag key tag targetPath image.tag",
+		"replicas":         "HelmRelease apps/app spec.values",
+	}))
+}
+
+func TestLookupVariable(t *testing.T) {
+	g := NewWithT(t)
+	t.Setenv("FLUX_BUILD_TEST_ENV", "env")
+
+	h := NewHelmBuilder(logr.Discard(), HelmOpts{Variables: map[string]string{"CLUSTER": "production"}})
+	g.Expect(h.lookupVariable("CLUSTER")).To(Equal("production"))
+	g.Expect(h.lookupVariable("FLUX_BUILD_TEST_ENV")).To(Equal("env"))
+
+	h = NewHelmBuilder(logr.Discard(), HelmOpts{Variables: map[string]string{"CLUSTER": "production"}, IgnoreEnv: true})
+	g.Expect(h.lookupVariable("CLUSTER")).To(Equal("production"))
+	g.Expect(h.lookupVariable("FLUX_BUILD_TEST_ENV")).To(BeEmpty())
+}
+
+func TestChartVersionsSharedRepository(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		requests++
+		_, _ = w.Write([]byte(`apiVersion: v1
+entries:
+  podinfo:
+  - name: podinfo
+    version: 7.0.0-rc.1
+    urls: [podinfo-7.0.0-rc.1.tgz]
+  - name: podinfo
+    version: 6.1.0
+    urls: [podinfo-6.1.0.tgz]
+  - name: podinfo
+    version: 5.2.0
+    urls: [podinfo-5.2.0.tgz]
+`))
+	}))
+	defer srv.Close()
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(`apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: HelmRepository
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  url: ` + srv.URL + `
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 5.x
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := make(ResourceIndex)
+	g.Expect(db.Push(resources)).To(Succeed())
+
+	// Downloaders of cached charts are not logged in and must not be reused
+	normalizedURL, err := repository.NormalizeURL(srv.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	repositories := memcache.New[CacheKey]()
+	repositories.Set(CacheKey{Repo: normalizedURL}, unauthenticatedDownloader{})
+
+	for i := 0; i < 2; i++ {
+		h := NewHelmBuilder(logr.Discard(), HelmOpts{Repositories: repositories})
+		versions, err := h.ChartVersions(context.TODO(), resources[1], db)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(versions.Current).To(Equal("5.2.0"))
+		g.Expect(versions.Latest).To(Equal("6.1.0"))
+	}
+
+	g.Expect(requests).To(Equal(1))
+	g.Expect(repositories.ItemCount()).To(Equal(2))
+}
+
+// unauthenticatedDownloader fails like a downloader which is not logged in to a private registry.
+type unauthenticatedDownloader struct{}
+
+func (unauthenticatedDownloader) GetChartVersion(name, version string) (*repo.ChartVersion, error) {
+	return nil, errors.New("unauthorized")
+}
+
+func (unauthenticatedDownloader) DownloadChart(chart *repo.ChartVersion) (*bytes.Buffer, error) {
+	return nil, errors.New("unauthorized")
+}
+
+func (unauthenticatedDownloader) VerifyChart(ctx context.Context, chart *repo.ChartVersion) error {
+	return nil
+}
+
+func (unauthenticatedDownloader) Clear() error {
+	return nil
+}
+
+func TestBuildReleaseVerifyRenderCache(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	_, err := chartutil.Save(&helmchart.Chart{
+		Metadata: &helmchart.Metadata{APIVersion: helmchart.APIVersionV2, Name: "app", Version: "1.0.0"},
+		Templates: []*helmchart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n")},
+		},
+	}, dir)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	index, err := repo.IndexDirectory(dir, srv.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(index.WriteFile(filepath.Join(dir, "index.yaml"), 0644)).To(Succeed())
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(`apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: HelmRepository
+metadata:
+  name: app
+  namespace: apps
+spec:
+  url: ` + srv.URL + `
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: app
+      version: 1.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: app
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := make(ResourceIndex)
+	g.Expect(db.Push(resources)).To(Succeed())
+
+	cacheDir := t.TempDir()
+	build := func(mode rendercache.Mode) *HelmResult {
+		renderCache, err := rendercache.New(cacheDir, mode, "test")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		cache, err := chartcache.New("fs", filepath.Join(dir, "charts"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		h := NewHelmBuilder(logr.Discard(), HelmOpts{Cache: cache, RenderCache: renderCache})
+		result, err := h.BuildRelease(context.TODO(), resources[1], db)
+		g.Expect(err).ToNot(HaveOccurred())
+		return result
+	}
+
+	g.Expect(build(rendercache.ModeReadWrite).StaleRenderCache).To(BeFalse())
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(HaveLen(1))
+
+	stale := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: stale\n")
+	g.Expect(os.WriteFile(entries[0], stale, 0644)).To(Succeed())
+
+	// Verifying does not replace the stale output
+	g.Expect(build(rendercache.ModeVerify).StaleRenderCache).To(BeTrue())
+	g.Expect(build(rendercache.ModeVerify).StaleRenderCache).To(BeTrue())
+
+	b, err := os.ReadFile(entries[0])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(b).To(Equal(stale))
+}
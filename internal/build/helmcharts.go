@@ -0,0 +1,417 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doodlescheduling/flux-build/internal/charts"
+	"github.com/doodlescheduling/flux-build/internal/helm/chart"
+	"github.com/fluxcd/pkg/runtime/transform"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
+	helmaction "helm.sh/helm/v3/pkg/action"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	valuesMergeOverride = "override"
+	valuesMergeMerge    = "merge"
+	valuesMergeReplace  = "replace"
+)
+
+// helmChartsHook inflates the helmCharts of kustomizations like the HelmChartInflationGenerator of kustomize,
+// the charts are fetched with the chart cache and rendered by the Helm builder instead of the helm binary.
+// Each chart is written into the in-memory layer of the filesystem and replaces the helmCharts of the kustomization
+// as a resource.
+type helmChartsHook struct {
+	fs         filesys.FileSystem
+	ctx        context.Context
+	helm       *Helm
+	restrictor kustypes.LoadRestrictions
+	// charts maps the rendered chart files to the kustomization file declaring the chart followed by its values files.
+	charts map[string][]string
+}
+
+func newHelmChartsHook(ctx context.Context, fs filesys.FileSystem, helm *Helm, restrictor kustypes.LoadRestrictions) *helmChartsHook {
+	return &helmChartsHook{
+		fs:         fs,
+		ctx:        ctx,
+		helm:       helm,
+		restrictor: restrictor,
+		charts:     make(map[string][]string),
+	}
+}
+
+func (h *helmChartsHook) rewrite(k *kustomization) error {
+	if err := h.inflate(k); err != nil {
+		return fmt.Errorf("failed to inflate helm charts of %s: %w", k.path, err)
+	}
+
+	return nil
+}
+
+// sources returns the files each rendered chart has been rendered from relative to root.
+// It must not be called before the build is done.
+func (h *helmChartsHook) sources(root string) (map[string][]string, error) {
+	sources := make(map[string][]string)
+	for file, from := range h.charts {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range from {
+			relFrom, err := filepath.Rel(root, f)
+			if err != nil {
+				return nil, err
+			}
+
+			sources[rel] = append(sources[rel], relFrom)
+		}
+	}
+
+	return sources, nil
+}
+
+// inflate renders the helmCharts of the kustomization and rewrites the kustomization to reference the rendered charts
+// as resources instead.
+func (h *helmChartsHook) inflate(k *kustomization) error {
+	kus := k.kus
+	helmCharts := kus.HelmCharts
+	globals := kus.HelmGlobals
+	if len(kus.HelmChartInflationGenerator) > 0 {
+		legacyCharts, legacyGlobals := kustypes.SplitHelmParameters(kus.HelmChartInflationGenerator)
+		helmCharts = append(helmCharts, legacyCharts...)
+		if globals == nil {
+			globals = &legacyGlobals
+		}
+	}
+
+	if len(helmCharts) == 0 {
+		return nil
+	}
+
+	dir := k.dir
+	chartHome := kustypes.HelmDefaultHome
+	if globals != nil && globals.ChartHome != "" {
+		chartHome = globals.ChartHome
+	}
+
+	if !filepath.IsAbs(chartHome) {
+		chartHome = filepath.Join(dir, chartHome)
+	}
+
+	for _, field := range []string{"helmCharts", "helmGlobals", "helmChartInflationGenerator"} {
+		if err := k.node.PipeE(kyaml.Clear(field)); err != nil {
+			return err
+		}
+	}
+
+	resources, err := k.node.Pipe(kyaml.LookupCreate(kyaml.SequenceNode, "resources"))
+	if err != nil {
+		return err
+	}
+
+	for i, c := range helmCharts {
+		manifest, valuesFiles, err := h.inflateChart(dir, chartHome, c)
+		if err != nil {
+			return fmt.Errorf("chart %s: %w", c.Name, err)
+		}
+
+		name := fmt.Sprintf(".helmchart-%d-%s.yaml", i, c.Name)
+		file := filepath.Join(dir, name)
+		if err := h.fs.WriteFile(file, manifest); err != nil {
+			return err
+		}
+
+		h.charts[file] = append([]string{k.path}, valuesFiles...)
+		if err := resources.PipeE(kyaml.Append(kyaml.NewScalarRNode(name).YNode())); err != nil {
+			return err
+		}
+	}
+
+	k.modified = true
+	return nil
+}
+
+// inflateChart renders the chart like helm template with the arguments of the HelmChartInflationGenerator.
+// It returns the rendered manifests and the local values files they have been rendered with.
+func (h *helmChartsHook) inflateChart(dir, chartHome string, c kustypes.HelmChart) ([]byte, []string, error) {
+	if c.Name == "" {
+		return nil, nil, fmt.Errorf("chart name cannot be empty")
+	}
+
+	switch c.ValuesMerge {
+	case "":
+		c.ValuesMerge = valuesMergeOverride
+	case valuesMergeOverride, valuesMergeMerge, valuesMergeReplace:
+	default:
+		return nil, nil, fmt.Errorf("valuesMerge must be one of %v", []string{valuesMergeMerge, valuesMergeOverride, valuesMergeReplace})
+	}
+
+	if c.Version != "" && c.Repo != "" {
+		chartHome = filepath.Join(chartHome, fmt.Sprintf("%s-%s", c.Name, c.Version))
+	}
+
+	loadedChart, err := h.helm.inflationChart(h.ctx, c, chartHome)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var localFiles []string
+	var valuesFile []byte
+	if c.ValuesFile == "" {
+		// The values file defaults to the values of the chart
+		for _, f := range loadedChart.Raw {
+			if f.Name == chartutil.ValuesfileName {
+				valuesFile = f.Data
+			}
+		}
+	} else {
+		path, b, err := h.load(dir, c.ValuesFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		valuesFile = b
+		localFiles = append(localFiles, path)
+	}
+
+	if len(c.ValuesInline) > 0 {
+		if valuesFile, err = mergeInlineValues(valuesFile, c.ValuesInline, c.ValuesMerge); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	valuesFiles := [][]byte{valuesFile}
+	for _, file := range c.AdditionalValuesFiles {
+		path, b, err := h.load(dir, file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load additionalValuesFile: %w", err)
+		}
+
+		valuesFiles = append(valuesFiles, b)
+		localFiles = append(localFiles, path)
+	}
+
+	// The values files are merged like the values files passed to helm template
+	values := chartutil.Values{}
+	for _, b := range valuesFiles {
+		current, err := chartutil.ReadValues(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse values: %w", err)
+		}
+
+		values = transform.MergeMaps(values, current)
+	}
+
+	manifest, err := h.helm.renderTemplate(h.ctx, c, values, loadedChart)
+	return manifest, localFiles, err
+}
+
+// load reads the file relative to the kustomization directory, with the root only load restrictor the file must be
+// within the directory.
+func (h *helmChartsHook) load(dir, file string) (string, []byte, error) {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, file)
+	}
+
+	if h.restrictor == kustypes.LoadRestrictionsRootOnly {
+		if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, fmt.Errorf("security; file '%s' is not in or below '%s'", path, dir)
+		}
+	}
+
+	b, err := h.fs.ReadFile(path)
+	return path, b, err
+}
+
+// mergeInlineValues merges the inline values with the values file like kustomize, with override the inline values
+// take precedence, with merge the values file and with replace only the inline values are used.
+func mergeInlineValues(valuesFile []byte, inline map[string]interface{}, mode string) ([]byte, error) {
+	if mode == valuesMergeReplace {
+		return yaml.Marshal(inline)
+	}
+
+	fileValues, err := kyaml.Parse(string(valuesFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse values file into rnode: %w", err)
+	}
+
+	inlineValues, err := kyaml.FromMap(inline)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse values inline into rnode: %w", err)
+	}
+
+	var merged *kyaml.RNode
+	if mode == valuesMergeOverride {
+		merged, err = merge2.Merge(inlineValues, fileValues.Copy(), kyaml.MergeOptions{})
+	} else {
+		merged, err = merge2.Merge(fileValues, inlineValues.Copy(), kyaml.MergeOptions{})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not merge values: %w", err)
+	}
+
+	m, err := merged.Map()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse merged values into map: %w", err)
+	}
+
+	return yaml.Marshal(m)
+}
+
+// inflationChart loads the chart from the chart home if it has been pulled before like kustomize does,
+// otherwise it is pulled from its repository into the chart cache.
+func (h *Helm) inflationChart(ctx context.Context, c kustypes.HelmChart, chartHome string) (*helmchart.Chart, error) {
+	local := filepath.Join(chartHome, c.Name)
+	if stat, err := os.Stat(local); err == nil && stat.IsDir() {
+		loadedChart, err := loader.Load(local)
+		return loadedChart, withPhase(PhaseRender, err)
+	}
+
+	if c.Repo == "" {
+		return nil, withPhase(PhaseFetch, fmt.Errorf("no repo specified for pull, no chart found at '%s'", local))
+	}
+
+	repo := &sourcev1beta2.HelmRepository{
+		Spec: sourcev1beta2.HelmRepositorySpec{
+			URL: c.Repo,
+		},
+	}
+
+	if strings.HasPrefix(c.Repo, sourcev1beta2.OCIRepositoryPrefix) {
+		repo.Spec.Type = sourcev1beta2.HelmRepositoryTypeOCI
+	}
+
+	version := c.Version
+	if version == "" && c.Devel {
+		version = ">0.0.0-0"
+	}
+
+	obj := &sourcev1beta2.HelmChart{
+		Spec: sourcev1beta2.HelmChartSpec{
+			Chart:   c.Name,
+			Version: version,
+		},
+	}
+
+	b := &chart.Build{}
+	if err := h.buildFromHelmRepository(ctx, obj, repo, b, &charts.Chart{}, nil); err != nil {
+		return nil, withPhase(PhaseFetch, err)
+	}
+
+	loadedChart, err := loader.Load(b.Path)
+	return loadedChart, withPhase(PhaseRender, err)
+}
+
+// renderTemplate renders the chart like helm template, the objects are marked as generated by helm to exclude them
+// from the namespace transformer like the HelmChartInflationGenerator does.
+func (h *Helm) renderTemplate(ctx context.Context, c kustypes.HelmChart, values chartutil.Values, loadedChart *helmchart.Chart) ([]byte, error) {
+	cfg := &helmaction.Configuration{}
+	client := helmaction.NewInstall(cfg)
+	client.DryRun = true
+	client.Replace = true
+	client.ClientOnly = true
+	client.IncludeCRDs = c.IncludeCRDs
+	client.DisableHooks = c.SkipHooks
+	client.Devel = true
+
+	// helm template uses release-name unless a release name or name template is given
+	switch {
+	case c.ReleaseName != "" && c.NameTemplate != "":
+		return nil, withPhase(PhaseRender, fmt.Errorf("cannot set nameTemplate and also specify a releaseName"))
+	case c.ReleaseName != "":
+		client.ReleaseName = c.ReleaseName
+	case c.NameTemplate != "":
+		name, err := helmaction.TemplateName(c.NameTemplate)
+		if err != nil {
+			return nil, withPhase(PhaseRender, err)
+		}
+
+		client.ReleaseName = name
+	default:
+		client.ReleaseName = "release-name"
+	}
+
+	client.Namespace = c.Namespace
+	if client.Namespace == "" {
+		client.Namespace = "default"
+	}
+
+	client.KubeVersion = h.opts.KubeVersion
+	if c.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(c.KubeVersion)
+		if err != nil {
+			return nil, withPhase(PhaseRender, err)
+		}
+
+		client.KubeVersion = kubeVersion
+	}
+
+	apiVersions := chartutil.DefaultVersionSet
+	apiVersions = append(apiVersions, h.opts.APIVersions...)
+	apiVersions = append(apiVersions, c.ApiVersions...)
+	client.APIVersions = apiVersions
+
+	rel, err := client.RunWithContext(ctx, loadedChart, values)
+	if err != nil {
+		return nil, withPhase(PhaseRender, err)
+	}
+
+	var manifests bytes.Buffer
+	fmt.Fprintln(&manifests, strings.TrimSpace(rel.Manifest))
+	if !c.SkipHooks {
+		for _, hook := range rel.Hooks {
+			if c.SkipTests && isTestHook(hook) {
+				continue
+			}
+
+			fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+		}
+	}
+
+	return markHelmGenerated(manifests.Bytes())
+}
+
+func isTestHook(h *release.Hook) bool {
+	for _, e := range h.Events {
+		if e == release.HookTest {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markHelmGenerated sets the helm generated annotation on all objects of the manifests.
+func markHelmGenerated(manifests []byte) ([]byte, error) {
+	nodes, err := kio.FromBytes(manifests)
+	if err != nil {
+		return nil, withPhase(PhaseRender, fmt.Errorf("error reading helm output: %w", err))
+	}
+
+	for _, node := range nodes {
+		if err := node.PipeE(kyaml.SetAnnotation(konfig.HelmGeneratedAnnotation, "true")); err != nil {
+			return nil, withPhase(PhaseRender, fmt.Errorf("failed to set helm annotation: %w", err))
+		}
+	}
+
+	s, err := kio.StringAll(nodes)
+	return []byte(s), err
+}
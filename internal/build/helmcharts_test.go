@@ -0,0 +1,226 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+)
+
+var chartFiles = map[string]string{
+	"charts/app/Chart.yaml":  "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+	"charts/app/values.yaml": "replicas: 1\nimage: nginx\nport: 80\n",
+	"charts/app/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-app
+data:
+  replicas: {{ .Values.replicas | quote }}
+  image: {{ .Values.image }}
+  port: {{ .Values.port | quote }}
+  namespace: {{ .Release.Namespace }}
+`,
+	"charts/app/templates/hook.yaml": `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-migrate
+  annotations:
+    helm.sh/hook: pre-install
+`,
+	"charts/app/templates/test.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+`,
+	"charts/app/crds/crd.yaml": `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: apps.example.com
+`,
+}
+
+func TestKustomizeHelmCharts(t *testing.T) {
+	tests := []struct {
+		name          string
+		kustomization string
+		files         map[string]string
+		chartHome     string
+		expected      []string
+		data          map[string]string
+		inputs        []string
+	}{
+		{
+			name:          "chart defaults",
+			kustomization: "namespace: other\nhelmCharts:\n- name: app\n",
+			expected:      []string{"ConfigMap/release-name-app", "Job/release-name-migrate", "Pod/release-name-test"},
+			data:          map[string]string{"replicas": "1", "image": "nginx", "port": "80", "namespace": "default"},
+			inputs:        []string{"kustomization.yaml"},
+		},
+		{
+			name:          "inline values override the values file",
+			kustomization: "helmCharts:\n- name: app\n  releaseName: demo\n  namespace: apps\n  valuesFile: values.yaml\n  valuesInline:\n    replicas: 3\n",
+			files:         map[string]string{"values.yaml": "replicas: 2\nimage: custom\n"},
+			expected:      []string{"ConfigMap/demo-app", "Job/demo-migrate", "Pod/demo-test"},
+			data:          map[string]string{"replicas": "3", "image": "custom", "port": "80", "namespace": "apps"},
+			inputs:        []string{"kustomization.yaml", "values.yaml"},
+		},
+		{
+			name:          "values file takes precedence with merge",
+			kustomization: "helmCharts:\n- name: app\n  releaseName: demo\n  valuesFile: values.yaml\n  valuesMerge: merge\n  valuesInline:\n    replicas: 3\n    port: 8080\n",
+			files:         map[string]string{"values.yaml": "replicas: 2\n"},
+			expected:      []string{"ConfigMap/demo-app", "Job/demo-migrate", "Pod/demo-test"},
+			data:          map[string]string{"replicas": "2", "image": "nginx", "port": "8080", "namespace": "default"},
+			inputs:        []string{"kustomization.yaml", "values.yaml"},
+		},
+		{
+			name:          "inline values replace the values file",
+			kustomization: "helmCharts:\n- name: app\n  releaseName: demo\n  valuesFile: values.yaml\n  valuesMerge: replace\n  valuesInline:\n    port: 8080\n",
+			files:         map[string]string{"values.yaml": "replicas: 2\n"},
+			expected:      []string{"ConfigMap/demo-app", "Job/demo-migrate", "Pod/demo-test"},
+			data:          map[string]string{"replicas": "1", "image": "nginx", "port": "8080", "namespace": "default"},
+			inputs:        []string{"kustomization.yaml", "values.yaml"},
+		},
+		{
+			name:          "additional values files",
+			kustomization: "helmCharts:\n- name: app\n  releaseName: demo\n  valuesInline:\n    replicas: 3\n  additionalValuesFiles:\n  - env/prod.yaml\n",
+			files:         map[string]string{"env/prod.yaml": "image: prod\n"},
+			expected:      []string{"ConfigMap/demo-app", "Job/demo-migrate", "Pod/demo-test"},
+			data:          map[string]string{"replicas": "3", "image": "prod", "port": "80", "namespace": "default"},
+			inputs:        []string{"kustomization.yaml", "env/prod.yaml"},
+		},
+		{
+			name:          "crds without tests",
+			kustomization: "helmCharts:\n- name: app\n  releaseName: demo\n  includeCRDs: true\n  skipTests: true\n",
+			expected:      []string{"CustomResourceDefinition/apps.example.com", "ConfigMap/demo-app", "Job/demo-migrate"},
+			data:          map[string]string{"replicas": "1", "image": "nginx", "port": "80", "namespace": "default"},
+			inputs:        []string{"kustomization.yaml"},
+		},
+		{
+			name:          "without hooks in a custom chart home",
+			kustomization: "helmGlobals:\n  chartHome: vendor\nhelmCharts:\n- name: app\n  releaseName: demo\n  skipHooks: true\n",
+			chartHome:     "vendor",
+			expected:      []string{"ConfigMap/demo-app"},
+			data:          map[string]string{"replicas": "1", "image": "nginx", "port": "80", "namespace": "default"},
+			inputs:        []string{"kustomization.yaml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			files := map[string]string{"kustomization.yaml": test.kustomization}
+			for name, content := range test.files {
+				files[name] = content
+			}
+
+			for name, content := range chartFiles {
+				if test.chartHome != "" {
+					name = filepath.Join(test.chartHome, strings.TrimPrefix(name, "charts/"))
+				}
+
+				files[name] = content
+			}
+
+			for name, content := range files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			opts := KustomizeOpts{Helm: NewHelmBuilder(logr.Discard(), HelmOpts{})}
+			index, origins, inputs, err := KustomizeWithInputs(context.TODO(), dir, opts)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, res := range index.Resources() {
+				names = append(names, res.GetKind()+"/"+res.GetName())
+				g.Expect(res.GetNamespace()).To(BeEmpty())
+				g.Expect(res.GetAnnotations()).ToNot(HaveKey(konfig.HelmGeneratedAnnotation))
+				g.Expect(origins[res.CurId()]).To(Equal(filepath.Join(dir, "kustomization.yaml")))
+
+				var expectedInputs []string
+				for _, input := range test.inputs {
+					expectedInputs = append(expectedInputs, filepath.Join(dir, input))
+				}
+
+				g.Expect(inputs[res.CurId()]).To(Equal(expectedInputs))
+				if res.GetKind() == "ConfigMap" {
+					g.Expect(res.GetDataMap()).To(Equal(test.data))
+				}
+			}
+
+			g.Expect(names).To(ConsistOf(test.expected))
+
+			// The source tree must not be modified
+			entries, err := os.ReadDir(dir)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(len(entries)).To(Equal(len(topLevel(files))))
+		})
+	}
+}
+
+func TestKustomizeHelmChartsErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		kustomization string
+		opts          KustomizeOpts
+		err           string
+	}{
+		{
+			name:          "helm disabled",
+			kustomization: "helmCharts:\n- name: app\n",
+			err:           "must specify --enable-helm",
+		},
+		{
+			name:          "chart not found",
+			kustomization: "helmCharts:\n- name: missing\n",
+			opts:          KustomizeOpts{Helm: NewHelmBuilder(logr.Discard(), HelmOpts{})},
+			err:           "no repo specified for pull, no chart found at",
+		},
+		{
+			name:          "values file outside of the kustomization",
+			kustomization: "helmCharts:\n- name: app\n  valuesFile: ../values.yaml\n",
+			opts:          KustomizeOpts{Helm: NewHelmBuilder(logr.Discard(), HelmOpts{}), LoadRestrictor: kustypes.LoadRestrictionsRootOnly},
+			err:           "is not in or below",
+		},
+		{
+			name:          "invalid values merge",
+			kustomization: "helmCharts:\n- name: app\n  valuesMerge: unknown\n  valuesInline:\n    replicas: 3\n",
+			opts:          KustomizeOpts{Helm: NewHelmBuilder(logr.Discard(), HelmOpts{})},
+			err:           "valuesMerge must be one of",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			root := t.TempDir()
+			dir := filepath.Join(root, "app")
+			files := map[string]string{"kustomization.yaml": test.kustomization}
+			for name, content := range chartFiles {
+				files[name] = content
+			}
+
+			for name, content := range files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			g.Expect(os.WriteFile(filepath.Join(root, "values.yaml"), []byte("replicas: 2\n"), 0644)).To(Succeed())
+
+			_, err := Kustomize(context.TODO(), dir, test.opts)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(test.err))
+		})
+	}
+}
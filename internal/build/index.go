@@ -1,6 +1,8 @@
 package build
 
 import (
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/kustomize/api/resource"
 )
@@ -34,3 +36,97 @@ type ref struct {
 	Name      string
 	Namespace string
 }
+
+// AffectedReleases returns the HelmReleases of the index which are new or changed compared to old,
+// including HelmReleases whose chart source or referenced values changed.
+func (r ResourceIndex) AffectedReleases(old ResourceIndex) ([]*resource.Resource, error) {
+	var affected []*resource.Resource
+	for id, res := range r {
+		if id.Kind != helmv2.HelmReleaseKind {
+			continue
+		}
+
+		refs := append([]ref{id}, releaseRefs(res)...)
+		for _, dep := range refs {
+			changed, err := changedResource(old[dep], r[dep])
+			if err != nil {
+				return nil, err
+			}
+
+			if changed {
+				affected = append(affected, res)
+				break
+			}
+		}
+	}
+
+	return affected, nil
+}
+
+// ReleaseDependencies returns the objects of the index the HelmRelease is built from: the HelmRelease itself,
+// its chart source and its valuesFrom ConfigMaps and Secrets. Charts are only built from HelmRepositories,
+// there are no local chart directories to depend on.
+func (r ResourceIndex) ReleaseDependencies(res *resource.Resource) []*resource.Resource {
+	dependencies := []*resource.Resource{res}
+	for _, dep := range releaseRefs(res) {
+		if obj, ok := r[dep]; ok {
+			dependencies = append(dependencies, obj)
+		}
+	}
+
+	return dependencies
+}
+
+// releaseRefs returns the references of a HelmRelease to its chart source and values.
+func releaseRefs(res *resource.Resource) []ref {
+	var refs []ref
+	if kind, _ := res.GetString("spec.chart.spec.sourceRef.kind"); kind != "" {
+		name, _ := res.GetString("spec.chart.spec.sourceRef.name")
+		namespace, _ := res.GetString("spec.chart.spec.sourceRef.namespace")
+		if namespace == "" {
+			namespace = res.GetNamespace()
+		}
+
+		refs = append(refs, ref{
+			GroupKind: schema.GroupKind{Group: sourcev1beta2.GroupVersion.Group, Kind: kind},
+			Name:      name,
+			Namespace: namespace,
+		})
+	}
+
+	valuesFrom, _ := res.GetSlice("spec.valuesFrom")
+	for _, v := range valuesFrom {
+		v, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		kind, _ := v["kind"].(string)
+		name, _ := v["name"].(string)
+		refs = append(refs, ref{
+			GroupKind: schema.GroupKind{Kind: kind},
+			Name:      name,
+			Namespace: res.GetNamespace(),
+		})
+	}
+
+	return refs
+}
+
+func changedResource(old, res *resource.Resource) (bool, error) {
+	if old == nil || res == nil {
+		return old != res, nil
+	}
+
+	a, err := old.AsYAML()
+	if err != nil {
+		return false, err
+	}
+
+	b, err := res.AsYAML()
+	if err != nil {
+		return false, err
+	}
+
+	return string(a) != string(b), nil
+}
@@ -0,0 +1,153 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+const releaseManifests = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: app
+      sourceRef:
+        kind: HelmRepository
+        name: apps
+        namespace: repos
+  valuesFrom:
+  - kind: ConfigMap
+    name: values
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: other
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: other
+      sourceRef:
+        kind: HelmRepository
+        name: other
+        namespace: repos
+---
+apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: HelmRepository
+metadata:
+  name: apps
+  namespace: repos
+spec:
+  url: https://charts.example.com
+---
+apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: HelmRepository
+metadata:
+  name: other
+  namespace: repos
+spec:
+  url: https://other.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: values
+  namespace: apps
+data:
+  values.yaml: "replicas: 1"
+`
+
+func index(t *testing.T, manifests string) ResourceIndex {
+	resMap, err := resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory()).NewResMapFromBytes([]byte(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := make(ResourceIndex)
+	if err := db.Push(resMap.Resources()); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestAffectedReleases(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		expected []string
+	}{
+		{
+			name:     "all releases are new",
+			old:      "",
+			expected: []string{"app", "other"},
+		},
+		{
+			name:     "nothing changed",
+			old:      releaseManifests,
+			expected: nil,
+		},
+		{
+			name:     "referenced values changed",
+			old:      strings.Replace(releaseManifests, "replicas: 1", "replicas: 2", 1),
+			expected: []string{"app"},
+		},
+		{
+			name:     "chart source changed",
+			old:      strings.Replace(releaseManifests, "https://other.example.com", "https://charts.other.example.com", 1),
+			expected: []string{"other"},
+		},
+		{
+			name:     "release changed",
+			old:      strings.Replace(releaseManifests, "chart: app", "chart: app-v2", 1),
+			expected: []string{"app"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := make(ResourceIndex)
+			if test.old != "" {
+				old = index(t, test.old)
+			}
+
+			affected, err := index(t, releaseManifests).AffectedReleases(old)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, res := range affected {
+				names = append(names, res.GetName())
+			}
+
+			g.Expect(names).To(ConsistOf(test.expected))
+		})
+	}
+}
+
+func TestReleaseDependencies(t *testing.T) {
+	g := NewWithT(t)
+
+	db := index(t, releaseManifests)
+	for _, res := range db {
+		if res.GetKind() != "HelmRelease" || res.GetName() != "app" {
+			continue
+		}
+
+		var names []string
+		for _, dep := range db.ReleaseDependencies(res) {
+			names = append(names, dep.GetKind()+"/"+dep.GetName())
+		}
+
+		g.Expect(names).To(Equal([]string{"HelmRelease/app", "HelmRepository/apps", "ConfigMap/values"}))
+	}
+}
@@ -0,0 +1,109 @@
+package build
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// kustomization is a kustomization file passed through the hooks of a kustomizationFS.
+type kustomization struct {
+	path string
+	dir  string
+	// kus is the kustomization as read from the file, rewrites of node are not reflected.
+	kus  kustypes.Kustomization
+	node *kyaml.RNode
+	// modified is set by hooks which rewrite node.
+	modified bool
+}
+
+// kustomizationHook checks or rewrites a kustomization before kustomize reads it.
+// An error rejects the kustomization.
+type kustomizationHook interface {
+	rewrite(k *kustomization) error
+}
+
+// kustomizationFS passes the kustomization files kustomize reads through the hooks in order. Each file is parsed once
+// for all hooks and the rewritten kustomization is kept, kustomizations are read again by repeated builds.
+// The hooks are run one at a time.
+type kustomizationFS struct {
+	filesys.FileSystem
+	hooks     []kustomizationHook
+	mu        sync.Mutex
+	rewritten map[string][]byte
+	// err is the last rejected kustomization, kustomize reports kustomization files which can not be read as missing.
+	err error
+}
+
+func newKustomizationFS(fs filesys.FileSystem, hooks ...kustomizationHook) *kustomizationFS {
+	return &kustomizationFS{
+		FileSystem: fs,
+		hooks:      hooks,
+		rewritten:  make(map[string][]byte),
+	}
+}
+
+func (fs *kustomizationFS) ReadFile(path string) ([]byte, error) {
+	b, err := fs.FileSystem.ReadFile(path)
+	if err != nil || !isKustomizationFile(path) || len(fs.hooks) == 0 {
+		return b, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if rewritten, ok := fs.rewritten[path]; ok {
+		return rewritten, nil
+	}
+
+	k := &kustomization{path: path, dir: filepath.Dir(path)}
+
+	// Let kustomize report invalid kustomization files
+	if err := yaml.Unmarshal(b, &k.kus); err != nil {
+		return b, nil
+	}
+
+	if k.node, err = kyaml.Parse(string(b)); err != nil {
+		return b, nil
+	}
+
+	for _, hook := range fs.hooks {
+		if err := hook.rewrite(k); err != nil {
+			// A custom schema only aborts the concurrent build
+			if !errors.Is(err, errCustomSchema) {
+				fs.err = err
+			}
+
+			return nil, err
+		}
+	}
+
+	if k.modified {
+		s, err := k.node.String()
+		if err != nil {
+			return b, nil
+		}
+
+		b = []byte(s)
+	}
+
+	fs.rewritten[path] = b
+	return b, nil
+}
+
+// buildErr returns the error of the last rejected kustomization instead of the error of the failed build.
+func (fs *kustomizationFS) buildErr(err error) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err != nil && fs.err != nil {
+		return fs.err
+	}
+
+	return err
+}
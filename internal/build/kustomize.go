@@ -2,12 +2,16 @@ package build
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
+	"github.com/doodlescheduling/flux-build/internal/remote"
+	"github.com/doodlescheduling/flux-build/internal/sourceignore"
 	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/provider"
@@ -15,93 +19,307 @@ import (
 	"sigs.k8s.io/kustomize/api/resource"
 	kustypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
 	"sigs.k8s.io/yaml"
 )
 
-var kustomizeBuildMutex sync.Mutex
+// openAPISchemaLock guards the OpenAPI schema which kustomize keeps in a global.
+// Builds run concurrently unless a kustomization declares a custom schema, those builds run exclusively.
+var openAPISchemaLock sync.RWMutex
+
+// errCustomSchema aborts a concurrent build which reads a kustomization declaring a custom OpenAPI schema.
+var errCustomSchema = errors.New("kustomization declares a custom openapi schema")
+
+// KustomizeOpts are the options of a kustomize build.
+type KustomizeOpts struct {
+	// Ignore are additional patterns of files excluded from the resources detected for paths without a
+	// kustomization, like the spec.ignore of a GitRepository.
+	Ignore []string
+	// LoadRestrictor restricts the files a kustomization may load, defaults to none like the kustomize-controller.
+	LoadRestrictor kustypes.LoadRestrictions
+	// Helm inflates the helmCharts of kustomizations with the chart cache and renderer of HelmReleases if set,
+	// kustomizations declaring helmCharts fail otherwise.
+	Helm *Helm
+	// ExecFunctions enables the alpha exec KRM functions whose executable matches one of the glob patterns.
+	// Other plugins apart from the builtin plugins are not allowed.
+	ExecFunctions []string
+	// Remotes resolves remote bases and files of kustomizations to local mirrors or vendored copies if set.
+	Remotes *remote.Resolver
+}
 
-func Kustomize(ctx context.Context, path string) (resmap.ResMap, error) {
-	kfile := filepath.Join(path, konfig.DefaultKustomizationFileName())
-	fs := filesys.MakeFsOnDisk()
-	pvd := provider.NewDefaultDepProvider()
-	singleFile := false
+// ParseLoadRestrictor parses the load restrictor of kustomize builds, one of none or rootOnly.
+func ParseLoadRestrictor(s string) (kustypes.LoadRestrictions, error) {
+	switch s {
+	case "", "none", kustypes.LoadRestrictionsNone.String():
+		return kustypes.LoadRestrictionsNone, nil
+	case "rootOnly", kustypes.LoadRestrictionsRootOnly.String():
+		return kustypes.LoadRestrictionsRootOnly, nil
+	}
 
-	_, err := os.Stat(kfile)
-	if err != nil {
-		stat, err := os.Stat(path)
-		if err != nil {
-			return nil, err
-		}
+	return kustypes.LoadRestrictionsUnknown, fmt.Errorf("unsupported load restrictor %q, expected one of none, rootOnly", s)
+}
+
+func Kustomize(ctx context.Context, path string, opts KustomizeOpts) (resmap.ResMap, error) {
+	index, _, _, err := kustomize(ctx, path, opts, false)
+	return index, err
+}
+
+// KustomizeWithOrigins builds the path and additionally returns the local file each object was declared in.
+func KustomizeWithOrigins(ctx context.Context, path string, opts KustomizeOpts) (resmap.ResMap, Origins, error) {
+	index, origins, _, err := kustomize(ctx, path, opts, true)
+	return index, origins, err
+}
 
-		if path == "/dev/stdin" || path == "-" {
-			singleFile = true
-			d, err := os.MkdirTemp(os.TempDir(), "")
+// KustomizeWithInputs builds the path like KustomizeWithOrigins and additionally returns the local files
+// each object has been built from.
+func KustomizeWithInputs(ctx context.Context, path string, opts KustomizeOpts) (resmap.ResMap, Origins, Inputs, error) {
+	return kustomize(ctx, path, opts, true)
+}
+
+func kustomize(ctx context.Context, path string, opts KustomizeOpts, trackOrigins bool) (resmap.ResMap, Origins, Inputs, error) {
+	var fs filesys.FileSystem = filesys.MakeFsOnDisk()
+	originRoot := path
+
+	// Paths without a kustomization are built with a kustomization generated in memory, the disk is never modified
+	if !hasKustomization(fs, path) {
+		singleFile := true
+		var filter *sourceignore.Filter
+		switch stat, err := os.Stat(path); {
+		case path == "/dev/stdin" || path == "-":
+			originRoot = ""
+			b, err := io.ReadAll(os.Stdin)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
 
-			f, err := os.OpenFile(filepath.Join(d, "stdin.yaml"), os.O_CREATE|os.O_RDWR, 0644)
+			fs, path, err = singleFileFS("stdin.yaml", b)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
-
-			_, err = io.Copy(f, os.Stdin)
+		case err != nil:
+			return nil, nil, nil, err
+		case !stat.IsDir():
+			originRoot = filepath.Dir(path)
+			b, err := os.ReadFile(path)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
 
-			path = d
-
-			defer func() {
-				_ = os.RemoveAll(d)
-			}()
-		} else if !stat.IsDir() {
-			singleFile = true
-			d, err := os.MkdirTemp(os.TempDir(), "")
+			fs, path, err = singleFileFS(filepath.Base(path), b)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
-
-			fullPath, err := filepath.Abs(path)
+		default:
+			singleFile = false
+			overlay := newOverlayFS()
+			root, _, err := overlay.CleanedAbs(path)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
 
-			if err := os.Symlink(fullPath, filepath.Join(d, filepath.Base(path))); err != nil {
-				return nil, err
+			// The kustomization is looked up within the cleaned root by kustomize
+			fs, path = overlay, root.String()
+
+			// Detect only the files the source-controller includes in the artifact
+			filter, err = sourceignore.NewFilter(path, opts.Ignore)
+			if err != nil {
+				return nil, nil, nil, err
 			}
+		}
 
-			path = d
+		if err := createKustomization(path, fs, provider.NewDefaultDepProvider().GetResourceFactory(), singleFile, filter); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed create kustomization: %w", err)
+		}
+	} else if opts.Helm != nil {
+		// Inflated charts are written into memory, the disk is never modified
+		fs = newOverlayFS()
+	}
 
-			defer func() {
-				_ = os.RemoveAll(d)
-			}()
+	// Plugins are rejected before any remote is resolved or chart inflated
+	var hooks []kustomizationHook
+	if len(opts.ExecFunctions) > 0 {
+		functions, err := newFunctionsHook(fs, opts.ExecFunctions)
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
-		defer func() {
-			_ = os.Remove(kfile)
-		}()
+		hooks = append(hooks, functions)
+	}
+
+	if opts.Remotes != nil {
+		hooks = append(hooks, &remotesHook{fs: fs, resolver: opts.Remotes})
+	}
+
+	var helmCharts *helmChartsHook
+	if opts.Helm != nil {
+		helmCharts = newHelmChartsHook(ctx, fs, opts.Helm, opts.LoadRestrictor)
+		hooks = append(hooks, helmCharts)
+	}
 
-		err = createKustomization(path, fs, pvd.GetResourceFactory(), singleFile)
+	var origin *originHook
+	if trackOrigins && originRoot != "" {
+		root, _, err := fs.CleanedAbs(path)
 		if err != nil {
-			return nil, fmt.Errorf("failed create kustomization: %w", err)
+			return nil, nil, nil, err
+		}
+
+		origin = &originHook{root: root.String()}
+		hooks = append(hooks, origin)
+	}
+
+	index, err := build(fs, path, opts, hooks...)
+	if err != nil || origin == nil {
+		return index, nil, nil, err
+	}
+
+	var charts map[string][]string
+	if helmCharts != nil {
+		if charts, err = helmCharts.sources(origin.root); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	origins, err := collectOrigins(index, originRoot, charts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	inputs, err := collectInputs(index, originRoot, charts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Do not leak the origin annotations into the output unless they have been configured
+	if origin.injected {
+		if err := index.RemoveOriginAnnotations(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return index, origins, inputs, nil
+}
+
+// hasKustomization returns true if the path is a directory containing a kustomization file.
+func hasKustomization(fs filesys.FileSystem, path string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if fs.Exists(filepath.Join(path, name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// singleFileFS returns an in-memory filesystem with the file at its root.
+func singleFileFS(name string, b []byte) (filesys.FileSystem, string, error) {
+	fs := filesys.MakeFsInMemory()
+	return fs, filesys.Separator, fs.WriteFile(filepath.Join(filesys.Separator, name), b)
+}
+
+// KustomizeManifests builds the manifests by their file name within an in-memory filesystem.
+// Files which do not contain Kubernetes objects are ignored.
+func KustomizeManifests(manifests map[string][]byte) (resmap.ResMap, error) {
+	const dir = "/manifests"
+	fs := filesys.MakeFsInMemory()
+	if err := fs.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	for name, manifest := range manifests {
+		if err := fs.WriteFile(filepath.Join(dir, name), manifest); err != nil {
+			return nil, err
 		}
 	}
 
+	if err := createKustomization(dir, fs, provider.NewDefaultDepProvider().GetResourceFactory(), false, nil); err != nil {
+		return nil, fmt.Errorf("failed create kustomization: %w", err)
+	}
+
+	return build(fs, dir, KustomizeOpts{})
+}
+
+// buildPostRenderer builds the kustomization of a kustomize post renderer, it shares the OpenAPI schema with
+// concurrent builds.
+func buildPostRenderer(fs filesys.FileSystem, path string) (resmap.ResMap, error) {
+	return build(fs, path, KustomizeOpts{})
+}
+
+// build runs kustomize for the path with the kustomizations passed through the hooks. Concurrent builds share the
+// OpenAPI schema, a build reading a kustomization which declares a custom schema is aborted and repeated exclusively.
+// The error of a rejected kustomization is returned instead of the error of the failed build.
+func build(fs filesys.FileSystem, path string, opts KustomizeOpts, hooks ...kustomizationHook) (resmap.ResMap, error) {
+	schema := &schemaHook{}
+	kfs := newKustomizationFS(fs, append([]kustomizationHook{schema}, hooks...)...)
+	index, err := runKustomizer(kfs, path, opts, false)
+	if !schema.custom.Load() {
+		return index, kfs.buildErr(err)
+	}
+
+	schema.allow.Store(true)
+	index, err = runKustomizer(kfs, path, opts, true)
+	return index, kfs.buildErr(err)
+}
+
+func runKustomizer(fs filesys.FileSystem, path string, opts KustomizeOpts, exclusive bool) (resmap.ResMap, error) {
+	if exclusive {
+		openAPISchemaLock.Lock()
+		defer func() {
+			// Do not leak the custom schema into the following builds
+			openapi.ResetOpenAPI()
+			openAPISchemaLock.Unlock()
+		}()
+	} else {
+		openAPISchemaLock.RLock()
+		defer openAPISchemaLock.RUnlock()
+	}
+
 	buildOptions := &krusty.Options{
-		LoadRestrictions:  kustypes.LoadRestrictionsNone,
+		LoadRestrictions:  opts.LoadRestrictor,
 		AddManagedbyLabel: false,
 		PluginConfig:      krusty.MakeDefaultOptions().PluginConfig,
 	}
 
-	kustomizeBuildMutex.Lock()
-	defer kustomizeBuildMutex.Unlock()
+	if buildOptions.LoadRestrictions == kustypes.LoadRestrictionsUnknown {
+		buildOptions.LoadRestrictions = kustypes.LoadRestrictionsNone
+	}
+
+	if len(opts.ExecFunctions) > 0 {
+		// Plugins are restricted to the allowed exec functions by the functionsFS
+		buildOptions.PluginConfig = kustypes.MakePluginConfig(kustypes.PluginRestrictionsNone, kustypes.BploUseStaticallyLinked)
+		buildOptions.PluginConfig.FnpLoadingOptions.EnableExec = true
+	}
 
 	kustomizer := krusty.MakeKustomizer(buildOptions)
 	return kustomizer.Run(fs, path)
 }
 
-func createKustomization(path string, fSys filesys.FileSystem, rf *resource.Factory, singleFile bool) error {
+// schemaHook rejects kustomizations which declare a custom OpenAPI schema unless the build runs exclusively.
+type schemaHook struct {
+	// allow is set once the build runs exclusively.
+	allow  atomic.Bool
+	custom atomic.Bool
+}
+
+func (h *schemaHook) rewrite(k *kustomization) error {
+	if len(k.kus.OpenAPI) == 0 || h.allow.Load() {
+		return nil
+	}
+
+	h.custom.Store(true)
+	return errCustomSchema
+}
+
+func isKustomizationFile(path string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if filepath.Base(path) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func createKustomization(path string, fSys filesys.FileSystem, rf *resource.Factory, singleFile bool, filter *sourceignore.Filter) error {
 	kfile := filepath.Join(path, konfig.DefaultKustomizationFileName())
 	kus := kustypes.Kustomization{
 		TypeMeta: kustypes.TypeMeta{
@@ -110,7 +328,7 @@ func createKustomization(path string, fSys filesys.FileSystem, rf *resource.Fact
 		},
 	}
 
-	detected, err := detectResources(fSys, rf, path, singleFile)
+	detected, err := detectResources(fSys, rf, path, singleFile, filter)
 	if err != nil {
 		return err
 	}
@@ -122,10 +340,12 @@ func createKustomization(path string, fSys filesys.FileSystem, rf *resource.Fact
 		return err
 	}
 
-	return os.WriteFile(kfile, kd, os.ModePerm)
+	return fSys.WriteFile(kfile, kd)
 }
 
-func detectResources(fSys filesys.FileSystem, rf *resource.Factory, base string, singleFile bool) ([]string, error) {
+// detectResources returns the files and directories with a kustomization within base, files and directories
+// ignored by the filter are skipped.
+func detectResources(fSys filesys.FileSystem, rf *resource.Factory, base string, singleFile bool, filter *sourceignore.Filter) ([]string, error) {
 	var paths []string
 
 	err := fSys.Walk(base, func(path string, info os.FileInfo, err error) error {
@@ -142,6 +362,14 @@ func detectResources(fSys filesys.FileSystem, rf *resource.Factory, base string,
 			return nil
 		}
 
+		if filter != nil && filter.Ignore(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
 		if info.IsDir() {
 			// If a sub-directory contains an existing kustomization file add the
 			// directory as a resource and do not decend into it.
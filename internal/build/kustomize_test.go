@@ -0,0 +1,377 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alitto/pond/v2"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	kustomizeapi "github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+)
+
+func TestKustomizeGeneratedKustomization(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string
+		ignore   []string
+		expected []string
+		origin   string
+	}{
+		{
+			name: "directory",
+			files: map[string]string{
+				"configmaps.yaml":        originManifests,
+				"sub/secret.yaml":        "apiVersion: v1\nkind: Secret\nmetadata:\n  name: c\n",
+				"README.md":              "# configmaps",
+				"base/configmap.yml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n",
+				"base/kustomization.yml": "resources:\n- configmap.yml\n",
+			},
+			expected: []string{"ConfigMap/a", "ConfigMap/b", "Secret/c", "ConfigMap/d"},
+			origin:   "configmaps.yaml",
+		},
+		{
+			name: "default ignore patterns",
+			files: map[string]string{
+				"configmaps.yaml":        originManifests,
+				".github/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n",
+				"sub/.flux.yaml":         "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n",
+			},
+			expected: []string{"ConfigMap/a", "ConfigMap/b"},
+			origin:   "configmaps.yaml",
+		},
+		{
+			name: "sourceignore",
+			files: map[string]string{
+				".sourceignore":         "/tests/\n",
+				"configmaps.yaml":       originManifests,
+				"tests/configmap.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n",
+				"sub/tests/secret.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: c\n",
+				"sub/skip.yaml":         "apiVersion: v1\nkind: Secret\nmetadata:\n  name: d\n",
+			},
+			ignore:   []string{"skip.yaml"},
+			expected: []string{"ConfigMap/a", "ConfigMap/b", "Secret/c"},
+			origin:   "configmaps.yaml",
+		},
+		{
+			name: "single file",
+			files: map[string]string{
+				"configmaps.yaml": originManifests,
+				"other.yaml":      "apiVersion: v1\nkind: Secret\nmetadata:\n  name: c\n",
+			},
+			path:     "configmaps.yaml",
+			expected: []string{"ConfigMap/a", "ConfigMap/b"},
+			origin:   "configmaps.yaml",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			for name, content := range test.files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			index, origins, err := KustomizeWithOrigins(context.TODO(), filepath.Join(dir, test.path), KustomizeOpts{Ignore: test.ignore})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, res := range index.Resources() {
+				names = append(names, res.GetKind()+"/"+res.GetName())
+			}
+
+			g.Expect(names).To(ConsistOf(test.expected))
+			g.Expect(origins).To(ContainElement(filepath.Join(dir, test.origin)))
+
+			// The source tree must not be modified
+			entries, err := os.ReadDir(dir)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(len(entries)).To(Equal(len(topLevel(test.files))))
+			for _, entry := range entries {
+				g.Expect(entry.Name()).ToNot(HavePrefix("kustomization"))
+			}
+		})
+	}
+}
+
+// topLevel returns the top level entries of the files.
+func topLevel(files map[string]string) map[string]bool {
+	entries := make(map[string]bool)
+	for name := range files {
+		entries[strings.Split(name, "/")[0]] = true
+	}
+
+	return entries
+}
+
+func TestKustomizeManifests(t *testing.T) {
+	g := NewWithT(t)
+
+	index, err := KustomizeManifests(map[string][]byte{
+		"manifest.yaml": []byte(originManifests),
+		"hook_0.yaml":   []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n"),
+		"NOTES.txt":     []byte("not a manifest"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var names []string
+	for _, res := range index.Resources() {
+		names = append(names, res.GetKind()+"/"+res.GetName())
+	}
+
+	g.Expect(names).To(ConsistOf("ConfigMap/a", "ConfigMap/b", "Job/migrate"))
+}
+
+func TestKustomizeConcurrentCustomSchema(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "configmaps.yaml"), []byte(originManifests), 0644)).To(Succeed())
+	kustomization := "openapi:\n  version: v1.21.2\nresources:\n- configmaps.yaml\n"
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644)).To(Succeed())
+
+	hr := helmv2.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec: helmv2.HelmReleaseSpec{
+			PostRenderers: []helmv2.PostRenderer{
+				{
+					Kustomize: &helmv2.Kustomize{
+						Patches: []kustomizeapi.Patch{
+							{
+								Patch:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  labels:\n    patched: \"true\"\n",
+								Target: &kustomizeapi.Selector{Kind: "ConfigMap", Name: "a"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	renderer, err := NewHelmBuilder(logr.Discard(), HelmOpts{}).postRenderers(hr)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := Kustomize(context.TODO(), dir, KustomizeOpts{})
+			errs <- err
+		}()
+
+		go func() {
+			defer wg.Done()
+			_, err := KustomizeManifests(map[string][]byte{"manifest.yaml": []byte(originManifests)})
+			errs <- err
+		}()
+
+		go func() {
+			defer wg.Done()
+			manifests, err := renderer.Run(bytes.NewBufferString(originManifests))
+			if err == nil && !strings.Contains(manifests.String(), "patched: \"true\"") {
+				err = fmt.Errorf("post renderer patch not applied: %s", manifests)
+			}
+
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+}
+
+// releaseManifest returns a rendered chart with a deployment, service and config map per component.
+func releaseManifest(release, components int) []byte {
+	var b strings.Builder
+	for i := 0; i < components; i++ {
+		fmt.Fprintf(&b, `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %[1]s-%[2]d
+  labels:
+    app.kubernetes.io/instance: %[1]s
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: %[1]s-%[2]d
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: %[1]s-%[2]d
+    spec:
+      containers:
+      - name: app
+        image: ghcr.io/stefanprodan/podinfo:6.7.0
+        ports:
+        - containerPort: 9898
+        envFrom:
+        - configMapRef:
+            name: %[1]s-%[2]d
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: %[1]s-%[2]d
+spec:
+  selector:
+    app.kubernetes.io/name: %[1]s-%[2]d
+  ports:
+  - port: 80
+    targetPort: 9898
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %[1]s-%[2]d
+data:
+  LOG_LEVEL: info
+`, fmt.Sprintf("release-%d", release), i)
+	}
+
+	return []byte(b.String())
+}
+
+// BenchmarkKustomizeManifests post renders 100 releases with an increasing number of workers.
+// Builds do not block each other, the releases/s scale with the workers up to the available CPUs.
+func BenchmarkKustomizeManifests(b *testing.B) {
+	const releases = 100
+	manifests := make([][]byte, releases)
+	for i := range manifests {
+		manifests[i] = releaseManifest(i, 10)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pool := pond.NewPool(workers)
+			defer pool.StopAndWait()
+
+			for i := 0; i < b.N; i++ {
+				group := pool.NewGroup()
+				for _, manifest := range manifests {
+					group.SubmitErr(func() error {
+						_, err := KustomizeManifests(map[string][]byte{"manifest.yaml": manifest})
+						return err
+					})
+				}
+
+				if err := group.Wait(); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(b.N*releases)/b.Elapsed().Seconds(), "releases/s")
+		})
+	}
+}
+
+// BenchmarkKustomize builds 20 kustomize paths on disk with an increasing number of workers.
+func BenchmarkKustomize(b *testing.B) {
+	const paths = 20
+	dir := b.TempDir()
+	for i := 0; i < paths; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("path-%d", i))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(path, "manifests.yaml"), releaseManifest(i, 10), 0644); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(path, "kustomization.yaml"), []byte("resources:\n- manifests.yaml\n"), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pool := pond.NewPool(workers)
+			defer pool.StopAndWait()
+
+			for i := 0; i < b.N; i++ {
+				group := pool.NewGroup()
+				for p := 0; p < paths; p++ {
+					path := filepath.Join(dir, fmt.Sprintf("path-%d", p))
+					group.SubmitErr(func() error {
+						_, _, err := KustomizeWithOrigins(context.TODO(), path, KustomizeOpts{})
+						return err
+					})
+				}
+
+				if err := group.Wait(); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(b.N*paths)/b.Elapsed().Seconds(), "paths/s")
+		})
+	}
+}
+
+func TestParseLoadRestrictor(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected kustypes.LoadRestrictions
+		err      bool
+	}{
+		{name: "", expected: kustypes.LoadRestrictionsNone},
+		{name: "none", expected: kustypes.LoadRestrictionsNone},
+		{name: "LoadRestrictionsNone", expected: kustypes.LoadRestrictionsNone},
+		{name: "rootOnly", expected: kustypes.LoadRestrictionsRootOnly},
+		{name: "LoadRestrictionsRootOnly", expected: kustypes.LoadRestrictionsRootOnly},
+		{name: "unknown", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			restrictor, err := ParseLoadRestrictor(test.name)
+			if test.err {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(restrictor).To(Equal(test.expected))
+		})
+	}
+}
+
+func TestKustomizeLoadRestrictor(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "app")
+	g.Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "configmap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n- ../configmap.yaml\n"), 0644)).To(Succeed())
+
+	index, err := Kustomize(context.TODO(), dir, KustomizeOpts{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(index.Size()).To(Equal(1))
+
+	_, err = Kustomize(context.TODO(), dir, KustomizeOpts{LoadRestrictor: kustypes.LoadRestrictionsRootOnly})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("is not in or below"))
+}
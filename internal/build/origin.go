@@ -0,0 +1,211 @@
+package build
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Origins maps the objects of a kustomize build to the local file they were declared in.
+type Origins map[resid.ResId]string
+
+// originHook enables origin annotations for the root kustomization without modifying it on disk.
+type originHook struct {
+	root     string
+	injected bool
+}
+
+func (h *originHook) rewrite(k *kustomization) error {
+	if k.dir != h.root {
+		return nil
+	}
+
+	for _, v := range k.kus.BuildMetadata {
+		if v == kustypes.OriginAnnotations {
+			return nil
+		}
+	}
+
+	buildMetadata, err := k.node.Pipe(yaml.LookupCreate(yaml.SequenceNode, "buildMetadata"))
+	if err != nil {
+		return nil
+	}
+
+	if err := buildMetadata.PipeE(yaml.Append(yaml.NewScalarRNode(kustypes.OriginAnnotations).YNode())); err != nil {
+		return nil
+	}
+
+	h.injected = true
+	k.modified = true
+	return nil
+}
+
+// collectOrigins resolves the origin annotation of all objects relative to root.
+// Objects from remote bases or generators have no local file and are omitted, objects of inflated helm charts
+// originate from the kustomization declaring the chart. charts maps the inflated chart files to their sources.
+func collectOrigins(index resmap.ResMap, root string, charts map[string][]string) (Origins, error) {
+	origins := make(Origins)
+	for _, res := range index.Resources() {
+		origin, err := res.GetOrigin()
+		if err != nil {
+			return nil, err
+		}
+
+		if origin == nil || origin.Repo != "" || origin.Path == "" || origin.ConfiguredIn != "" {
+			continue
+		}
+
+		if sources, ok := charts[origin.Path]; ok {
+			origins[res.CurId()] = filepath.Join(root, sources[0])
+			continue
+		}
+
+		origins[res.CurId()] = filepath.Join(root, origin.Path)
+	}
+
+	return origins, nil
+}
+
+// Inputs maps the objects of a kustomize build to the local files they have been built from,
+// the file declaring the object or the kustomization file and the sources of the generator which generated it.
+type Inputs map[resid.ResId][]string
+
+// collectInputs resolves the local files of all objects relative to root.
+// Objects from remote bases have no local files and are omitted, objects of inflated helm charts are built from
+// the kustomization declaring the chart and its values files.
+func collectInputs(index resmap.ResMap, root string, charts map[string][]string) (Inputs, error) {
+	inputs := make(Inputs)
+	kustomizations := make(map[string]*kustypes.Kustomization)
+	for _, res := range index.Resources() {
+		origin, err := res.GetOrigin()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case origin == nil || origin.Repo != "":
+		case origin.ConfiguredIn != "":
+			kfile := filepath.Join(root, origin.ConfiguredIn)
+			k, ok := kustomizations[kfile]
+			if !ok {
+				// Generator sources of unreadable kustomizations are unknown, the kustomization file is still an input
+				k, _ = readKustomization(kfile)
+				kustomizations[kfile] = k
+			}
+
+			inputs[res.CurId()] = append([]string{kfile}, generatorSources(k, filepath.Dir(kfile), origin.ConfiguredBy.Kind, res.OrgId().Name)...)
+		case charts[origin.Path] != nil:
+			for _, source := range charts[origin.Path] {
+				inputs[res.CurId()] = append(inputs[res.CurId()], filepath.Join(root, source))
+			}
+		case origin.Path != "":
+			inputs[res.CurId()] = []string{filepath.Join(root, origin.Path)}
+		}
+	}
+
+	return inputs, nil
+}
+
+func readKustomization(path string) (*kustypes.Kustomization, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &kustypes.Kustomization{}
+	if err := sigsyaml.Unmarshal(b, k); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// generatorSources returns the files and env files of the builtin ConfigMap or Secret generator of the
+// kustomization which generated the object with the given name.
+func generatorSources(k *kustypes.Kustomization, dir, kind, name string) []string {
+	if k == nil {
+		return nil
+	}
+
+	var generators []kustypes.GeneratorArgs
+	switch kind {
+	case "ConfigMapGenerator":
+		for _, g := range k.ConfigMapGenerator {
+			generators = append(generators, g.GeneratorArgs)
+		}
+	case "SecretGenerator":
+		for _, g := range k.SecretGenerator {
+			generators = append(generators, g.GeneratorArgs)
+		}
+	}
+
+	var sources []string
+	for _, g := range generators {
+		if !generatedName(name, g.Name) {
+			continue
+		}
+
+		for _, file := range g.FileSources {
+			// File sources are in the format [key=]path
+			if _, path, ok := strings.Cut(file, "="); ok {
+				file = path
+			}
+
+			sources = append(sources, filepath.Join(dir, file))
+		}
+
+		for _, env := range append(g.EnvSources, g.EnvSource) {
+			if env != "" {
+				sources = append(sources, filepath.Join(dir, env))
+			}
+		}
+	}
+
+	return sources
+}
+
+// generatedName returns true if name is the generator name, optionally followed by the content hash suffix.
+func generatedName(name, generator string) bool {
+	suffix, ok := strings.CutPrefix(name, generator)
+	return ok && (suffix == "" || (len(suffix) == 11 && suffix[0] == '-'))
+}
+
+// DeclarationLine returns the line number at which the object with the given kind and name
+// is declared within a yaml file. It returns 0 if the object is not found.
+func DeclarationLine(file, kind, name string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		node := yaml.NewRNode(doc.Content[0])
+		if node.GetKind() == kind && node.GetName() == name {
+			return doc.Content[0].Line, nil
+		}
+	}
+}
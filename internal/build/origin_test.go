@@ -0,0 +1,94 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+const originManifests = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`
+
+func TestKustomizeWithOrigins(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(dir, "base"), 0755)).To(Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(dir, "overlay"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "base", "configmaps.yaml"), []byte(originManifests), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "base", "kustomization.yaml"), []byte("resources:\n- configmaps.yaml\n"), 0644)).To(Succeed())
+	kustomization := []byte("namespace: test\nresources:\n- ../base\n")
+	g.Expect(os.WriteFile(filepath.Join(dir, "overlay", "kustomization.yaml"), kustomization, 0644)).To(Succeed())
+
+	index, origins, err := KustomizeWithOrigins(context.TODO(), filepath.Join(dir, "overlay"), KustomizeOpts{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	file := filepath.Join(dir, "base", "configmaps.yaml")
+	g.Expect(origins).To(HaveLen(2))
+	g.Expect(origins).To(HaveKeyWithValue(resid.NewResIdWithNamespace(resid.NewGvk("", "v1", "ConfigMap"), "b", "test"), file))
+
+	for _, res := range index.Resources() {
+		g.Expect(res.GetAnnotations()).To(BeEmpty())
+	}
+
+	// The kustomization on disk must not be modified
+	b, err := os.ReadFile(filepath.Join(dir, "overlay", "kustomization.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(b).To(Equal(kustomization))
+
+	line, err := DeclarationLine(file, "ConfigMap", "b")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(line).To(Equal(6))
+}
+
+func TestKustomizeWithInputs(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "configmaps.yaml"), []byte(originManifests), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("replicas: 1\n"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "tag.env"), []byte("tag=v1\n"), 0644)).To(Succeed())
+	kustomization := `resources:
+- configmaps.yaml
+configMapGenerator:
+- name: values
+  files:
+  - values.yaml=values.yaml
+secretGenerator:
+- name: tag
+  envs:
+  - tag.env
+`
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644)).To(Succeed())
+
+	index, _, inputs, err := KustomizeWithInputs(context.TODO(), dir, KustomizeOpts{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(inputs).To(HaveLen(4))
+
+	kfile := filepath.Join(dir, "kustomization.yaml")
+	for _, res := range index.Resources() {
+		switch {
+		case res.GetKind() == "Secret":
+			g.Expect(inputs[res.CurId()]).To(Equal([]string{kfile, filepath.Join(dir, "tag.env")}))
+		case strings.HasPrefix(res.GetName(), "values-"):
+			g.Expect(inputs[res.CurId()]).To(Equal([]string{kfile, filepath.Join(dir, "values.yaml")}))
+		default:
+			g.Expect(inputs[res.CurId()]).To(Equal([]string{filepath.Join(dir, "configmaps.yaml")}))
+		}
+
+		g.Expect(res.GetAnnotations()).To(BeEmpty())
+	}
+}
@@ -0,0 +1,177 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// overlayFS layers an in-memory filesystem over the disk. Files are written to memory and shadow the files on disk,
+// the disk is never modified.
+type overlayFS struct {
+	disk filesys.FileSystem
+	mem  filesys.FileSystem
+}
+
+func newOverlayFS() *overlayFS {
+	return &overlayFS{
+		disk: filesys.MakeFsOnDisk(),
+		mem:  filesys.MakeFsInMemory(),
+	}
+}
+
+// inMemory returns true if path is a file written to memory.
+func (fs *overlayFS) inMemory(path string) bool {
+	return fs.mem.Exists(path) && !fs.mem.IsDir(path)
+}
+
+func (fs *overlayFS) Create(path string) (filesys.File, error) {
+	return fs.mem.Create(path)
+}
+
+func (fs *overlayFS) Mkdir(path string) error {
+	return fs.mem.Mkdir(path)
+}
+
+func (fs *overlayFS) MkdirAll(path string) error {
+	return fs.mem.MkdirAll(path)
+}
+
+// RemoveAll removes path from memory only.
+func (fs *overlayFS) RemoveAll(path string) error {
+	if !fs.mem.Exists(path) {
+		return nil
+	}
+
+	return fs.mem.RemoveAll(path)
+}
+
+func (fs *overlayFS) Open(path string) (filesys.File, error) {
+	if fs.inMemory(path) {
+		return fs.mem.Open(path)
+	}
+
+	return fs.disk.Open(path)
+}
+
+func (fs *overlayFS) IsDir(path string) bool {
+	return fs.mem.IsDir(path) || fs.disk.IsDir(path)
+}
+
+func (fs *overlayFS) ReadDir(path string) ([]string, error) {
+	if !fs.mem.IsDir(path) {
+		return fs.disk.ReadDir(path)
+	}
+
+	names, err := fs.mem.ReadDir(path)
+	if err != nil || !fs.disk.IsDir(path) {
+		return names, err
+	}
+
+	onDisk, err := fs.disk.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return union(names, onDisk), nil
+}
+
+func (fs *overlayFS) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	if fs.disk.Exists(path) || !fs.mem.Exists(path) {
+		return fs.disk.CleanedAbs(path)
+	}
+
+	// A file in memory within a directory on disk
+	if dir := filepath.Dir(path); !fs.mem.IsDir(path) && fs.disk.IsDir(dir) {
+		d, _, err := fs.disk.CleanedAbs(dir)
+		return d, filepath.Base(path), err
+	}
+
+	return fs.mem.CleanedAbs(path)
+}
+
+func (fs *overlayFS) Exists(path string) bool {
+	return fs.mem.Exists(path) || fs.disk.Exists(path)
+}
+
+func (fs *overlayFS) Glob(pattern string) ([]string, error) {
+	inMemory, err := fs.mem.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	onDisk, err := fs.disk.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return union(inMemory, onDisk), nil
+}
+
+func (fs *overlayFS) ReadFile(path string) ([]byte, error) {
+	if fs.inMemory(path) {
+		return fs.mem.ReadFile(path)
+	}
+
+	return fs.disk.ReadFile(path)
+}
+
+func (fs *overlayFS) WriteFile(path string, data []byte) error {
+	return fs.mem.WriteFile(path, data)
+}
+
+// Walk walks the files on disk followed by the files which only exist in memory.
+func (fs *overlayFS) Walk(path string, walkFn filepath.WalkFunc) error {
+	var skipped []string
+	if fs.disk.Exists(path) {
+		err := fs.disk.Walk(path, func(p string, info os.FileInfo, err error) error {
+			err = walkFn(p, info, err)
+			if errors.Is(err, filepath.SkipDir) && info != nil && info.IsDir() {
+				skipped = append(skipped, p)
+			}
+
+			return err
+		})
+
+		if err != nil || !fs.mem.Exists(path) {
+			return err
+		}
+	}
+
+	return fs.mem.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return walkFn(p, info, err)
+		}
+
+		for _, dir := range skipped {
+			if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+				return filepath.SkipDir
+			}
+		}
+
+		if fs.disk.Exists(p) {
+			return nil
+		}
+
+		return walkFn(p, info, err)
+	})
+}
+
+// union returns the sorted unique names of a and b.
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var names []string
+	for _, name := range append(a, b...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,66 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestOverlayFS(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(dir, "sub"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("disk"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "sub", "b.yaml"), []byte("disk"), 0644)).To(Succeed())
+
+	fs := newOverlayFS()
+	g.Expect(fs.WriteFile(filepath.Join(dir, "a.yaml"), []byte("memory"))).To(Succeed())
+	g.Expect(fs.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("memory"))).To(Succeed())
+
+	b, err := fs.ReadFile(filepath.Join(dir, "a.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(b)).To(Equal("memory"))
+
+	b, err = fs.ReadFile(filepath.Join(dir, "sub", "b.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(b)).To(Equal("disk"))
+
+	g.Expect(fs.Exists(filepath.Join(dir, "kustomization.yaml"))).To(BeTrue())
+	g.Expect(fs.IsDir(filepath.Join(dir, "sub"))).To(BeTrue())
+
+	names, err := fs.ReadDir(dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(names).To(Equal([]string{"a.yaml", "kustomization.yaml", "sub"}))
+
+	root, file, err := fs.CleanedAbs(filepath.Join(dir, "kustomization.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(root.Join(file)).To(Equal(filepath.Join(dir, "kustomization.yaml")))
+
+	var walked []string
+	g.Expect(fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && path == filepath.Join(dir, "sub") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		walked = append(walked, rel)
+		return err
+	})).To(Succeed())
+	g.Expect(walked).To(ConsistOf(".", "a.yaml", "kustomization.yaml"))
+
+	// The disk is never modified
+	b, err = os.ReadFile(filepath.Join(dir, "a.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(b)).To(Equal("disk"))
+	g.Expect(filepath.Join(dir, "kustomization.yaml")).ToNot(BeAnExistingFile())
+
+	g.Expect(fs.RemoveAll(filepath.Join(dir, "sub"))).To(Succeed())
+	g.Expect(filepath.Join(dir, "sub", "b.yaml")).To(BeAnExistingFile())
+}
@@ -0,0 +1,53 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/doodlescheduling/flux-build/internal/remote"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// remotesHook rewrites the remote bases and files of kustomizations to their local mirror or vendored copy.
+// Remotes which can not be resolved are fetched by kustomize unless the resolver is offline.
+type remotesHook struct {
+	fs       filesys.FileSystem
+	resolver *remote.Resolver
+}
+
+func (h *remotesHook) rewrite(k *kustomization) error {
+	for _, field := range []string{"resources", "components", "bases"} {
+		entries := k.node.Field(field)
+		if entries == nil || entries.Value.YNode().Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, entry := range entries.Value.YNode().Content {
+			r, ok := remote.Parse(entry.Value)
+			if !ok || h.fs.Exists(filepath.Join(k.dir, entry.Value)) {
+				continue
+			}
+
+			local, _ := h.resolver.Resolve(r)
+			if local == "" {
+				if h.resolver.Offline {
+					return fmt.Errorf("%s: remote %s is neither mirrored nor vendored", k.path, r.URL)
+				}
+
+				continue
+			}
+
+			// Kustomize does not accept absolute paths of bases
+			rel, err := filepath.Rel(k.dir, local)
+			if err != nil {
+				continue
+			}
+
+			entry.Value = rel
+			k.modified = true
+		}
+	}
+
+	return nil
+}
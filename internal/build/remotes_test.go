@@ -0,0 +1,93 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doodlescheduling/flux-build/internal/remote"
+	. "github.com/onsi/gomega"
+)
+
+func TestKustomizeRemotes(t *testing.T) {
+	tests := []struct {
+		name          string
+		kustomization string
+		offline       bool
+		expected      []string
+		labels        map[string]string
+		err           string
+	}{
+		{
+			name:          "mirrored repository",
+			kustomization: "resources:\n- github.com/org/repo//base?ref=v1\n- configmap.yaml\n",
+			expected:      []string{"ConfigMap/mirrored", "ConfigMap/local"},
+		},
+		{
+			name:          "mirrored file",
+			kustomization: "resources:\n- https://example.com/manifests/remote.yaml\n",
+			expected:      []string{"ConfigMap/remote"},
+		},
+		{
+			name:          "mirrored component",
+			kustomization: "resources:\n- configmap.yaml\ncomponents:\n- github.com/org/repo//component?ref=v1\n",
+			expected:      []string{"ConfigMap/local"},
+			labels:        map[string]string{"mirrored": "true"},
+		},
+		{
+			name:          "offline remote which is not mirrored",
+			kustomization: "resources:\n- github.com/org/repo//base?ref=v2\n",
+			offline:       true,
+			err:           "remote github.com/org/repo//base?ref=v2 is neither mirrored nor vendored",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			root := t.TempDir()
+			files := map[string]string{
+				"app/kustomization.yaml":            test.kustomization,
+				"app/configmap.yaml":                "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: local\n",
+				"repo/base/kustomization.yaml":      "resources:\n- configmap.yaml\n",
+				"repo/base/configmap.yaml":          "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: mirrored\n",
+				"repo/component/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nlabels:\n- pairs:\n    mirrored: \"true\"\n",
+				"manifests/remote.yaml":             "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: remote\n",
+			}
+
+			for name, content := range files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(root, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			mirrors, err := remote.ParseMirrors([]string{
+				"github.com/org/repo?ref=v1=" + filepath.Join(root, "repo"),
+				"https://example.com/manifests/=" + filepath.Join(root, "manifests"),
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			opts := KustomizeOpts{Remotes: &remote.Resolver{Mirrors: mirrors, Offline: test.offline}}
+			index, origins, err := KustomizeWithOrigins(context.TODO(), filepath.Join(root, "app"), opts)
+			if test.err != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(test.err))
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, res := range index.Resources() {
+				names = append(names, res.GetKind()+"/"+res.GetName())
+				g.Expect(origins).To(HaveKey(res.CurId()))
+				for key, value := range test.labels {
+					g.Expect(res.GetLabels()).To(HaveKeyWithValue(key, value))
+				}
+			}
+
+			g.Expect(names).To(ConsistOf(test.expected))
+		})
+	}
+}
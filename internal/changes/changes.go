@@ -0,0 +1,62 @@
+package changes
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Set is a set of changed files.
+type Set struct {
+	files map[string]bool
+}
+
+// New creates a set of the given files, relative paths are resolved against the working directory.
+func New(files []string) *Set {
+	s := &Set{files: make(map[string]bool)}
+	for _, file := range files {
+		s.files[normalize(file)] = true
+	}
+
+	return s
+}
+
+// normalize returns the absolute path of the file with symlinks resolved, if possible.
+func normalize(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	// Deleted files can not be resolved, resolve their directory instead
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+
+	return path
+}
+
+// Len returns the number of changed files.
+func (s *Set) Len() int {
+	return len(s.files)
+}
+
+// Contains returns true if the file has changed.
+func (s *Set) Contains(file string) bool {
+	return s.files[normalize(file)]
+}
+
+// Within returns the changed files within the directory.
+func (s *Set) Within(dir string) []string {
+	dir = normalize(dir)
+	var files []string
+	for file := range s.files {
+		if strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
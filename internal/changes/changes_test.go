@@ -0,0 +1,33 @@
+package changes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSet(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(dir, "apps", "podinfo"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "apps", "podinfo", "values.yaml"), nil, 0644)).To(Succeed())
+	g.Expect(os.Symlink(filepath.Join(dir, "apps"), filepath.Join(dir, "link"))).To(Succeed())
+
+	s := New([]string{
+		filepath.Join(dir, "apps", "podinfo", "values.yaml"),
+		filepath.Join(dir, "apps", "deleted.yaml"),
+	})
+	g.Expect(s.Len()).To(Equal(2))
+
+	g.Expect(s.Contains(filepath.Join(dir, "apps", "podinfo", "values.yaml"))).To(BeTrue())
+	g.Expect(s.Contains(filepath.Join(dir, "link", "podinfo", "values.yaml"))).To(BeTrue())
+	g.Expect(s.Contains(filepath.Join(dir, "apps", "deleted.yaml"))).To(BeTrue())
+	g.Expect(s.Contains(filepath.Join(dir, "apps", "podinfo"))).To(BeFalse())
+
+	g.Expect(s.Within(filepath.Join(dir, "link"))).To(HaveLen(2))
+	g.Expect(s.Within(filepath.Join(dir, "apps", "podinfo"))).To(HaveLen(1))
+	g.Expect(s.Within(filepath.Join(dir, "repos"))).To(BeEmpty())
+}
@@ -0,0 +1,72 @@
+package charts
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Chart is the chart a HelmRelease has been rendered from.
+type Chart struct {
+	// HelmRelease is the namespace/name of the HelmRelease.
+	HelmRelease string `json:"helmRelease"`
+	// Name is the name of the chart.
+	Name string `json:"name"`
+	// Constraint is the version or semver range requested by the HelmRelease.
+	Constraint string `json:"constraint,omitempty"`
+	// Version is the chart version the constraint has been resolved to.
+	Version string `json:"version,omitempty"`
+	// Repository is the normalized url of the chart repository.
+	Repository string `json:"repository,omitempty"`
+	// Digest is the sha256 digest of the chart tarball.
+	Digest string `json:"digest,omitempty"`
+	// FromCache is true if the chart has not been pulled but taken from the chart cache.
+	FromCache    bool         `json:"fromCache"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Dependency is a subchart packaged with a chart.
+type Dependency struct {
+	Name string `json:"name"`
+	// Constraint is the version or semver range declared in the Chart.yaml of the parent chart.
+	Constraint string `json:"constraint,omitempty"`
+	// Version is the version of the packaged subchart.
+	Version    string `json:"version,omitempty"`
+	Repository string `json:"repository,omitempty"`
+}
+
+// Inventory is the list of charts used by a build. It is safe for concurrent use.
+type Inventory struct {
+	mu     sync.Mutex
+	Charts []Chart `json:"charts"`
+}
+
+// Add adds a chart to the inventory.
+func (inv *Inventory) Add(c Chart) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.Charts = append(inv.Charts, c)
+}
+
+// Sort orders the charts by HelmRelease.
+func (inv *Inventory) Sort() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	sort.SliceStable(inv.Charts, func(i, j int) bool {
+		return inv.Charts[i].HelmRelease < inv.Charts[j].HelmRelease
+	})
+}
+
+// WriteJSON writes the inventory as JSON.
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.Charts == nil {
+		inv.Charts = []Chart{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv)
+}
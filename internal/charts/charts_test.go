@@ -0,0 +1,59 @@
+package charts
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	inv := &Inventory{}
+	inv.Add(Chart{HelmRelease: "monitoring/prometheus", Name: "kube-prometheus-stack", Constraint: "62.x", Version: "62.7.0", Repository: "https://prometheus-community.github.io/helm-charts/", Digest: "sha256:abc"})
+	inv.Add(Chart{HelmRelease: "apps/redis", Name: "redis", Constraint: "18.1.6", Repository: "oci://registry-1.docker.io/bitnamicharts", FromCache: true, Dependencies: []Dependency{
+		{Name: "common", Constraint: "2.x.x", Version: "2.13.0", Repository: "oci://registry-1.docker.io/bitnamicharts"},
+	}})
+	inv.Sort()
+
+	var buf bytes.Buffer
+	g.Expect(inv.WriteJSON(&buf)).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`{
+  "charts": [
+    {
+      "helmRelease": "apps/redis",
+      "name": "redis",
+      "constraint": "18.1.6",
+      "repository": "oci://registry-1.docker.io/bitnamicharts",
+      "fromCache": true,
+      "dependencies": [
+        {
+          "name": "common",
+          "constraint": "2.x.x",
+          "version": "2.13.0",
+          "repository": "oci://registry-1.docker.io/bitnamicharts"
+        }
+      ]
+    },
+    {
+      "helmRelease": "monitoring/prometheus",
+      "name": "kube-prometheus-stack",
+      "constraint": "62.x",
+      "version": "62.7.0",
+      "repository": "https://prometheus-community.github.io/helm-charts/",
+      "digest": "sha256:abc",
+      "fromCache": false
+    }
+  ]
+}
+`))
+}
+
+func TestInventoryEmpty(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect((&Inventory{}).WriteJSON(&buf)).To(Succeed())
+	g.Expect(buf.String()).To(Equal("{\n  \"charts\": []\n}\n"))
+}
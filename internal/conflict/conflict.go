@@ -0,0 +1,212 @@
+package conflict
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Mode defines how conflicting objects are reported.
+type Mode string
+
+const (
+	// ModeIgnore disables conflict detection.
+	ModeIgnore Mode = "ignore"
+	// ModeWarn reports conflicts as warnings.
+	ModeWarn Mode = "warn"
+	// ModeFail reports conflicts as errors.
+	ModeFail Mode = "fail"
+)
+
+// ParseMode converts a string into a Mode. An empty string equals ModeIgnore.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeIgnore:
+		return ModeIgnore, nil
+	case ModeWarn, ModeFail:
+		return Mode(s), nil
+	}
+
+	return ModeIgnore, fmt.Errorf("unsupported conflict mode %q, expected one of ignore, warn, fail", s)
+}
+
+// ID identifies an object within a cluster.
+type ID struct {
+	schema.GroupKind
+	Namespace string
+	Name      string
+}
+
+// String returns the id in the format [group/]Kind:[namespace/]name.
+func (id ID) String() string {
+	kind := id.Kind
+	if id.Group != "" {
+		kind = fmt.Sprintf("%s/%s", id.Group, id.Kind)
+	}
+
+	if id.Namespace == "" {
+		return fmt.Sprintf("%s:%s", kind, id.Name)
+	}
+
+	return fmt.Sprintf("%s:%s/%s", kind, id.Namespace, id.Name)
+}
+
+// Conflict is an object which is produced more than once.
+type Conflict struct {
+	ID ID
+	// Producers are the build targets which produce the object.
+	Producers []string
+	// Identical is true if all producers produce the same content.
+	Identical bool
+}
+
+func (c Conflict) String() string {
+	if c.Identical {
+		return fmt.Sprintf("duplicate object %s produced by %s", c.ID, strings.Join(c.Producers, ", "))
+	}
+
+	return fmt.Sprintf("conflicting object %s produced with different content by %s", c.ID, strings.Join(c.Producers, ", "))
+}
+
+type occurrence struct {
+	producer string
+	hash     [sha256.Size]byte
+}
+
+// Detector records which build target produces which objects.
+// It is not safe for concurrent use.
+type Detector struct {
+	allow   Allowlist
+	objects map[ID][]occurrence
+}
+
+// NewDetector creates a Detector. Objects matched by the allowlist are intentional overlaps and not reported.
+func NewDetector(allow Allowlist) *Detector {
+	return &Detector{
+		allow:   allow,
+		objects: make(map[ID][]occurrence),
+	}
+}
+
+// Add records the objects produced by the given producer.
+// Namespaced objects without a namespace are recorded in namespace, e.g. the release namespace of a HelmRelease.
+func (d *Detector) Add(producer, namespace string, resources []*resource.Resource) error {
+	for _, res := range resources {
+		b, err := res.AsYAML()
+		if err != nil {
+			return err
+		}
+
+		gvk := schema.FromAPIVersionAndKind(res.GetApiVersion(), res.GetKind())
+		id := ID{
+			GroupKind: gvk.GroupKind(),
+			Namespace: res.GetNamespace(),
+			Name:      res.GetName(),
+		}
+
+		if id.Namespace == "" && !res.GetGvk().IsClusterScoped() {
+			id.Namespace = namespace
+		}
+
+		d.objects[id] = append(d.objects[id], occurrence{
+			producer: producer,
+			hash:     sha256.Sum256(b),
+		})
+	}
+
+	return nil
+}
+
+// Conflicts returns all objects which have been produced more than once and are not allowed to overlap.
+func (d *Detector) Conflicts() []Conflict {
+	var conflicts []Conflict
+	for id, occurrences := range d.objects {
+		if len(occurrences) < 2 || d.allow.Match(id) {
+			continue
+		}
+
+		c := Conflict{
+			ID:        id,
+			Identical: true,
+		}
+
+		for _, o := range occurrences {
+			c.Producers = append(c.Producers, o.producer)
+			if o.hash != occurrences[0].hash {
+				c.Identical = false
+			}
+		}
+
+		sort.Strings(c.Producers)
+		conflicts = append(conflicts, c)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].ID.String() < conflicts[j].ID.String()
+	})
+
+	return conflicts
+}
+
+// Allowlist matches objects which may be produced more than once.
+type Allowlist []allowPattern
+
+type allowPattern struct {
+	group  string
+	kind   string
+	object string
+}
+
+// ParseAllowlist parses glob patterns in the format [group/]Kind:[namespace/]name.
+// A pattern without a group matches the kind in any group.
+func ParseAllowlist(patterns []string) (Allowlist, error) {
+	var allow Allowlist
+	for _, pattern := range patterns {
+		kind, object, ok := strings.Cut(pattern, ":")
+		if !ok || kind == "" || object == "" {
+			return nil, fmt.Errorf("invalid conflict allow pattern %q, expected [group/]Kind:[namespace/]name", pattern)
+		}
+
+		p := allowPattern{kind: kind, object: object, group: "*"}
+		if i := strings.LastIndex(kind, "/"); i != -1 {
+			p.group = kind[:i]
+			p.kind = kind[i+1:]
+		}
+
+		for _, s := range []string{p.group, p.kind, p.object} {
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, fmt.Errorf("invalid conflict allow pattern %q: %w", pattern, err)
+			}
+		}
+
+		allow = append(allow, p)
+	}
+
+	return allow, nil
+}
+
+// Match returns true if the object is allowed to be produced more than once.
+func (a Allowlist) Match(id ID) bool {
+	object := id.Name
+	if id.Namespace != "" {
+		object = fmt.Sprintf("%s/%s", id.Namespace, id.Name)
+	}
+
+	for _, p := range a {
+		if match(p.group, id.Group) && match(p.kind, id.Kind) && match(p.object, object) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func match(pattern, s string) bool {
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
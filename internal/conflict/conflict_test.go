@@ -0,0 +1,163 @@
+package conflict
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+func parse(g *WithT, manifests string) []*resource.Resource {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(manifests))
+	g.Expect(err).ToNot(HaveOccurred())
+	return resources
+}
+
+func TestParseMode(t *testing.T) {
+	g := NewWithT(t)
+
+	mode, err := ParseMode("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mode).To(Equal(ModeIgnore))
+
+	mode, err = ParseMode("warn")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mode).To(Equal(ModeWarn))
+
+	_, err = ParseMode("error")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestConflicts(t *testing.T) {
+	g := NewWithT(t)
+
+	d := NewDetector(nil)
+	g.Expect(d.Add("path/apps", "", parse(g, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: monitoring
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: apps
+`))).To(Succeed())
+
+	g.Expect(d.Add("helmrelease/apps/a", "apps", parse(g, `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+rules: []
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: monitoring
+`))).To(Succeed())
+
+	g.Expect(d.Add("helmrelease/apps/b", "apps", parse(g, `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get"]
+`))).To(Succeed())
+
+	g.Expect(d.Conflicts()).To(Equal([]Conflict{
+		{
+			ID:        ID{GroupKind: schema.GroupKind{Kind: "Namespace"}, Name: "monitoring"},
+			Producers: []string{"helmrelease/apps/a", "path/apps"},
+			Identical: true,
+		},
+		{
+			ID:        ID{GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}, Name: "reader"},
+			Producers: []string{"helmrelease/apps/a", "helmrelease/apps/b"},
+		},
+	}))
+}
+
+func TestConflictsReleaseNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	// The same chart installed by two releases into different namespaces
+	manifests := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: app
+rules: []
+`
+
+	d := NewDetector(nil)
+	g.Expect(d.Add("helmrelease/dev/app", "dev", parse(g, manifests))).To(Succeed())
+	g.Expect(d.Add("helmrelease/prod/app", "prod", parse(g, manifests))).To(Succeed())
+
+	g.Expect(d.Conflicts()).To(Equal([]Conflict{
+		{
+			ID:        ID{GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}, Name: "app"},
+			Producers: []string{"helmrelease/dev/app", "helmrelease/prod/app"},
+			Identical: true,
+		},
+	}))
+}
+
+func TestAllowlist(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		expectErr bool
+		id        ID
+		match     bool
+	}{
+		{
+			pattern: "Namespace:monitoring",
+			id:      ID{GroupKind: schema.GroupKind{Kind: "Namespace"}, Name: "monitoring"},
+			match:   true,
+		},
+		{
+			pattern: "rbac.authorization.k8s.io/ClusterRole:*",
+			id:      ID{GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}, Name: "reader"},
+			match:   true,
+		},
+		{
+			pattern: "ClusterRole:reader",
+			id:      ID{GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}, Name: "reader"},
+			match:   true,
+		},
+		{
+			pattern: "ConfigMap:*",
+			id:      ID{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Namespace: "apps", Name: "config"},
+			match:   false,
+		},
+		{
+			pattern: "ConfigMap:apps/*",
+			id:      ID{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Namespace: "apps", Name: "config"},
+			match:   true,
+		},
+		{pattern: "ConfigMap", expectErr: true},
+		{pattern: "ConfigMap:[", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			g := NewWithT(t)
+			allow, err := ParseAllowlist([]string{tt.pattern})
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(allow.Match(tt.id)).To(Equal(tt.match))
+		})
+	}
+}
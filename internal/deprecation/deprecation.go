@@ -0,0 +1,114 @@
+package deprecation
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// API is a Kubernetes API which has been deprecated or removed.
+type API struct {
+	Group        string
+	Version      string
+	Kind         string
+	DeprecatedIn string
+	RemovedIn    string
+	Replacement  string
+}
+
+// APIVersion returns the apiVersion of the API in the format [group/]version.
+func (a API) APIVersion() string {
+	return schema.GroupVersion{Group: a.Group, Version: a.Version}.String()
+}
+
+// Finding is a deprecated or removed API matched for a given Kubernetes version.
+type Finding struct {
+	API API
+	// Removed is true if the API is no longer served at the given version.
+	Removed bool
+	// KubeVersion is the version the API was checked against.
+	KubeVersion string
+}
+
+func (f Finding) String() string {
+	var msg string
+	if f.Removed {
+		msg = fmt.Sprintf("%s %s has been removed in v%s and is not served by Kubernetes %s", f.API.APIVersion(), f.API.Kind, f.API.RemovedIn, f.KubeVersion)
+	} else {
+		msg = fmt.Sprintf("%s %s is deprecated since v%s", f.API.APIVersion(), f.API.Kind, f.API.DeprecatedIn)
+		if f.API.RemovedIn != "" {
+			msg = fmt.Sprintf("%s and will be removed in v%s", msg, f.API.RemovedIn)
+		}
+	}
+
+	if f.API.Replacement == "" {
+		return fmt.Sprintf("%s, no replacement is available", msg)
+	}
+
+	return fmt.Sprintf("%s, migrate to %s", msg, f.API.Replacement)
+}
+
+// Checker matches objects against a list of deprecated and removed APIs.
+type Checker struct {
+	apis map[schema.GroupVersionKind]API
+}
+
+// NewChecker creates a Checker for the given APIs.
+func NewChecker(apis []API) (*Checker, error) {
+	c := &Checker{
+		apis: make(map[schema.GroupVersionKind]API, len(apis)),
+	}
+
+	for _, api := range apis {
+		for _, v := range []string{api.DeprecatedIn, api.RemovedIn} {
+			if v == "" {
+				continue
+			}
+
+			if _, err := semver.NewVersion(v); err != nil {
+				return nil, fmt.Errorf("invalid version %q for %s %s: %w", v, api.APIVersion(), api.Kind, err)
+			}
+		}
+
+		c.apis[schema.GroupVersionKind{Group: api.Group, Version: api.Version, Kind: api.Kind}] = api
+	}
+
+	return c, nil
+}
+
+// Check returns a finding if the given apiVersion and kind is deprecated or removed at the given Kubernetes version.
+// The kubeVersion accepts the formats 1.31, v1.31 or v1.31.0.
+func (c *Checker) Check(apiVersion, kind, kubeVersion string) (*Finding, error) {
+	api, ok := c.apis[schema.FromAPIVersionAndKind(apiVersion, kind)]
+	if !ok {
+		return nil, nil
+	}
+
+	current, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubernetes version %q: %w", kubeVersion, err)
+	}
+
+	// Prereleases like 1.32.0-rc.1 are treated as the final release
+	if current.Prerelease() != "" {
+		v, _ := current.SetPrerelease("")
+		current = &v
+	}
+
+	finding := &Finding{
+		API:         api,
+		KubeVersion: fmt.Sprintf("v%s", current),
+	}
+
+	if api.RemovedIn != "" && !current.LessThan(semver.MustParse(api.RemovedIn)) {
+		finding.Removed = true
+		return finding, nil
+	}
+
+	if api.DeprecatedIn != "" && !current.LessThan(semver.MustParse(api.DeprecatedIn)) {
+		return finding, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,115 @@
+package deprecation
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestAPIs(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := NewChecker(APIs)
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestCheck(t *testing.T) {
+	c, err := NewChecker(APIs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		apiVersion  string
+		kind        string
+		kubeVersion string
+		expectNil   bool
+		removed     bool
+		message     string
+	}{
+		{
+			name:        "served api",
+			apiVersion:  "apps/v1",
+			kind:        "Deployment",
+			kubeVersion: "v1.31.0",
+			expectNil:   true,
+		},
+		{
+			name:        "not yet deprecated",
+			apiVersion:  "batch/v1beta1",
+			kind:        "CronJob",
+			kubeVersion: "1.20",
+			expectNil:   true,
+		},
+		{
+			name:        "deprecated",
+			apiVersion:  "batch/v1beta1",
+			kind:        "CronJob",
+			kubeVersion: "v1.21.3",
+			message:     "batch/v1beta1 CronJob is deprecated since v1.21 and will be removed in v1.25, migrate to batch/v1",
+		},
+		{
+			name:        "removed",
+			apiVersion:  "batch/v1beta1",
+			kind:        "CronJob",
+			kubeVersion: "v1.25.0",
+			removed:     true,
+			message:     "batch/v1beta1 CronJob has been removed in v1.25 and is not served by Kubernetes v1.25.0, migrate to batch/v1",
+		},
+		{
+			name:        "removed at prerelease",
+			apiVersion:  "flowcontrol.apiserver.k8s.io/v1beta3",
+			kind:        "FlowSchema",
+			kubeVersion: "v1.32.0-rc.1",
+			removed:     true,
+			message:     "flowcontrol.apiserver.k8s.io/v1beta3 FlowSchema has been removed in v1.32 and is not served by Kubernetes v1.32.0, migrate to flowcontrol.apiserver.k8s.io/v1",
+		},
+		{
+			name:        "removed without replacement",
+			apiVersion:  "policy/v1beta1",
+			kind:        "PodSecurityPolicy",
+			kubeVersion: "v1.31.0",
+			removed:     true,
+			message:     "policy/v1beta1 PodSecurityPolicy has been removed in v1.25 and is not served by Kubernetes v1.31.0, no replacement is available",
+		},
+		{
+			name:        "core api",
+			apiVersion:  "v1",
+			kind:        "Endpoints",
+			kubeVersion: "v1.33.0",
+			message:     "v1 Endpoints is deprecated since v1.33, migrate to discovery.k8s.io/v1 EndpointSlice",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			finding, err := c.Check(test.apiVersion, test.kind, test.kubeVersion)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			if test.expectNil {
+				g.Expect(finding).To(BeNil())
+				return
+			}
+
+			g.Expect(finding).ToNot(BeNil())
+			g.Expect(finding.Removed).To(Equal(test.removed))
+			g.Expect(finding.String()).To(Equal(test.message))
+		})
+	}
+}
+
+func TestCheckInvalidVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := NewChecker(APIs)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = c.Check("batch/v1beta1", "CronJob", "latest")
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = NewChecker([]API{{Group: "batch", Version: "v1beta1", Kind: "CronJob", RemovedIn: "soon"}})
+	g.Expect(err).To(HaveOccurred())
+}
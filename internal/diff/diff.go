@@ -0,0 +1,278 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// ChangeType describes how an object differs between two builds.
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Modified ChangeType = "modified"
+	Removed  ChangeType = "removed"
+)
+
+var (
+	releaseNameLabel      = fmt.Sprintf("%s/name", helmv2.GroupVersion.Group)
+	releaseNamespaceLabel = fmt.Sprintf("%s/namespace", helmv2.GroupVersion.Group)
+)
+
+// Change is a single object which differs between two builds.
+type Change struct {
+	Type      ChangeType
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	// Release is the namespace/name of the HelmRelease which rendered the object, if any.
+	Release string
+	// Diff is the unified diff of the object manifests.
+	Diff string
+}
+
+// ID returns a human readable identity of the changed object.
+func (c Change) ID() string {
+	if c.Namespace == "" {
+		return fmt.Sprintf("%s %s", c.Kind, c.Name)
+	}
+
+	return fmt.Sprintf("%s %s/%s", c.Kind, c.Namespace, c.Name)
+}
+
+// Release summarizes the changes of a single HelmRelease between two builds.
+type Release struct {
+	Namespace  string
+	Name       string
+	Chart      string
+	OldVersion string
+	NewVersion string
+	Type       ChangeType
+	// Changes is the number of changed objects rendered by this release.
+	Changes int
+}
+
+// ID returns the namespace/name of the release.
+func (r Release) ID() string {
+	return fmt.Sprintf("%s/%s", r.Namespace, r.Name)
+}
+
+// VersionBump returns true if the requested chart version differs between both builds.
+func (r Release) VersionBump() bool {
+	return r.Type == Modified && r.OldVersion != r.NewVersion
+}
+
+// KindCount holds the number of changes for a single kind.
+type KindCount struct {
+	Kind     string
+	Added    int
+	Modified int
+	Removed  int
+}
+
+// Result is the comparison of two builds.
+type Result struct {
+	Changes  []Change
+	Releases []Release
+}
+
+type object struct {
+	res  *resource.Resource
+	yaml string
+}
+
+type objectKey struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// Compare compares the objects of a base build against the objects of a head build.
+func Compare(base, head []*resource.Resource) (*Result, error) {
+	baseObjects, err := index(base)
+	if err != nil {
+		return nil, err
+	}
+
+	headObjects, err := index(head)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for key, h := range headObjects {
+		b, ok := baseObjects[key]
+		switch {
+		case !ok:
+			result.add(key, Added, nil, h)
+		case b.yaml != h.yaml:
+			result.add(key, Modified, b, h)
+		}
+	}
+
+	for key, b := range baseObjects {
+		if _, ok := headObjects[key]; !ok {
+			result.add(key, Removed, b, nil)
+		}
+	}
+
+	sort.Slice(result.Changes, func(i, j int) bool {
+		a, b := result.Changes[i], result.Changes[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	result.Releases = releases(result.Changes, baseObjects, headObjects)
+	return result, nil
+}
+
+// Empty returns true if there are no changes between both builds.
+func (r *Result) Empty() bool {
+	return len(r.Changes) == 0
+}
+
+// KindCounts returns the number of changes grouped by kind.
+func (r *Result) KindCounts() []KindCount {
+	var counts []KindCount
+	for _, c := range r.Changes {
+		if len(counts) == 0 || counts[len(counts)-1].Kind != c.Kind {
+			counts = append(counts, KindCount{Kind: c.Kind})
+		}
+
+		count := &counts[len(counts)-1]
+		switch c.Type {
+		case Added:
+			count.Added++
+		case Modified:
+			count.Modified++
+		case Removed:
+			count.Removed++
+		}
+	}
+
+	return counts
+}
+
+func (r *Result) add(key objectKey, t ChangeType, base, head *object) {
+	var from, to string
+	var owner *resource.Resource
+	if base != nil {
+		from = base.yaml
+		owner = base.res
+	}
+	if head != nil {
+		to = head.yaml
+		owner = head.res
+	}
+
+	d, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "base",
+		ToFile:   "head",
+		Context:  3,
+	})
+
+	change := Change{
+		Type:      t,
+		Group:     key.Group,
+		Kind:      key.Kind,
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Diff:      d,
+	}
+
+	labels := owner.GetLabels()
+	if name, ok := labels[releaseNameLabel]; ok {
+		change.Release = fmt.Sprintf("%s/%s", labels[releaseNamespaceLabel], name)
+	}
+
+	r.Changes = append(r.Changes, change)
+}
+
+func releases(changes []Change, base, head map[objectKey]*object) []Release {
+	byID := make(map[string]*Release)
+	get := func(namespace, name string) *Release {
+		id := fmt.Sprintf("%s/%s", namespace, name)
+		if r, ok := byID[id]; ok {
+			return r
+		}
+
+		r := &Release{Namespace: namespace, Name: name, Type: Modified}
+		key := objectKey{Group: helmv2.GroupVersion.Group, Kind: helmv2.HelmReleaseKind, Namespace: namespace, Name: name}
+		b, inBase := base[key]
+		if inBase {
+			r.Chart, _ = b.res.GetString("spec.chart.spec.chart")
+			r.OldVersion, _ = b.res.GetString("spec.chart.spec.version")
+		}
+
+		h, inHead := head[key]
+		if inHead {
+			r.Chart, _ = h.res.GetString("spec.chart.spec.chart")
+			r.NewVersion, _ = h.res.GetString("spec.chart.spec.version")
+		}
+
+		switch {
+		case inHead && !inBase:
+			r.Type = Added
+		case inBase && !inHead:
+			r.Type = Removed
+		}
+
+		byID[id] = r
+		return r
+	}
+
+	for _, c := range changes {
+		switch {
+		case c.Kind == helmv2.HelmReleaseKind && c.Group == helmv2.GroupVersion.Group:
+			get(c.Namespace, c.Name)
+		case c.Release != "":
+			ns, name, _ := strings.Cut(c.Release, "/")
+			get(ns, name).Changes++
+		}
+	}
+
+	var result []Release
+	for _, r := range byID {
+		result = append(result, *r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID() < result[j].ID()
+	})
+
+	return result
+}
+
+func index(resources []*resource.Resource) (map[objectKey]*object, error) {
+	objects := make(map[objectKey]*object, len(resources))
+	for _, res := range resources {
+		y, err := res.AsYAML()
+		if err != nil {
+			return nil, err
+		}
+
+		gvk := res.GetGvk()
+		objects[objectKey{
+			Group:     gvk.Group,
+			Kind:      gvk.Kind,
+			Namespace: res.GetNamespace(),
+			Name:      res.GetName(),
+		}] = &object{res: res, yaml: string(y)}
+	}
+
+	return objects, nil
+}
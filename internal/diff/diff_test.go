@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+const baseManifests = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.3.5
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+  namespace: apps
+  labels:
+    helm.toolkit.fluxcd.io/name: podinfo
+    helm.toolkit.fluxcd.io/namespace: apps
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+  namespace: apps
+`
+
+const headManifests = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.4.0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+  namespace: apps
+  labels:
+    helm.toolkit.fluxcd.io/name: podinfo
+    helm.toolkit.fluxcd.io/namespace: apps
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: added
+  namespace: apps
+`
+
+func resources(t *testing.T, manifests string) []*resource.Resource {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	res, err := rf.SliceFromBytes([]byte(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestCompare(t *testing.T) {
+	g := NewWithT(t)
+
+	result, err := Compare(resources(t, baseManifests), resources(t, headManifests))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Empty()).To(BeFalse())
+	g.Expect(result.Changes).To(HaveLen(4))
+
+	g.Expect(result.KindCounts()).To(Equal([]KindCount{
+		{Kind: "ConfigMap", Added: 1, Removed: 1},
+		{Kind: "Deployment", Modified: 1},
+		{Kind: "HelmRelease", Modified: 1},
+	}))
+
+	g.Expect(result.Releases).To(HaveLen(1))
+	release := result.Releases[0]
+	g.Expect(release.ID()).To(Equal("apps/podinfo"))
+	g.Expect(release.VersionBump()).To(BeTrue())
+	g.Expect(release.OldVersion).To(Equal("6.3.5"))
+	g.Expect(release.NewVersion).To(Equal("6.4.0"))
+	g.Expect(release.Changes).To(Equal(1))
+
+	deployment := result.Changes[2]
+	g.Expect(deployment.Release).To(Equal("apps/podinfo"))
+	g.Expect(deployment.Diff).To(ContainSubstring("-  replicas: 1\n+  replicas: 2\n"))
+
+	var md bytes.Buffer
+	g.Expect(result.Markdown(&md, "origin/main")).To(Succeed())
+	g.Expect(md.String()).To(ContainSubstring("| `apps/podinfo` | podinfo | 6.3.5 → 6.4.0 | modified | 1 |"))
+	g.Expect(md.String()).To(ContainSubstring("<summary>modified <code>Deployment apps/podinfo</code> (HelmRelease <code>apps/podinfo</code>)</summary>"))
+}
+
+func TestCompareNoChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	result, err := Compare(resources(t, baseManifests), resources(t, baseManifests))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Empty()).To(BeTrue())
+
+	var md bytes.Buffer
+	g.Expect(result.Markdown(&md, "origin/main")).To(Succeed())
+	g.Expect(md.String()).To(ContainSubstring("No changes detected."))
+}
+
+func TestUnified(t *testing.T) {
+	g := NewWithT(t)
+
+	result, err := Compare(resources(t, baseManifests), resources(t, headManifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var out bytes.Buffer
+	g.Expect(result.Unified(&out)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("# modified Deployment apps/podinfo (HelmRelease apps/podinfo)\n"))
+	g.Expect(out.String()).To(ContainSubstring("-  replicas: 1\n+  replicas: 2\n"))
+	g.Expect(out.String()).To(ContainSubstring("# removed ConfigMap apps/removed\n"))
+}
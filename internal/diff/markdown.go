@@ -0,0 +1,58 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markdown writes a summary of the result suitable as a pull request comment.
+func (r *Result) Markdown(w io.Writer, base string) error {
+	var s strings.Builder
+	fmt.Fprintf(&s, "## flux-build diff against `%s`\n\n", base)
+
+	if r.Empty() {
+		s.WriteString("No changes detected.\n")
+		_, err := io.WriteString(w, s.String())
+		return err
+	}
+
+	if len(r.Releases) > 0 {
+		s.WriteString("### HelmReleases\n\n")
+		s.WriteString("| HelmRelease | Chart | Version | Status | Changed objects |\n")
+		s.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, rel := range r.Releases {
+			version := rel.NewVersion
+			switch {
+			case rel.VersionBump():
+				version = fmt.Sprintf("%s → %s", rel.OldVersion, rel.NewVersion)
+			case rel.Type == Removed:
+				version = rel.OldVersion
+			}
+
+			fmt.Fprintf(&s, "| `%s` | %s | %s | %s | %d |\n", rel.ID(), rel.Chart, version, rel.Type, rel.Changes)
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("### Changes by kind\n\n")
+	s.WriteString("| Kind | Added | Modified | Removed |\n")
+	s.WriteString("| --- | --- | --- | --- |\n")
+	for _, count := range r.KindCounts() {
+		fmt.Fprintf(&s, "| %s | %d | %d | %d |\n", count.Kind, count.Added, count.Modified, count.Removed)
+	}
+	s.WriteString("\n")
+
+	s.WriteString("### Objects\n\n")
+	for _, c := range r.Changes {
+		summary := fmt.Sprintf("%s <code>%s</code>", c.Type, c.ID())
+		if c.Release != "" {
+			summary = fmt.Sprintf("%s (HelmRelease <code>%s</code>)", summary, c.Release)
+		}
+
+		fmt.Fprintf(&s, "<details>\n<summary>%s</summary>\n\n```diff\n%s```\n\n</details>\n\n", summary, c.Diff)
+	}
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
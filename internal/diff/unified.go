@@ -0,0 +1,23 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Unified writes the changes as plain unified diffs, each preceded by a comment line identifying the object.
+func (r *Result) Unified(w io.Writer) error {
+	var s strings.Builder
+	for _, c := range r.Changes {
+		fmt.Fprintf(&s, "# %s %s", c.Type, c.ID())
+		if c.Release != "" {
+			fmt.Fprintf(&s, " (HelmRelease %s)", c.Release)
+		}
+
+		fmt.Fprintf(&s, "\n%s\n", c.Diff)
+	}
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -0,0 +1,128 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Filter restricts which HelmReleases are rendered and which objects are written to the output.
+// An empty filter matches everything.
+type Filter struct {
+	// HelmReleases is a list of namespace/name glob patterns of HelmReleases to render.
+	// A pattern without a namespace matches the release name in any namespace.
+	HelmReleases []string
+	// Namespaces is a list of namespace glob patterns, applies to HelmReleases and output objects.
+	Namespaces []string
+	// Kinds is a list of object kinds to include in the output.
+	Kinds []string
+	// ExcludeKinds is a list of object kinds to exclude from the output.
+	ExcludeKinds []string
+	// Selector selects output objects by their labels.
+	Selector labels.Selector
+}
+
+// New creates a Filter and validates the glob patterns and label selector.
+func New(helmReleases, namespaces, kinds, excludeKinds []string, selector string) (*Filter, error) {
+	f := &Filter{
+		HelmReleases: helmReleases,
+		Namespaces:   namespaces,
+		Kinds:        kinds,
+		ExcludeKinds: excludeKinds,
+	}
+
+	for _, pattern := range append(append([]string{}, helmReleases...), namespaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+		}
+	}
+
+	if selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", selector, err)
+		}
+
+		f.Selector = s
+	}
+
+	return f, nil
+}
+
+// Empty returns true if the filter does not restrict anything.
+func (f *Filter) Empty() bool {
+	return len(f.HelmReleases) == 0 && len(f.Namespaces) == 0 && len(f.Kinds) == 0 &&
+		len(f.ExcludeKinds) == 0 && (f.Selector == nil || f.Selector.Empty())
+}
+
+// MatchRelease returns true if the HelmRelease should be rendered.
+func (f *Filter) MatchRelease(namespace, name string) bool {
+	if len(f.Namespaces) > 0 && !matchAny(f.Namespaces, namespace) {
+		return false
+	}
+
+	if len(f.HelmReleases) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.HelmReleases {
+		// Patterns without a namespace match releases in any namespace
+		subject := name
+		if strings.Contains(pattern, "/") {
+			subject = fmt.Sprintf("%s/%s", namespace, name)
+		}
+
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchObject returns true if the object should be written to the output.
+// The namespaces only apply to namespaced objects, cluster scoped objects are kept.
+func (f *Filter) MatchObject(res *resource.Resource) bool {
+	kind := res.GetKind()
+
+	if len(f.Kinds) > 0 && !containsFold(f.Kinds, kind) {
+		return false
+	}
+
+	if containsFold(f.ExcludeKinds, kind) {
+		return false
+	}
+
+	if namespace := res.GetNamespace(); namespace != "" && len(f.Namespaces) > 0 && !matchAny(f.Namespaces, namespace) {
+		return false
+	}
+
+	if f.Selector != nil && !f.Selector.Matches(labels.Set(res.GetLabels())) {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
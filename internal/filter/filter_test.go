@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+)
+
+func TestMatchRelease(t *testing.T) {
+	tests := []struct {
+		name         string
+		helmReleases []string
+		namespaces   []string
+		namespace    string
+		release      string
+		expect       bool
+	}{
+		{name: "empty filter", namespace: "apps", release: "podinfo", expect: true},
+		{name: "exact match", helmReleases: []string{"apps/podinfo"}, namespace: "apps", release: "podinfo", expect: true},
+		{name: "glob match", helmReleases: []string{"apps/pod*"}, namespace: "apps", release: "podinfo", expect: true},
+		{name: "namespace glob", helmReleases: []string{"*/podinfo"}, namespace: "apps", release: "podinfo", expect: true},
+		{name: "name only", helmReleases: []string{"podinfo"}, namespace: "apps", release: "podinfo", expect: true},
+		{name: "no match", helmReleases: []string{"apps/other", "other/podinfo"}, namespace: "apps", release: "podinfo", expect: false},
+		{name: "namespace match", namespaces: []string{"app*"}, namespace: "apps", release: "podinfo", expect: true},
+		{name: "namespace mismatch", namespaces: []string{"kube-system"}, namespace: "apps", release: "podinfo", expect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			f, err := New(tt.helmReleases, tt.namespaces, nil, nil, "")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(f.MatchRelease(tt.namespace, tt.release)).To(Equal(tt.expect))
+		})
+	}
+}
+
+func TestMatchObject(t *testing.T) {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	res, err := rf.FromBytes([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+  namespace: apps
+  labels:
+    app: podinfo
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		namespaces   []string
+		kinds        []string
+		excludeKinds []string
+		selector     string
+		expect       bool
+	}{
+		{name: "empty filter", expect: true},
+		{name: "kind match", kinds: []string{"Service", "deployment"}, expect: true},
+		{name: "kind mismatch", kinds: []string{"Service"}, expect: false},
+		{name: "excluded kind", excludeKinds: []string{"Deployment"}, expect: false},
+		{name: "namespace mismatch", namespaces: []string{"default"}, expect: false},
+		{name: "selector match", selector: "app=podinfo", expect: true},
+		{name: "selector mismatch", selector: "app in (other)", expect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			f, err := New(nil, tt.namespaces, tt.kinds, tt.excludeKinds, tt.selector)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(f.MatchObject(res)).To(Equal(tt.expect))
+		})
+	}
+}
+
+func TestMatchObjectClusterScoped(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	res, err := rf.FromBytes([]byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: podinfo
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	f, err := New(nil, []string{"apps"}, nil, nil, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.MatchObject(res)).To(BeTrue())
+
+	f, err = New(nil, []string{"apps"}, []string{"Deployment"}, nil, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.MatchObject(res)).To(BeFalse())
+}
+
+func TestNewInvalid(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := New([]string{"apps/[a"}, nil, nil, nil, "")
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = New(nil, nil, nil, nil, "app in (")
+	g.Expect(err).To(HaveOccurred())
+
+	f, err := New(nil, nil, nil, nil, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.Empty()).To(BeTrue())
+}
@@ -0,0 +1,70 @@
+package git
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Export checks out ref of the repository at url into dir without history and returns the commit it resolved to.
+// The default branch is checked out if ref is empty. dir contains no git metadata afterwards.
+func Export(ctx context.Context, url, ref, dir string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", url},
+		{"fetch", "--quiet", "--depth=1", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+		{"submodule", "update", "--quiet", "--init", "--recursive"},
+	} {
+		if _, err := run(ctx, dir, args...); err != nil {
+			return "", err
+		}
+	}
+
+	commit, err := run(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(commit), removeMetadata(dir)
+}
+
+// removeMetadata removes the git metadata of the repository and its submodules within dir.
+func removeMetadata(dir string) error {
+	var metadata []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Name() == ".git" {
+			metadata = append(metadata, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range metadata {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
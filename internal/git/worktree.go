@@ -0,0 +1,111 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// TopLevel returns the root directory of the git working tree containing dir.
+func TopLevel(ctx context.Context, dir string) (string, error) {
+	out, err := run(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// Worktree is a temporary detached git worktree checked out at a given ref.
+type Worktree struct {
+	Path string
+	Ref  string
+	repo string
+}
+
+// AddWorktree checks out ref into a new temporary detached worktree of the repository
+// containing dir. The worktree must be removed by calling Remove.
+func AddWorktree(ctx context.Context, dir, ref string) (*Worktree, error) {
+	repo, err := TopLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := os.MkdirTemp("", "flux-build-worktree")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := run(ctx, repo, "worktree", "add", "--detach", "--force", path, ref); err != nil {
+		_ = os.RemoveAll(path)
+		return nil, err
+	}
+
+	return &Worktree{
+		Path: path,
+		Ref:  ref,
+		repo: repo,
+	}, nil
+}
+
+// Remove deletes the worktree from disk and prunes it from the repository.
+func (w *Worktree) Remove(ctx context.Context) error {
+	_, err := run(ctx, w.repo, "worktree", "remove", "--force", w.Path)
+	if err != nil {
+		_ = os.RemoveAll(w.Path)
+		_, _ = run(ctx, w.repo, "worktree", "prune")
+	}
+
+	return err
+}
+
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// ChangedFiles returns the absolute paths of all files which differ between the merge base of ref and HEAD
+// and the working tree of the repository containing dir, including untracked files.
+func ChangedFiles(ctx context.Context, dir, ref string) ([]string, error) {
+	repo, err := TopLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := run(ctx, repo, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := run(ctx, repo, "diff", "--name-only", "--no-renames", strings.TrimSpace(base))
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := run(ctx, repo, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range strings.Split(changed+untracked, "\n") {
+		if file != "" {
+			files = append(files, filepath.Join(repo, file))
+		}
+	}
+
+	return files, nil
+}
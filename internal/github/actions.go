@@ -0,0 +1,94 @@
+package github
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/doodlescheduling/flux-build/internal/report"
+)
+
+// WriteAnnotations writes a workflow command for each reported error.
+// GitHub shows them inline on the pull request diff if the error carries a file.
+// See https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+func WriteAnnotations(w io.Writer, rep *report.Report) error {
+	for _, err := range rep.Errors {
+		command := "error"
+		if err.Severity == report.SeverityWarning {
+			command = "warning"
+		}
+
+		props := []string{}
+		if err.File != "" {
+			props = append(props, fmt.Sprintf("file=%s", escapeProperty(err.File)))
+			if err.Line > 0 {
+				props = append(props, fmt.Sprintf("line=%d", err.Line))
+			}
+		}
+
+		props = append(props, fmt.Sprintf("title=%s", escapeProperty(fmt.Sprintf("flux-build %s failure", err.Phase))))
+
+		msg := err.Message
+		if target := err.Target(); target != "" {
+			msg = fmt.Sprintf("%s: %s", target, msg)
+		}
+
+		if _, e := fmt.Fprintf(w, "::%s %s::%s\n", command, strings.Join(props, ","), escapeData(msg)); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// WriteStepSummary writes a markdown table with the status of each build target suitable as job step summary.
+func WriteStepSummary(w io.Writer, rep *report.Report) error {
+	var s strings.Builder
+	s.WriteString("## flux-build\n\n")
+
+	failed := 0
+	for _, t := range rep.Targets {
+		for _, err := range rep.TargetErrors(t) {
+			if err.Severity == report.SeverityError {
+				failed++
+				break
+			}
+		}
+	}
+
+	fmt.Fprintf(&s, "%d of %d targets failed.\n\n", failed, len(rep.Targets))
+	s.WriteString("| Target | Status | Phase | Message |\n")
+	s.WriteString("| --- | --- | --- | --- |\n")
+
+	for _, t := range rep.Targets {
+		errs := rep.TargetErrors(t)
+		if len(errs) == 0 {
+			fmt.Fprintf(&s, "| `%s` | :white_check_mark: | | |\n", t)
+			continue
+		}
+
+		for _, err := range errs {
+			status := ":x:"
+			if err.Severity == report.SeverityWarning {
+				status = ":warning:"
+			}
+
+			fmt.Fprintf(&s, "| `%s` | %s | %s | %s |\n", t, status, err.Phase, escapeMarkdown(err.Message))
+		}
+	}
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", "\\|", "\r", "", "\n", "<br>").Replace(s)
+}
@@ -0,0 +1,57 @@
+package github
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/doodlescheduling/flux-build/internal/report"
+	. "github.com/onsi/gomega"
+)
+
+func testReport() *report.Report {
+	r := report.New()
+	r.AddTarget(report.Target{HelmRelease: "apps/podinfo"})
+	r.AddTarget(report.Target{HelmRelease: "apps/redis"})
+	r.AddTarget(report.Target{Path: "apps"})
+	r.Add(&report.Error{
+		Phase:       "fetch",
+		HelmRelease: "apps/podinfo",
+		File:        "apps/podinfo.yaml",
+		Line:        12,
+		Message:     "no chart version found\nfor 9.9.9",
+	})
+	r.Add(&report.Error{
+		Severity: report.SeverityWarning,
+		Phase:    "kustomize",
+		Path:     "apps",
+		Message:  "100% | broken",
+	})
+	return r
+}
+
+func TestWriteAnnotations(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(WriteAnnotations(&buf, testReport())).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`::error file=apps/podinfo.yaml,line=12,title=flux-build fetch failure::helmrelease/apps/podinfo: no chart version found%0Afor 9.9.9
+::warning title=flux-build kustomize failure::path/apps: 100%25 | broken
+`))
+}
+
+func TestWriteStepSummary(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(WriteStepSummary(&buf, testReport())).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`## flux-build
+
+1 of 3 targets failed.
+
+| Target | Status | Phase | Message |
+| --- | --- | --- | --- |
+| ` + "`helmrelease/apps/podinfo`" + ` | :x: | fetch | no chart version found<br>for 9.9.9 |
+| ` + "`helmrelease/apps/redis`" + ` | :white_check_mark: | | |
+| ` + "`path/apps`" + ` | :warning: | kustomize | 100% \| broken |
+`))
+}
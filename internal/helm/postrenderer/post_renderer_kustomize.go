@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"strings"
-	"sync"
 
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/resmap"
@@ -32,13 +31,20 @@ import (
 	helmv2 "github.com/fluxcd/helm-controller/api/v2"
 )
 
+// KustomizeFunc builds the kustomization within dirPath.
+type KustomizeFunc func(fs filesys.FileSystem, dirPath string) (resmap.ResMap, error)
+
 type postRendererKustomize struct {
-	spec *helmv2.Kustomize
+	spec  *helmv2.Kustomize
+	build KustomizeFunc
 }
 
-func NewPostRendererKustomize(spec *helmv2.Kustomize) *postRendererKustomize {
+// NewPostRendererKustomize creates a post renderer which builds the kustomization using build.
+// The kustomization is built by buildKustomization if build is nil.
+func NewPostRendererKustomize(spec *helmv2.Kustomize, build KustomizeFunc) *postRendererKustomize {
 	return &postRendererKustomize{
-		spec: spec,
+		spec:  spec,
+		build: build,
 	}
 }
 
@@ -134,7 +140,12 @@ func (k *postRendererKustomize) Run(renderedManifests *bytes.Buffer) (modifiedMa
 	if err := writeToFile(fs, "kustomization.yaml", kustomization); err != nil {
 		return nil, err
 	}
-	resMap, err := buildKustomization(fs, ".")
+	build := k.build
+	if build == nil {
+		build = buildKustomization
+	}
+
+	resMap, err := build(fs, ".")
 	if err != nil {
 		return nil, err
 	}
@@ -145,19 +156,11 @@ func (k *postRendererKustomize) Run(renderedManifests *bytes.Buffer) (modifiedMa
 	return bytes.NewBuffer(yaml), nil
 }
 
-// TODO: remove mutex when kustomize fixes the concurrent map read/write panic
-var kustomizeRenderMutex sync.Mutex
-
 // buildKustomization wraps krusty.MakeKustomizer with the following settings:
 // - reorder the resources just before output (Namespaces and Cluster roles/role bindings first, CRDs before CRs, Webhooks last)
 // - load files from outside the kustomization.yaml root
 // - disable plugins except for the builtin ones
 func buildKustomization(fs filesys.FileSystem, dirPath string) (resmap.ResMap, error) {
-	// Temporary workaround for concurrent map read and map write bug
-	// https://github.com/kubernetes-sigs/kustomize/issues/3659
-	kustomizeRenderMutex.Lock()
-	defer kustomizeRenderMutex.Unlock()
-
 	buildOptions := &krusty.Options{
 		LoadRestrictions: kustypes.LoadRestrictionsNone,
 		PluginConfig:     kustypes.DisabledPluginConfig(),
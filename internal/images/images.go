@@ -0,0 +1,272 @@
+package images
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// dockerHub is the registry of images without a registry host.
+const dockerHub = "docker.io"
+
+// Container types of a pod spec.
+const (
+	TypeContainer          = "container"
+	TypeInitContainer      = "initContainer"
+	TypeEphemeralContainer = "ephemeralContainer"
+)
+
+// podSpecPaths are the paths to the pod spec of the supported workload kinds.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+var containerFields = []struct {
+	field         string
+	containerType string
+}{
+	{"initContainers", TypeInitContainer},
+	{"containers", TypeContainer},
+	{"ephemeralContainers", TypeEphemeralContainer},
+}
+
+// Image is a container image referenced by a workload.
+type Image struct {
+	// Path is the input path which produced the workload.
+	Path string `json:"path,omitempty"`
+	// HelmRelease is the namespace/name of the HelmRelease which produced the workload.
+	HelmRelease   string `json:"helmRelease,omitempty"`
+	Kind          string `json:"kind"`
+	Namespace     string `json:"namespace,omitempty"`
+	Name          string `json:"name"`
+	Container     string `json:"container"`
+	ContainerType string `json:"containerType"`
+	// Image is the image reference as declared in the container.
+	Image      string `json:"image"`
+	Registry   string `json:"registry,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// Tag is empty if the image is referenced without a tag.
+	Tag    string `json:"tag,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// Workload returns the identity of the workload in the format Kind/[namespace/]name.
+func (i Image) Workload() string {
+	if i.Namespace == "" {
+		return fmt.Sprintf("%s/%s", i.Kind, i.Name)
+	}
+
+	return fmt.Sprintf("%s/%s/%s", i.Kind, i.Namespace, i.Name)
+}
+
+// Extract returns the images of all containers, init containers and ephemeral containers of a workload.
+// Objects which are not a supported workload kind have no images.
+func Extract(res *resource.Resource) ([]Image, error) {
+	specPath, ok := podSpecPaths[res.GetKind()]
+	if !ok {
+		return nil, nil
+	}
+
+	obj, err := res.Map()
+	if err != nil {
+		return nil, err
+	}
+
+	spec, ok := nestedMap(obj, specPath...)
+	if !ok {
+		return nil, nil
+	}
+
+	var images []Image
+	for _, f := range containerFields {
+		containers, _ := spec[f.field].([]interface{})
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			ref, _ := container["image"].(string)
+			containerName, _ := container["name"].(string)
+			img := Image{
+				Kind:          res.GetKind(),
+				Namespace:     res.GetNamespace(),
+				Name:          res.GetName(),
+				Container:     containerName,
+				ContainerType: f.containerType,
+				Image:         ref,
+			}
+
+			if err := img.parse(); err != nil {
+				return images, fmt.Errorf("%s: container %s: %w", img.Workload(), containerName, err)
+			}
+
+			images = append(images, img)
+		}
+	}
+
+	return images, nil
+}
+
+// parse sets the registry, repository, tag and digest from the image reference.
+func (i *Image) parse() error {
+	if i.Image == "" {
+		return fmt.Errorf("no image specified")
+	}
+
+	ref, err := name.ParseReference(i.Image)
+	if err != nil {
+		return err
+	}
+
+	repo := ref.Context()
+	i.Registry = repo.RegistryStr()
+	if i.Registry == name.DefaultRegistry {
+		i.Registry = dockerHub
+	}
+
+	i.Repository = repo.RepositoryStr()
+
+	// ParseReference defaults to latest, the tag is only set if it is part of the reference
+	named, digest, _ := strings.Cut(i.Image, "@")
+	i.Digest = digest
+	if j := strings.LastIndex(named, ":"); j > strings.LastIndex(named, "/") {
+		i.Tag = named[j+1:]
+	}
+
+	return nil
+}
+
+func nestedMap(obj map[string]interface{}, fields ...string) (map[string]interface{}, bool) {
+	for _, field := range fields {
+		next, ok := obj[field].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		obj = next
+	}
+
+	return obj, true
+}
+
+// Policy defines which images are allowed to be used.
+type Policy struct {
+	// DenyLatest denies images without a tag or digest and images with the tag latest.
+	DenyLatest bool
+	// AllowRegistries are glob patterns of allowed registries. Any registry is allowed if empty.
+	AllowRegistries []string
+}
+
+// ValidateRegistries returns an error if any allowed registry is not a valid glob pattern.
+func (p *Policy) ValidateRegistries() error {
+	for _, pattern := range p.AllowRegistries {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid registry pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Check returns the violations of an image against the policy.
+func (p *Policy) Check(img Image) []string {
+	var violations []string
+	if p.DenyLatest && img.Digest == "" {
+		switch img.Tag {
+		case "":
+			violations = append(violations, fmt.Sprintf("image %s is not pinned to a tag or digest", img.Image))
+		case "latest":
+			violations = append(violations, fmt.Sprintf("image %s uses the latest tag", img.Image))
+		}
+	}
+
+	if len(p.AllowRegistries) > 0 && !p.allowRegistry(img.Registry) {
+		violations = append(violations, fmt.Sprintf("image %s is pulled from registry %s which is not allowed", img.Image, img.Registry))
+	}
+
+	return violations
+}
+
+func (p *Policy) allowRegistry(registry string) bool {
+	for _, pattern := range p.AllowRegistries {
+		if ok, _ := path.Match(pattern, registry); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Inventory is the list of images used by a build.
+// It is not safe for concurrent use.
+type Inventory struct {
+	Images []Image `json:"images"`
+}
+
+// Add adds images to the inventory.
+func (inv *Inventory) Add(images ...Image) {
+	inv.Images = append(inv.Images, images...)
+}
+
+// Sort orders the images by build target, workload and container.
+func (inv *Inventory) Sort() {
+	sort.SliceStable(inv.Images, func(i, j int) bool {
+		a, b := inv.Images[i], inv.Images[j]
+		if a.HelmRelease != b.HelmRelease {
+			return a.HelmRelease < b.HelmRelease
+		}
+
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+
+		if a.Workload() != b.Workload() {
+			return a.Workload() < b.Workload()
+		}
+
+		return a.Container < b.Container
+	})
+}
+
+// WriteJSON writes the inventory as JSON.
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	if inv.Images == nil {
+		inv.Images = []Image{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv)
+}
+
+// WriteCSV writes the inventory as CSV with a header row.
+func (inv *Inventory) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "helmRelease", "kind", "namespace", "name", "container", "containerType", "image", "registry", "repository", "tag", "digest"}); err != nil {
+		return err
+	}
+
+	for _, i := range inv.Images {
+		if err := cw.Write([]string{i.Path, i.HelmRelease, i.Kind, i.Namespace, i.Name, i.Container, i.ContainerType, i.Image, i.Registry, i.Repository, i.Tag, i.Digest}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
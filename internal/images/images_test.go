@@ -0,0 +1,151 @@
+package images
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+func parse(g *WithT, manifest string) *resource.Resource {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	res, err := rf.FromBytes([]byte(manifest))
+	g.Expect(err).ToNot(HaveOccurred())
+	return res
+}
+
+func TestExtract(t *testing.T) {
+	g := NewWithT(t)
+
+	images, err := Extract(parse(g, `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: apps
+spec:
+  schedule: "@daily"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          initContainers:
+          - name: init
+            image: busybox
+          containers:
+          - name: backup
+            image: ghcr.io/org/backup:v1.2.0@sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c
+          - name: sidecar
+            image: localhost:5000/sidecar:latest
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(images).To(Equal([]Image{
+		{Kind: "CronJob", Namespace: "apps", Name: "backup", Container: "init", ContainerType: TypeInitContainer, Image: "busybox", Registry: "docker.io", Repository: "library/busybox"},
+		{Kind: "CronJob", Namespace: "apps", Name: "backup", Container: "backup", ContainerType: TypeContainer, Image: "ghcr.io/org/backup:v1.2.0@sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c", Registry: "ghcr.io", Repository: "org/backup", Tag: "v1.2.0", Digest: "sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"},
+		{Kind: "CronJob", Namespace: "apps", Name: "backup", Container: "sidecar", ContainerType: TypeContainer, Image: "localhost:5000/sidecar:latest", Registry: "localhost:5000", Repository: "sidecar", Tag: "latest"},
+	}))
+}
+
+func TestExtractUnsupportedKind(t *testing.T) {
+	g := NewWithT(t)
+
+	images, err := Extract(parse(g, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  image: nginx
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(images).To(BeEmpty())
+}
+
+func TestExtractInvalidImage(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Extract(parse(g, `apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+spec:
+  containers:
+  - name: app
+    image: Invalid Image
+`))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("Pod/pod: container app"))
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     Policy
+		image      Image
+		violations []string
+	}{
+		{
+			name:   "untagged",
+			policy: Policy{DenyLatest: true},
+			image:  Image{Image: "nginx", Registry: "docker.io"},
+			violations: []string{
+				"image nginx is not pinned to a tag or digest",
+			},
+		},
+		{
+			name:   "latest",
+			policy: Policy{DenyLatest: true},
+			image:  Image{Image: "nginx:latest", Registry: "docker.io", Tag: "latest"},
+			violations: []string{
+				"image nginx:latest uses the latest tag",
+			},
+		},
+		{
+			name:   "latest pinned to digest",
+			policy: Policy{DenyLatest: true},
+			image:  Image{Image: "nginx:latest@sha256:abc", Registry: "docker.io", Tag: "latest", Digest: "sha256:abc"},
+		},
+		{
+			name:   "allowed registry",
+			policy: Policy{AllowRegistries: []string{"ghcr.io", "*.dkr.ecr.*.amazonaws.com"}},
+			image:  Image{Image: "123.dkr.ecr.eu-west-1.amazonaws.com/app:v1", Registry: "123.dkr.ecr.eu-west-1.amazonaws.com", Tag: "v1"},
+		},
+		{
+			name:   "registry not allowed",
+			policy: Policy{AllowRegistries: []string{"ghcr.io"}},
+			image:  Image{Image: "nginx:1.27", Registry: "docker.io", Tag: "1.27"},
+			violations: []string{
+				"image nginx:1.27 is pulled from registry docker.io which is not allowed",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(test.policy.Check(test.image)).To(Equal(test.violations))
+		})
+	}
+}
+
+func TestInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	inv := &Inventory{}
+	inv.Add(
+		Image{HelmRelease: "apps/b", Kind: "Deployment", Namespace: "apps", Name: "b", Container: "app", ContainerType: TypeContainer, Image: "nginx:1.27", Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"},
+		Image{Path: "clusters/prod", Kind: "Pod", Name: "a", Container: "app", ContainerType: TypeContainer, Image: "ghcr.io/org/a:v1", Registry: "ghcr.io", Repository: "org/a", Tag: "v1"},
+	)
+	inv.Sort()
+
+	var csv bytes.Buffer
+	g.Expect(inv.WriteCSV(&csv)).To(Succeed())
+	g.Expect(csv.String()).To(Equal(`path,helmRelease,kind,namespace,name,container,containerType,image,registry,repository,tag,digest
+clusters/prod,,Pod,,a,app,container,ghcr.io/org/a:v1,ghcr.io,org/a,v1,
+,apps/b,Deployment,apps,b,app,container,nginx:1.27,docker.io,library/nginx,1.27,
+`))
+
+	var json bytes.Buffer
+	g.Expect((&Inventory{}).WriteJSON(&json)).To(Succeed())
+	g.Expect(json.String()).To(Equal("{\n  \"images\": []\n}\n"))
+}
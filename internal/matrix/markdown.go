@@ -0,0 +1,49 @@
+package matrix
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markdown writes a summary of the result suitable as a pull request comment.
+func (r *Result) Markdown(w io.Writer) error {
+	var s strings.Builder
+	s.WriteString("## flux-build kube version matrix\n\n")
+
+	if r.Empty() {
+		fmt.Fprintf(&s, "All HelmReleases build and render the same output with kube versions %s.\n", strings.Join(r.KubeVersions, ", "))
+		_, err := io.WriteString(w, s.String())
+		return err
+	}
+
+	if len(r.Releases) > 0 {
+		s.WriteString("### HelmReleases\n\n")
+		fmt.Fprintf(&s, "| HelmRelease | %s |\n", strings.Join(r.KubeVersions, " | "))
+		fmt.Fprintf(&s, "| --- |%s\n", strings.Repeat(" --- |", len(r.KubeVersions)))
+		for _, rel := range r.Releases {
+			cells := make([]string, len(rel.Status))
+			for i := range rel.Status {
+				cells[i] = rel.cell(i)
+			}
+
+			fmt.Fprintf(&s, "| `%s` | %s |\n", rel.ID, strings.Join(cells, " | "))
+		}
+		s.WriteString("\n")
+	}
+
+	if len(r.Changes) > 0 {
+		s.WriteString("### Objects\n\n")
+		for _, c := range r.Changes {
+			summary := fmt.Sprintf("%s <code>%s</code> (%s → %s)", c.Type, c.ID(), c.From, c.To)
+			if c.Release != "" {
+				summary = fmt.Sprintf("%s (HelmRelease <code>%s</code>)", summary, c.Release)
+			}
+
+			fmt.Fprintf(&s, "<details>\n<summary>%s</summary>\n\n```diff\n%s```\n\n</details>\n\n", summary, c.Diff)
+		}
+	}
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -0,0 +1,134 @@
+package matrix
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/doodlescheduling/flux-build/internal/diff"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Status describes how a HelmRelease builds at a single Kubernetes version.
+type Status string
+
+const (
+	// StatusOK means the release renders the same output as at the previous version.
+	StatusOK Status = "ok"
+	// StatusChanged means the release renders a different output than at the previous version.
+	StatusChanged Status = "changed"
+	// StatusFailed means the release fails to build.
+	StatusFailed Status = "failed"
+)
+
+// Build is the output of a build at a single Kubernetes version.
+type Build struct {
+	KubeVersion string
+	Resources   []*resource.Resource
+	// Failed are the namespace/name of the HelmReleases which failed to build.
+	Failed []string
+}
+
+// Release is the status of a single HelmRelease at each Kubernetes version.
+type Release struct {
+	// ID is the namespace/name of the HelmRelease.
+	ID string
+	// Status is the status at each version in the order of the builds.
+	Status []Status
+	// Changes is the number of objects which differ from the previous version in the order of the builds.
+	Changes []int
+}
+
+// Change is an object which differs between two consecutive Kubernetes versions.
+type Change struct {
+	diff.Change
+	From string
+	To   string
+}
+
+// Result is the comparison of builds at multiple Kubernetes versions.
+type Result struct {
+	KubeVersions []string
+	// Releases are the HelmReleases which fail or change at any version.
+	Releases []Release
+	Changes  []Change
+}
+
+// Compare compares each build against the build of the previous Kubernetes version.
+// Objects of releases which fail at either version are not compared.
+func Compare(builds []Build) (*Result, error) {
+	result := &Result{}
+	byID := make(map[string]*Release)
+	get := func(id string) *Release {
+		if r, ok := byID[id]; ok {
+			return r
+		}
+
+		r := &Release{
+			ID:      id,
+			Status:  make([]Status, len(builds)),
+			Changes: make([]int, len(builds)),
+		}
+
+		for i := range r.Status {
+			r.Status[i] = StatusOK
+		}
+
+		byID[id] = r
+		return r
+	}
+
+	failed := make([]map[string]bool, len(builds))
+	for i, b := range builds {
+		result.KubeVersions = append(result.KubeVersions, b.KubeVersion)
+		failed[i] = make(map[string]bool)
+		for _, id := range b.Failed {
+			failed[i][id] = true
+			get(id).Status[i] = StatusFailed
+		}
+	}
+
+	for i := 1; i < len(builds); i++ {
+		d, err := diff.Compare(builds[i-1].Resources, builds[i].Resources)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range d.Changes {
+			if failed[i-1][c.Release] || failed[i][c.Release] {
+				continue
+			}
+
+			result.Changes = append(result.Changes, Change{Change: c, From: builds[i-1].KubeVersion, To: builds[i].KubeVersion})
+			if c.Release == "" {
+				continue
+			}
+
+			r := get(c.Release)
+			r.Status[i] = StatusChanged
+			r.Changes[i]++
+		}
+	}
+
+	for _, r := range byID {
+		result.Releases = append(result.Releases, *r)
+	}
+
+	sort.Slice(result.Releases, func(i, j int) bool {
+		return result.Releases[i].ID < result.Releases[j].ID
+	})
+
+	return result, nil
+}
+
+// Empty returns true if all releases build and render the same output at all versions.
+func (r *Result) Empty() bool {
+	return len(r.Releases) == 0 && len(r.Changes) == 0
+}
+
+func (r Release) cell(i int) string {
+	if r.Status[i] == StatusChanged {
+		return fmt.Sprintf("%s (%d)", r.Status[i], r.Changes[i])
+	}
+
+	return string(r.Status[i])
+}
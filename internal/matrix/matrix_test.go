@@ -0,0 +1,92 @@
+package matrix
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+const oldManifests = `apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: podinfo
+  namespace: apps
+  labels:
+    helm.toolkit.fluxcd.io/name: podinfo
+    helm.toolkit.fluxcd.io/namespace: apps
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: redis
+  namespace: apps
+  labels:
+    helm.toolkit.fluxcd.io/name: redis
+    helm.toolkit.fluxcd.io/namespace: apps
+`
+
+const newManifests = `apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: podinfo
+  namespace: apps
+  labels:
+    helm.toolkit.fluxcd.io/name: podinfo
+    helm.toolkit.fluxcd.io/namespace: apps
+`
+
+func resources(t *testing.T, manifests string) []*resource.Resource {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	res, err := rf.SliceFromBytes([]byte(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestCompare(t *testing.T) {
+	g := NewWithT(t)
+
+	result, err := Compare([]Build{
+		{KubeVersion: "v1.24.0", Resources: resources(t, oldManifests)},
+		{KubeVersion: "v1.25.0", Resources: resources(t, newManifests), Failed: []string{"apps/redis"}},
+		{KubeVersion: "v1.26.0", Resources: resources(t, newManifests), Failed: []string{"apps/redis"}},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Empty()).To(BeFalse())
+
+	g.Expect(result.Releases).To(Equal([]Release{
+		{ID: "apps/podinfo", Status: []Status{StatusOK, StatusChanged, StatusOK}, Changes: []int{0, 1, 0}},
+		{ID: "apps/redis", Status: []Status{StatusOK, StatusFailed, StatusFailed}, Changes: []int{0, 0, 0}},
+	}))
+
+	g.Expect(result.Changes).To(HaveLen(1))
+	g.Expect(result.Changes[0].From).To(Equal("v1.24.0"))
+	g.Expect(result.Changes[0].To).To(Equal("v1.25.0"))
+
+	var md bytes.Buffer
+	g.Expect(result.Markdown(&md)).To(Succeed())
+	g.Expect(md.String()).To(ContainSubstring("| HelmRelease | v1.24.0 | v1.25.0 | v1.26.0 |\n| --- | --- | --- | --- |\n"))
+	g.Expect(md.String()).To(ContainSubstring("| `apps/podinfo` | ok | changed (1) | ok |"))
+	g.Expect(md.String()).To(ContainSubstring("| `apps/redis` | ok | failed | failed |"))
+	g.Expect(md.String()).To(ContainSubstring("(v1.24.0 → v1.25.0) (HelmRelease <code>apps/podinfo</code>)"))
+}
+
+func TestCompareNoChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	result, err := Compare([]Build{
+		{KubeVersion: "v1.30.0", Resources: resources(t, newManifests)},
+		{KubeVersion: "v1.31.0", Resources: resources(t, newManifests)},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Empty()).To(BeTrue())
+
+	var md bytes.Buffer
+	g.Expect(result.Markdown(&md)).To(Succeed())
+	g.Expect(md.String()).To(ContainSubstring("All HelmReleases build and render the same output with kube versions v1.30.0, v1.31.0."))
+}
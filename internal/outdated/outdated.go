@@ -0,0 +1,129 @@
+package outdated
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Update is the kind of update which brings a chart to the latest version.
+type Update string
+
+const (
+	UpdateNone  Update = "none"
+	UpdatePatch Update = "patch"
+	UpdateMinor Update = "minor"
+	UpdateMajor Update = "major"
+)
+
+var updateRank = map[Update]int{
+	UpdateNone:  0,
+	UpdatePatch: 1,
+	UpdateMinor: 2,
+	UpdateMajor: 3,
+}
+
+// ParseUpdate converts a string into an Update. An empty string equals UpdateNone.
+func ParseUpdate(s string) (Update, error) {
+	if s == "" {
+		return UpdateNone, nil
+	}
+
+	if _, ok := updateRank[Update(s)]; !ok {
+		return UpdateNone, fmt.Errorf("unsupported update %q, expected one of none, patch, minor, major", s)
+	}
+
+	return Update(s), nil
+}
+
+// AtLeast returns true if the update is as significant as o or more.
+func (u Update) AtLeast(o Update) bool {
+	return updateRank[u] >= updateRank[o]
+}
+
+// Classify returns the kind of update from the current to the latest version.
+// UpdateNone is returned if latest is not newer than current.
+func Classify(current, latest string) (Update, error) {
+	c, err := semver.NewVersion(current)
+	if err != nil {
+		return UpdateNone, fmt.Errorf("invalid version %q: %w", current, err)
+	}
+
+	l, err := semver.NewVersion(latest)
+	if err != nil {
+		return UpdateNone, fmt.Errorf("invalid version %q: %w", latest, err)
+	}
+
+	switch {
+	case !c.LessThan(l):
+		return UpdateNone, nil
+	case l.Major() != c.Major():
+		return UpdateMajor, nil
+	case l.Minor() != c.Minor():
+		return UpdateMinor, nil
+	}
+
+	return UpdatePatch, nil
+}
+
+// Release is the chart version of a HelmRelease compared to the latest version available in its repository.
+type Release struct {
+	// HelmRelease is the namespace/name of the HelmRelease.
+	HelmRelease string `json:"helmRelease"`
+	Chart       string `json:"chart"`
+	Repository  string `json:"repository"`
+	// Constraint is the version or semver range requested by the HelmRelease.
+	Constraint string `json:"constraint,omitempty"`
+	// Current is the version the constraint resolves to.
+	Current string `json:"current"`
+	// Latest is the latest stable version available in the repository.
+	Latest string `json:"latest"`
+	Update Update `json:"update"`
+}
+
+func (r Release) String() string {
+	if r.Update == UpdateNone {
+		return fmt.Sprintf("chart %s %s is up to date", r.Chart, r.Current)
+	}
+
+	return fmt.Sprintf("chart %s %s is outdated, %s update to %s available", r.Chart, r.Current, r.Update, r.Latest)
+}
+
+// Report lists the chart versions of all HelmReleases. It is safe for concurrent use.
+type Report struct {
+	mu       sync.Mutex
+	Releases []Release `json:"releases"`
+}
+
+// Add adds a release to the report.
+func (r *Report) Add(release Release) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Releases = append(r.Releases, release)
+}
+
+// Sort orders the releases by HelmRelease.
+func (r *Report) Sort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.SliceStable(r.Releases, func(i, j int) bool {
+		return r.Releases[i].HelmRelease < r.Releases[j].HelmRelease
+	})
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Releases == nil {
+		r.Releases = []Release{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
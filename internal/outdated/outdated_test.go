@@ -0,0 +1,81 @@
+package outdated
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		current string
+		latest  string
+		update  Update
+	}{
+		{current: "1.2.3", latest: "1.2.3", update: UpdateNone},
+		{current: "1.2.3", latest: "1.2.4", update: UpdatePatch},
+		{current: "1.2.3", latest: "1.3.0", update: UpdateMinor},
+		{current: "1.2.3", latest: "2.0.0", update: UpdateMajor},
+		{current: "v0.1.0", latest: "0.2.0", update: UpdateMinor},
+		{current: "2.0.0-rc.1", latest: "1.9.0", update: UpdateNone},
+		{current: "1.0.0-rc.1", latest: "1.0.0", update: UpdatePatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.current+"->"+test.latest, func(t *testing.T) {
+			g := NewWithT(t)
+
+			update, err := Classify(test.current, test.latest)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(update).To(Equal(test.update))
+		})
+	}
+}
+
+func TestClassifyInvalidVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Classify("latest", "1.0.0")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestParseUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	update, err := ParseUpdate("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(update).To(Equal(UpdateNone))
+
+	update, err = ParseUpdate("minor")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(update).To(Equal(UpdateMinor))
+
+	_, err = ParseUpdate("breaking")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestAtLeast(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(UpdateMajor.AtLeast(UpdateMinor)).To(BeTrue())
+	g.Expect(UpdateMinor.AtLeast(UpdateMinor)).To(BeTrue())
+	g.Expect(UpdatePatch.AtLeast(UpdateMinor)).To(BeFalse())
+	g.Expect(UpdateNone.AtLeast(UpdatePatch)).To(BeFalse())
+}
+
+func TestReport(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &Report{}
+	r.Add(Release{HelmRelease: "monitoring/grafana", Chart: "grafana", Repository: "https://grafana.github.io/helm-charts/", Constraint: "8.x", Current: "8.5.1", Latest: "8.5.1", Update: UpdateNone})
+	r.Add(Release{HelmRelease: "apps/redis", Chart: "redis", Repository: "oci://registry-1.docker.io/bitnamicharts", Constraint: "18.1.6", Current: "18.1.6", Latest: "20.0.1", Update: UpdateMajor})
+	r.Sort()
+
+	g.Expect(r.Releases[0].String()).To(Equal("chart redis 18.1.6 is outdated, major update to 20.0.1 available"))
+	g.Expect(r.Releases[1].String()).To(Equal("chart grafana 8.5.1 is up to date"))
+
+	var buf bytes.Buffer
+	g.Expect(r.WriteJSON(&buf)).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring(`"update": "major"`))
+}
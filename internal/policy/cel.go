@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/cel/environment"
+	"k8s.io/apiserver/pkg/cel/library"
+)
+
+const (
+	varObject          = "object"
+	varOldObject       = "oldObject"
+	varRequest         = "request"
+	varParams          = "params"
+	varNamespaceObject = "namespaceObject"
+	varVariables       = "variables"
+	varAuthorizer      = "authorizer"
+	varRequestResource = "authorizer.requestResource"
+)
+
+// denyReason is the reason of all authorization checks, there is no cluster to ask.
+const denyReason = "authorization checks are not supported without a cluster"
+
+// denyAll is the authorizer behind the authorizer variables, every check is denied.
+var denyAll = authorizer.AuthorizerFunc(func(ctx context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+	return authorizer.DecisionDeny, denyReason, nil
+})
+
+// requestUser is the user of the request variable.
+var requestUser = &user.DefaultInfo{Name: "flux-build"}
+
+// newEnv creates a CEL environment with the kubernetes CEL libraries and
+// the variables available to ValidatingAdmissionPolicy expressions.
+func newEnv() (*cel.Env, error) {
+	envSet, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).Extend(environment.VersionedOptions{
+		IntroducedVersion: environment.DefaultCompatibilityVersion(),
+		EnvOptions: []cel.EnvOption{
+			cel.Variable(varObject, cel.DynType),
+			cel.Variable(varOldObject, cel.DynType),
+			cel.Variable(varRequest, cel.DynType),
+			cel.Variable(varParams, cel.DynType),
+			cel.Variable(varNamespaceObject, cel.DynType),
+			cel.Variable(varVariables, cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable(varAuthorizer, library.AuthorizerType),
+			cel.Variable(varRequestResource, library.ResourceCheckType),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return envSet.Env(environment.StoredExpressions)
+}
+
+func compile(env *cel.Env, expression string) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to compile expression %q: %w", expression, issues.Err())
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expression %q: %w", expression, err)
+	}
+
+	return prg, nil
+}
+
+func evalBool(prg cel.Program, activation map[string]interface{}) (bool, error) {
+	val, _, err := prg.Eval(activation)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := val.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression must evaluate to bool, got %s", val.Type())
+	}
+
+	return bool(b), nil
+}
+
+func eval(prg cel.Program, activation map[string]interface{}) (ref.Val, error) {
+	val, _, err := prg.Eval(activation)
+	return val, err
+}
+
+// requestResource is the resource of the request checked by authorizer.requestResource.
+type requestResource struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+func (r requestResource) GetName() string                          { return r.name }
+func (r requestResource) GetNamespace() string                     { return r.namespace }
+func (r requestResource) GetResource() schema.GroupVersionResource { return r.gvr }
+func (r requestResource) GetSubresource() string                   { return "" }
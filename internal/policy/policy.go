@@ -0,0 +1,548 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/cel/library"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+var (
+	policyGVK    = admissionregistrationv1.SchemeGroupVersion.WithKind("ValidatingAdmissionPolicy")
+	bindingGVK   = admissionregistrationv1.SchemeGroupVersion.WithKind("ValidatingAdmissionPolicyBinding")
+	crdGVK       = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+	namespaceGVK = schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+)
+
+// Result is a failed validation of an object.
+type Result struct {
+	Policy  string
+	Binding string
+	// Action is either Deny or Warn depending on the validation actions of the binding.
+	Action  admissionregistrationv1.ValidationAction
+	Message string
+}
+
+func (r Result) String() string {
+	if r.Action == admissionregistrationv1.Warn {
+		return fmt.Sprintf("Validation failed for ValidatingAdmissionPolicy '%s' with binding '%s': %s", r.Policy, r.Binding, r.Message)
+	}
+
+	return fmt.Sprintf("ValidatingAdmissionPolicy '%s' with binding '%s' denied request: %s", r.Policy, r.Binding, r.Message)
+}
+
+type compiledPolicy struct {
+	*admissionregistrationv1.ValidatingAdmissionPolicy
+	matchConditions []cel.Program
+	variables       []namedProgram
+	validations     []compiledValidation
+}
+
+type namedProgram struct {
+	name string
+	prg  cel.Program
+}
+
+type compiledValidation struct {
+	admissionregistrationv1.Validation
+	prg     cel.Program
+	message cel.Program
+}
+
+// Evaluator evaluates ValidatingAdmissionPolicies offline against objects.
+// All objects of a build need to be added before evaluating, they are used to look up
+// policies, bindings, params, namespaces and resource names of custom resources.
+// Objects are evaluated as if they were created.
+type Evaluator struct {
+	env        *cel.Env
+	policies   map[string]*compiledPolicy
+	bindings   []*admissionregistrationv1.ValidatingAdmissionPolicyBinding
+	namespaces map[string]*unstructured.Unstructured
+	resources  map[schema.GroupKind]string
+	objects    []*unstructured.Unstructured
+}
+
+// New creates an empty Evaluator.
+func New() (*Evaluator, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluator{
+		env:        env,
+		policies:   make(map[string]*compiledPolicy),
+		namespaces: make(map[string]*unstructured.Unstructured),
+		resources:  make(map[schema.GroupKind]string),
+	}, nil
+}
+
+// LoadDir adds all objects of the yaml files within dir.
+func (e *Evaluator) LoadDir(dir string) error {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		resources, err := rf.SliceFromBytes(b)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		for _, res := range resources {
+			if err := e.Add(res); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Add registers an object. ValidatingAdmissionPolicies are compiled once added.
+func (e *Evaluator) Add(res *resource.Resource) error {
+	obj, err := toUnstructured(res)
+	if err != nil {
+		return err
+	}
+
+	e.objects = append(e.objects, obj)
+
+	switch obj.GroupVersionKind() {
+	case policyGVK:
+		var policy admissionregistrationv1.ValidatingAdmissionPolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &policy); err != nil {
+			return fmt.Errorf("failed to decode ValidatingAdmissionPolicy %s: %w", obj.GetName(), err)
+		}
+
+		compiled, err := e.compilePolicy(&policy)
+		if err != nil {
+			return fmt.Errorf("invalid ValidatingAdmissionPolicy %s: %w", policy.Name, err)
+		}
+
+		e.policies[policy.Name] = compiled
+	case bindingGVK:
+		var binding admissionregistrationv1.ValidatingAdmissionPolicyBinding
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &binding); err != nil {
+			return fmt.Errorf("failed to decode ValidatingAdmissionPolicyBinding %s: %w", obj.GetName(), err)
+		}
+
+		e.bindings = append(e.bindings, &binding)
+	case namespaceGVK:
+		e.namespaces[obj.GetName()] = obj
+	case crdGVK:
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "plural")
+		e.resources[schema.GroupKind{Group: group, Kind: kind}] = plural
+	}
+
+	return nil
+}
+
+func (e *Evaluator) compilePolicy(policy *admissionregistrationv1.ValidatingAdmissionPolicy) (*compiledPolicy, error) {
+	compiled := &compiledPolicy{ValidatingAdmissionPolicy: policy}
+
+	for _, condition := range policy.Spec.MatchConditions {
+		prg, err := compile(e.env, condition.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled.matchConditions = append(compiled.matchConditions, prg)
+	}
+
+	for _, variable := range policy.Spec.Variables {
+		prg, err := compile(e.env, variable.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled.variables = append(compiled.variables, namedProgram{name: variable.Name, prg: prg})
+	}
+
+	for _, validation := range policy.Spec.Validations {
+		v := compiledValidation{Validation: validation}
+		prg, err := compile(e.env, validation.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		v.prg = prg
+		if validation.MessageExpression != "" {
+			if v.message, err = compile(e.env, validation.MessageExpression); err != nil {
+				return nil, err
+			}
+		}
+
+		compiled.validations = append(compiled.validations, v)
+	}
+
+	return compiled, nil
+}
+
+// Evaluate evaluates all bound policies matching the object.
+func (e *Evaluator) Evaluate(res *resource.Resource) ([]Result, error) {
+	obj, err := toUnstructured(res)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := append([]*admissionregistrationv1.ValidatingAdmissionPolicyBinding{}, e.bindings...)
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	var results []Result
+	for _, binding := range bindings {
+		policy, ok := e.policies[binding.Spec.PolicyName]
+		if !ok || policy.Spec.MatchConstraints == nil {
+			continue
+		}
+
+		if !e.matches(policy.Spec.MatchConstraints, obj, true) {
+			continue
+		}
+
+		if binding.Spec.MatchResources != nil && !e.matches(binding.Spec.MatchResources, obj, false) {
+			continue
+		}
+
+		var messages []string
+		if policy.Spec.ParamKind == nil {
+			messages = e.evaluatePolicy(policy, obj, nil)
+		} else {
+			params := e.params(policy.Spec.ParamKind, binding.Spec.ParamRef, obj)
+			if len(params) == 0 && (binding.Spec.ParamRef == nil || binding.Spec.ParamRef.ParameterNotFoundAction == nil || *binding.Spec.ParamRef.ParameterNotFoundAction == admissionregistrationv1.DenyAction) {
+				messages = append(messages, fmt.Sprintf("failed to configure binding: no params found for %s %s", policy.Spec.ParamKind.APIVersion, policy.Spec.ParamKind.Kind))
+			}
+
+			for _, param := range params {
+				messages = append(messages, e.evaluatePolicy(policy, obj, param.Object)...)
+			}
+		}
+
+		for _, action := range binding.Spec.ValidationActions {
+			if action != admissionregistrationv1.Deny && action != admissionregistrationv1.Warn {
+				continue
+			}
+
+			for _, msg := range messages {
+				results = append(results, Result{
+					Policy:  policy.Name,
+					Binding: binding.Name,
+					Action:  action,
+					Message: msg,
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// evaluatePolicy returns a message for each failed validation.
+func (e *Evaluator) evaluatePolicy(policy *compiledPolicy, obj *unstructured.Unstructured, params map[string]interface{}) []string {
+	failurePolicy := admissionregistrationv1.Fail
+	if policy.Spec.FailurePolicy != nil {
+		failurePolicy = *policy.Spec.FailurePolicy
+	}
+
+	onError := func(expression string, err error) []string {
+		if failurePolicy == admissionregistrationv1.Ignore {
+			return nil
+		}
+
+		return []string{fmt.Sprintf("expression '%s' resulted in error: %s", expression, err)}
+	}
+
+	variables := make(map[string]interface{})
+	activation := map[string]interface{}{
+		varObject:          obj.Object,
+		varOldObject:       nil,
+		varRequest:         e.request(obj),
+		varParams:          nil,
+		varNamespaceObject: nil,
+		varVariables:       variables,
+		varAuthorizer:      library.NewAuthorizerVal(requestUser, denyAll),
+		varRequestResource: library.NewResourceAuthorizerVal(requestUser, denyAll, requestResource{
+			gvr:       e.resource(obj.GroupVersionKind()),
+			namespace: obj.GetNamespace(),
+			name:      obj.GetName(),
+		}),
+	}
+
+	if params != nil {
+		activation[varParams] = params
+	}
+
+	if ns, ok := e.namespaces[obj.GetNamespace()]; ok {
+		activation[varNamespaceObject] = ns.Object
+	}
+
+	for i, prg := range policy.matchConditions {
+		ok, err := evalBool(prg, activation)
+		if err != nil {
+			return onError(policy.Spec.MatchConditions[i].Expression, err)
+		}
+
+		if !ok {
+			return nil
+		}
+	}
+
+	for i, variable := range policy.variables {
+		val, err := eval(variable.prg, activation)
+		if err != nil {
+			return onError(policy.Spec.Variables[i].Expression, err)
+		}
+
+		variables[variable.name] = val
+	}
+
+	var messages []string
+	for _, validation := range policy.validations {
+		ok, err := evalBool(validation.prg, activation)
+		if err != nil {
+			messages = append(messages, onError(validation.Expression, err)...)
+			continue
+		}
+
+		if ok {
+			continue
+		}
+
+		msg := validation.Message
+		if validation.message != nil {
+			if val, err := eval(validation.message, activation); err == nil {
+				if s, ok := val.(types.String); ok && strings.TrimSpace(string(s)) != "" {
+					msg = string(s)
+				}
+			}
+		}
+
+		if msg == "" {
+			msg = fmt.Sprintf("failed expression: %s", validation.Expression)
+		}
+
+		if validation.Reason != nil {
+			msg = fmt.Sprintf("%s (reason: %s)", msg, *validation.Reason)
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+func (e *Evaluator) request(obj *unstructured.Unstructured) map[string]interface{} {
+	gvk := obj.GroupVersionKind()
+	gvr := e.resource(gvk)
+
+	return map[string]interface{}{
+		"kind": map[string]interface{}{
+			"group":   gvk.Group,
+			"version": gvk.Version,
+			"kind":    gvk.Kind,
+		},
+		"resource": map[string]interface{}{
+			"group":    gvr.Group,
+			"version":  gvr.Version,
+			"resource": gvr.Resource,
+		},
+		"name":      obj.GetName(),
+		"namespace": obj.GetNamespace(),
+		"operation": string(admissionregistrationv1.Create),
+		"userInfo": map[string]interface{}{
+			"username": requestUser.Name,
+		},
+		"dryRun": true,
+	}
+}
+
+// resource returns the resource of a kind, either from a CustomResourceDefinition of the build
+// or guessed from the kind.
+func (e *Evaluator) resource(gvk schema.GroupVersionKind) schema.GroupVersionResource {
+	if plural, ok := e.resources[gvk.GroupKind()]; ok {
+		return gvk.GroupVersion().WithResource(plural)
+	}
+
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return gvr
+}
+
+func (e *Evaluator) matches(match *admissionregistrationv1.MatchResources, obj *unstructured.Unstructured, requireRules bool) bool {
+	if match.NamespaceSelector != nil && !e.matchNamespace(match.NamespaceSelector, obj) {
+		return false
+	}
+
+	if match.ObjectSelector != nil && !matchLabels(match.ObjectSelector, obj.GetLabels()) {
+		return false
+	}
+
+	gvr := e.resource(obj.GroupVersionKind())
+	if len(match.ResourceRules) > 0 || requireRules {
+		matched := false
+		for _, rule := range match.ResourceRules {
+			if matchRule(rule, gvr, obj) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	for _, rule := range match.ExcludeResourceRules {
+		if matchRule(rule, gvr, obj) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *Evaluator) matchNamespace(selector *metav1.LabelSelector, obj *unstructured.Unstructured) bool {
+	if obj.GroupVersionKind() == namespaceGVK {
+		return matchLabels(selector, obj.GetLabels())
+	}
+
+	// The namespace selector does not apply to cluster scoped objects
+	if obj.GetNamespace() == "" {
+		return true
+	}
+
+	nsLabels := map[string]string{}
+	if ns, ok := e.namespaces[obj.GetNamespace()]; ok {
+		for k, v := range ns.GetLabels() {
+			nsLabels[k] = v
+		}
+	}
+
+	nsLabels["kubernetes.io/metadata.name"] = obj.GetNamespace()
+	return matchLabels(selector, nsLabels)
+}
+
+func (e *Evaluator) params(paramKind *admissionregistrationv1.ParamKind, paramRef *admissionregistrationv1.ParamRef, obj *unstructured.Unstructured) []*unstructured.Unstructured {
+	if paramRef == nil {
+		return nil
+	}
+
+	var params []*unstructured.Unstructured
+	for _, candidate := range e.objects {
+		if candidate.GetAPIVersion() != paramKind.APIVersion || candidate.GetKind() != paramKind.Kind {
+			continue
+		}
+
+		switch {
+		case paramRef.Namespace != "" && candidate.GetNamespace() != paramRef.Namespace:
+			continue
+		case paramRef.Namespace == "" && candidate.GetNamespace() != "" && candidate.GetNamespace() != obj.GetNamespace():
+			continue
+		case paramRef.Name != "" && candidate.GetName() != paramRef.Name:
+			continue
+		case paramRef.Selector != nil && !matchLabels(paramRef.Selector, candidate.GetLabels()):
+			continue
+		}
+
+		params = append(params, candidate)
+	}
+
+	return params
+}
+
+func matchRule(rule admissionregistrationv1.NamedRuleWithOperations, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) bool {
+	if !containsAny(operations(rule.Operations), "*", string(admissionregistrationv1.Create)) {
+		return false
+	}
+
+	if !containsAny(rule.APIGroups, "*", gvr.Group) || !containsAny(rule.APIVersions, "*", gvr.Version) {
+		return false
+	}
+
+	if !containsAny(rule.Resources, "*", "*/*", gvr.Resource) {
+		return false
+	}
+
+	if rule.Scope != nil {
+		switch *rule.Scope {
+		case admissionregistrationv1.ClusterScope:
+			if obj.GetNamespace() != "" {
+				return false
+			}
+		case admissionregistrationv1.NamespacedScope:
+			if obj.GetNamespace() == "" {
+				return false
+			}
+		}
+	}
+
+	return len(rule.ResourceNames) == 0 || containsAny(rule.ResourceNames, obj.GetName())
+}
+
+func operations(ops []admissionregistrationv1.OperationType) []string {
+	var s []string
+	for _, op := range ops {
+		s = append(s, string(op))
+	}
+
+	return s
+}
+
+func containsAny(list []string, values ...string) bool {
+	for _, v := range list {
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchLabels(selector *metav1.LabelSelector, l map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+
+	return s.Matches(labels.Set(l))
+}
+
+func toUnstructured(res *resource.Resource) (*unstructured.Unstructured, error) {
+	b, err := res.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{}
+	return obj, obj.UnmarshalJSON(b)
+}
@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"sigs.k8s.io/kustomize/api/provider"
+)
+
+const manifests = `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: replicas
+spec:
+  failurePolicy: Fail
+  paramKind:
+    apiVersion: v1
+    kind: ConfigMap
+  matchConstraints:
+    resourceRules:
+    - apiGroups: ["apps"]
+      apiVersions: ["v1"]
+      operations: ["CREATE", "UPDATE"]
+      resources: ["deployments"]
+  variables:
+  - name: replicas
+    expression: "has(object.spec.replicas) ? object.spec.replicas : 1"
+  validations:
+  - expression: "variables.replicas <= int(params.data.maxReplicas)"
+    messageExpression: "'replicas must be no greater than ' + params.data.maxReplicas"
+  - expression: "object.metadata.name.startsWith('app-')"
+    message: "name must start with app-"
+    reason: Invalid
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: replicas-production
+spec:
+  policyName: replicas
+  validationActions: [Deny]
+  paramRef:
+    name: limits
+    namespace: default
+    parameterNotFoundAction: Deny
+  matchResources:
+    namespaceSelector:
+      matchLabels:
+        environment: production
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: replicas-staging
+spec:
+  policyName: replicas
+  validationActions: [Warn]
+  paramRef:
+    name: limits
+    namespace: default
+    parameterNotFoundAction: Deny
+  matchResources:
+    namespaceSelector:
+      matchLabels:
+        environment: staging
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: limits
+  namespace: default
+data:
+  maxReplicas: "3"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: production
+  labels:
+    environment: production
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: staging
+  labels:
+    environment: staging
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app-ok
+  namespace: production
+spec:
+  replicas: 2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: production
+spec:
+  replicas: 5
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app-staging
+  namespace: staging
+spec:
+  replicas: 5
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app-other
+  namespace: other
+spec:
+  replicas: 5
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: production
+spec:
+  replicas: 5
+`
+
+func TestEvaluate(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(manifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	e, err := New()
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, res := range resources {
+		g.Expect(e.Add(res)).To(Succeed())
+	}
+
+	results, err := e.Evaluate(resources[6])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(BeEmpty())
+
+	results, err = e.Evaluate(resources[7])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(Equal([]Result{
+		{Policy: "replicas", Binding: "replicas-production", Action: admissionregistrationv1.Deny, Message: "replicas must be no greater than 3"},
+		{Policy: "replicas", Binding: "replicas-production", Action: admissionregistrationv1.Deny, Message: "name must start with app- (reason: Invalid)"},
+	}))
+
+	results, err = e.Evaluate(resources[8])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(Equal([]Result{
+		{Policy: "replicas", Binding: "replicas-staging", Action: admissionregistrationv1.Warn, Message: "replicas must be no greater than 3"},
+	}))
+
+	results, err = e.Evaluate(resources[9])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(BeEmpty())
+
+	results, err = e.Evaluate(resources[10])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(BeEmpty())
+}
+
+func TestAddInvalidPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(`apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: invalid
+spec:
+  validations:
+  - expression: "object.spec.replicas <="
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	e, err := New()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e.Add(resources[0])).To(MatchError(ContainSubstring("invalid ValidatingAdmissionPolicy invalid")))
+}
+
+func TestEvaluateAuthorizer(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(`apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: authorizer
+spec:
+  matchConstraints:
+    resourceRules:
+    - apiGroups: [""]
+      apiVersions: ["v1"]
+      operations: ["CREATE"]
+      resources: ["configmaps"]
+  validations:
+  - expression: "authorizer.requestResource.check('create').allowed()"
+    messageExpression: "authorizer.requestResource.check('create').reason()"
+  - expression: "authorizer.group('').resource('secrets').namespace(object.metadata.namespace).check('get').allowed()"
+    message: "secrets can not be read"
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: authorizer
+spec:
+  policyName: authorizer
+  validationActions: [Deny]
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: default
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	e, err := New()
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, res := range resources {
+		g.Expect(e.Add(res)).To(Succeed())
+	}
+
+	results, err := e.Evaluate(resources[2])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(Equal([]Result{
+		{Policy: "authorizer", Binding: "authorizer", Action: admissionregistrationv1.Deny, Message: denyReason},
+		{Policy: "authorizer", Binding: "authorizer", Action: admissionregistrationv1.Deny, Message: "secrets can not be read"},
+	}))
+}
@@ -0,0 +1,109 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultFile is the name of the project configuration file.
+const DefaultFile = ".flux-build.yaml"
+
+// Config is the project configuration file defining named profiles.
+type Config struct {
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// Profile defines the settings of a single build, e.g. of a cluster.
+// Unset fields default to the flags and environment variables.
+type Profile struct {
+	// Name is the key of the profile in the configuration file.
+	Name string `json:"-"`
+	// Paths are the kustomize paths to build, relative to the configuration file.
+	Paths       []string `json:"paths,omitempty"`
+	KubeVersion string   `json:"kubeVersion,omitempty"`
+	APIVersions []string `json:"apiVersions,omitempty"`
+	// Variables are substituted in HelmReleases in addition to the environment variables.
+	Variables map[string]string `json:"variables,omitempty"`
+	Cache     string            `json:"cache,omitempty"`
+	// CacheDir is the fs cache directory, relative to the configuration file.
+	CacheDir string `json:"cacheDir,omitempty"`
+	Filter   Filter `json:"filter,omitempty"`
+	// Output is the path to write the build output to, relative to the configuration file.
+	Output string `json:"output,omitempty"`
+	// SourceIgnore are the ignore patterns of the GitRepository the paths are synced from, in the format of its spec.ignore.
+	SourceIgnore string `json:"sourceIgnore,omitempty"`
+}
+
+// Filter restricts the HelmReleases rendered and objects written by a profile.
+type Filter struct {
+	HelmReleases []string `json:"helmReleases,omitempty"`
+	Namespaces   []string `json:"namespaces,omitempty"`
+	Kinds        []string `json:"kinds,omitempty"`
+	ExcludeKinds []string `json:"excludeKinds,omitempty"`
+	Selector     string   `json:"selector,omitempty"`
+}
+
+// Load reads the configuration file at path. Relative paths within profiles are resolved against
+// the directory of the file.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for name, p := range c.Profiles {
+		if p == nil {
+			p = &Profile{}
+			c.Profiles[name] = p
+		}
+
+		p.Name = name
+		for i := range p.Paths {
+			p.Paths[i] = resolve(dir, p.Paths[i])
+		}
+
+		p.CacheDir = resolve(dir, p.CacheDir)
+		p.Output = resolve(dir, p.Output)
+	}
+
+	return c, nil
+}
+
+func resolve(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// Names returns the names of all profiles in alphabetical order.
+func (c *Config) Names() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the profile with the given name.
+func (c *Config) Get(name string) (*Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found, available profiles: %v", name, c.Names())
+	}
+
+	return p, nil
+}
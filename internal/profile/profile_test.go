@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const config = `profiles:
+  production:
+    paths:
+    - clusters/production
+    - /abs/repositories
+    kubeVersion: "1.30"
+    apiVersions:
+    - monitoring.coreos.com/v1
+    variables:
+      CLUSTER_NAME: production
+    cache: fs
+    cacheDir: .cache
+    filter:
+      namespaces:
+      - apps
+    output: build/production.yaml
+    sourceIgnore: |
+      /*
+      !/clusters
+  staging: {}
+`
+
+func TestLoad(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultFile)
+	g.Expect(os.WriteFile(path, []byte(config), 0644)).To(Succeed())
+
+	c, err := Load(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Names()).To(Equal([]string{"production", "staging"}))
+
+	p, err := c.Get("production")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p).To(Equal(&Profile{
+		Name:         "production",
+		Paths:        []string{filepath.Join(dir, "clusters/production"), "/abs/repositories"},
+		KubeVersion:  "1.30",
+		APIVersions:  []string{"monitoring.coreos.com/v1"},
+		Variables:    map[string]string{"CLUSTER_NAME": "production"},
+		Cache:        "fs",
+		CacheDir:     filepath.Join(dir, ".cache"),
+		Filter:       Filter{Namespaces: []string{"apps"}},
+		Output:       filepath.Join(dir, "build/production.yaml"),
+		SourceIgnore: "/*\n!/clusters\n",
+	}))
+
+	p, err = c.Get("staging")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p).To(Equal(&Profile{Name: "staging"}))
+
+	_, err = c.Get("development")
+	g.Expect(err).To(MatchError(ContainSubstring(`profile "development" not found`)))
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	g.Expect(os.WriteFile(path, []byte("profiles:\n  production:\n    kubernetesVersion: \"1.30\"\n"), 0644)).To(Succeed())
+
+	_, err := Load(path)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,164 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Mode defines how redacted values are replaced.
+type Mode string
+
+const (
+	// ModeNone disables redaction.
+	ModeNone Mode = "none"
+	// ModePlaceholder replaces values with a fixed placeholder.
+	ModePlaceholder Mode = "placeholder"
+	// ModeHash replaces values with their HMAC-SHA256 using the redaction key. Equal values result in equal hashes
+	// which allows to detect value changes without disclosing them, without the key values can not be guessed by their hash.
+	ModeHash Mode = "hash"
+)
+
+// Placeholder is the replacement for values redacted with ModePlaceholder.
+const Placeholder = "**REDACTED**"
+
+// ParseMode converts a string into a Mode. An empty string equals ModeNone.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeNone:
+		return ModeNone, nil
+	case ModePlaceholder, ModeHash:
+		return Mode(s), nil
+	}
+
+	return ModeNone, fmt.Errorf("unsupported redaction mode %q, expected one of none, placeholder, hash", s)
+}
+
+// Redactor replaces sensitive values of objects while keeping the object structure intact.
+type Redactor struct {
+	mode  Mode
+	rules []Rule
+	key   []byte
+}
+
+// New creates a new Redactor. Values of v1.Secret objects are always redacted,
+// additional fields can be selected using rules.
+// ModeHash requires a key, values are replaced with the placeholder if the key is empty.
+func New(mode Mode, rules []Rule, key []byte) *Redactor {
+	if mode == ModeHash && len(key) == 0 {
+		mode = ModePlaceholder
+	}
+
+	return &Redactor{
+		mode:  mode,
+		rules: rules,
+		key:   key,
+	}
+}
+
+// Redact redacts the given object in place.
+func (r *Redactor) Redact(res *resource.Resource) {
+	if r.mode == ModeNone {
+		return
+	}
+
+	gvk := res.GetGvk()
+	node := res.YNode()
+
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		walk(node, []segment{{typ: segmentKey, key: "data"}, {typ: segmentWildcard}}, r.redactBase64)
+		walk(node, []segment{{typ: segmentKey, key: "stringData"}, {typ: segmentWildcard}}, r.redactString)
+	}
+
+	for _, rule := range r.rules {
+		if rule.matches(gvk.Group, gvk.Kind) {
+			walk(node, rule.path, r.redactString)
+		}
+	}
+}
+
+// RedactString returns the replacement of a single value. The value is returned as is if redaction is disabled.
+func (r *Redactor) RedactString(value string) string {
+	if r.mode == ModeNone {
+		return value
+	}
+
+	return r.replace(value)
+}
+
+func (r *Redactor) replace(value string) string {
+	if r.mode == ModeHash {
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(value))
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	return Placeholder
+}
+
+func (r *Redactor) redactString(value string) string {
+	return r.replace(value)
+}
+
+// redactBase64 keeps base64 encoded values valid, the hash is computed from the decoded value
+// so that the same value in `data` and `stringData` results in the same hash.
+func (r *Redactor) redactBase64(value string) string {
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		value = string(decoded)
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(r.replace(value)))
+}
+
+func walk(node *yaml.Node, path []segment, redact func(string) string) {
+	if node == nil {
+		return
+	}
+
+	if len(path) == 0 {
+		redactNode(node, redact)
+		return
+	}
+
+	seg, rest := path[0], path[1:]
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if seg.typ == segmentWildcard || (seg.typ == segmentKey && node.Content[i].Value == seg.key) {
+				walk(node.Content[i+1], rest, redact)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if seg.typ == segmentWildcard || (seg.typ == segmentIndex && seg.index == i) {
+				walk(item, rest, redact)
+			}
+		}
+	}
+}
+
+func redactNode(node *yaml.Node, redact func(string) string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == yaml.NodeTagNull {
+			return
+		}
+
+		node.Value = redact(node.Value)
+		node.Tag = yaml.NodeTagString
+		node.Style = 0
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			redactNode(node.Content[i], redact)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			redactNode(item, redact)
+		}
+	}
+}
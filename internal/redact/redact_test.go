@@ -0,0 +1,170 @@
+package redact
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+)
+
+const manifests = `apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  password: cGFzc3dvcmQ=
+stringData:
+  token: password
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations:
+    example.com/token: secret
+data:
+  connectionString: postgres://user:password@db
+  plain: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        env:
+        - name: A
+          value: a
+        - name: B
+          value: b
+`
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule      string
+		expectErr bool
+		group     string
+		kind      string
+		path      []segment
+	}{
+		{
+			rule: "ConfigMap:$.data.connectionString",
+			kind: "ConfigMap",
+			path: []segment{{typ: segmentKey, key: "data"}, {typ: segmentKey, key: "connectionString"}},
+		},
+		{
+			rule:  "apps/Deployment:.spec.containers[*].env[0]",
+			group: "apps",
+			kind:  "Deployment",
+			path: []segment{
+				{typ: segmentKey, key: "spec"},
+				{typ: segmentKey, key: "containers"},
+				{typ: segmentWildcard},
+				{typ: segmentKey, key: "env"},
+				{typ: segmentIndex, index: 0},
+			},
+		},
+		{
+			rule: "*:metadata.annotations['example.com/token']",
+			kind: "*",
+			path: []segment{{typ: segmentKey, key: "metadata"}, {typ: segmentKey, key: "annotations"}, {typ: segmentKey, key: "example.com/token"}},
+		},
+		{rule: "ConfigMap", expectErr: true},
+		{rule: "ConfigMap:$", expectErr: true},
+		{rule: "ConfigMap:.data[abc]", expectErr: true},
+		{rule: "ConfigMap:.data[0", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			g := NewWithT(t)
+			r, err := ParseRule(tt.rule)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(r.Group).To(Equal(tt.group))
+			g.Expect(r.Kind).To(Equal(tt.kind))
+			g.Expect(r.path).To(Equal(tt.path))
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	g := NewWithT(t)
+
+	rules, err := ParseRules([]string{
+		"ConfigMap:.data.connectionString",
+		"*:.metadata.annotations['example.com/token']",
+		"apps/Deployment:.spec.template.spec.containers[*].env[1].value",
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(manifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	r := New(ModeHash, rules, []byte("redaction-key"))
+	for _, res := range resources {
+		r.Redact(res)
+	}
+
+	const passwordHash = "hmac-sha256:5471c3acbc74e216b1923712eba0e48a8d088db9c8523ce41af7881f43a4d13a"
+
+	secret := resources[0]
+	g.Expect(secret.GetDataMap()).To(HaveKeyWithValue("password", "aG1hYy1zaGEyNTY6NTQ3MWMzYWNiYzc0ZTIxNmIxOTIzNzEyZWJhMGU0OGE4ZDA4OGRiOWM4NTIzY2U0MWFmNzg4MWY0M2E0ZDEzYQ=="))
+	g.Expect(secret.GetString("stringData.token")).To(Equal(passwordHash))
+
+	cm := resources[1]
+	g.Expect(cm.GetDataMap()).To(HaveKeyWithValue("plain", "value"))
+	g.Expect(cm.GetDataMap()["connectionString"]).To(HavePrefix("hmac-sha256:"))
+	g.Expect(cm.GetAnnotations()["example.com/token"]).To(HavePrefix("hmac-sha256:"))
+
+	deployment := resources[2]
+	g.Expect(deployment.GetString("spec.template.spec.containers[0].env[0].value")).To(Equal("a"))
+	g.Expect(deployment.GetString("spec.template.spec.containers[0].env[1].value")).To(HavePrefix("hmac-sha256:"))
+}
+
+func TestRedactPlaceholder(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(manifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	r := New(ModePlaceholder, nil, nil)
+	for _, res := range resources {
+		r.Redact(res)
+	}
+
+	g.Expect(resources[0].GetDataMap()).To(HaveKeyWithValue("password", "KipSRURBQ1RFRCoq"))
+	g.Expect(resources[0].GetString("stringData.token")).To(Equal(Placeholder))
+	g.Expect(resources[1].GetDataMap()).To(HaveKeyWithValue("connectionString", "postgres://user:password@db"))
+}
+
+func TestRedactHashWithoutKey(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(manifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Unkeyed hashes of low entropy values can be reversed by a dictionary
+	r := New(ModeHash, nil, nil)
+	r.Redact(resources[0])
+
+	g.Expect(resources[0].GetString("stringData.token")).To(Equal(Placeholder))
+}
+
+func TestRedactHashKey(t *testing.T) {
+	g := NewWithT(t)
+
+	a := New(ModeHash, nil, []byte("a")).replace("password")
+	g.Expect(a).To(Equal(New(ModeHash, nil, []byte("a")).replace("password")))
+	g.Expect(a).ToNot(Equal(New(ModeHash, nil, []byte("b")).replace("password")))
+}
@@ -0,0 +1,136 @@
+package redact
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type segmentType int
+
+const (
+	segmentKey segmentType = iota
+	segmentIndex
+	segmentWildcard
+)
+
+type segment struct {
+	typ   segmentType
+	key   string
+	index int
+}
+
+// Rule selects fields of objects of a given kind which are redacted.
+type Rule struct {
+	// Group is the api group of the object, a Rule without a group matches all groups.
+	Group string
+	// Kind is the object kind, `*` matches any kind.
+	Kind string
+	path []segment
+	raw  string
+}
+
+func (r Rule) String() string {
+	return r.raw
+}
+
+// ParseRule parses a rule in the format `[group/]Kind:path`.
+// The path is a JSONPath like expression, e.g. `ConfigMap:$.data.connectionString`,
+// `apps/Deployment:.spec.template.spec.containers[*].env[*].value` or `*:.metadata.annotations['example.com/token']`.
+// `*` matches any map key, `[*]` any list item and `[n]` a specific list item.
+func ParseRule(rule string) (Rule, error) {
+	kind, path, ok := strings.Cut(rule, ":")
+	if !ok || kind == "" || path == "" {
+		return Rule{}, fmt.Errorf("invalid redaction rule %q, expected format [group/]Kind:path", rule)
+	}
+
+	r := Rule{Kind: kind, raw: rule}
+	if group, kind, ok := strings.Cut(kind, "/"); ok {
+		r.Group = group
+		r.Kind = kind
+	}
+
+	segments, err := parsePath(path)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid redaction rule %q: %w", rule, err)
+	}
+
+	r.path = segments
+	return r, nil
+}
+
+// ParseRules parses multiple rules, see ParseRule.
+func ParseRules(rules []string) ([]Rule, error) {
+	var result []Rule
+	for _, rule := range rules {
+		r, err := ParseRule(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+func (r Rule) matches(group, kind string) bool {
+	if r.Kind != "*" && r.Kind != kind {
+		return false
+	}
+
+	return r.Group == "" || r.Group == group
+}
+
+func parsePath(path string) ([]segment, error) {
+	path = strings.TrimPrefix(path, "$")
+	var segments []segment
+
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := strings.Index(path, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("missing closing bracket in path")
+			}
+
+			inner := path[1:end]
+			path = path[end+1:]
+
+			switch {
+			case inner == "*":
+				segments = append(segments, segment{typ: segmentWildcard})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, segment{typ: segmentKey, key: inner[1 : len(inner)-1]})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid list index %q", inner)
+				}
+				segments = append(segments, segment{typ: segmentIndex, index: i})
+			}
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+
+			key := path[:end]
+			path = path[end:]
+
+			if key == "*" {
+				segments = append(segments, segment{typ: segmentWildcard})
+			} else {
+				segments = append(segments, segment{typ: segmentKey, key: key})
+			}
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,161 @@
+package remote
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Remote is a remote base or file referenced by a kustomization.
+type Remote struct {
+	// URL is the remote as declared in the kustomization.
+	URL string
+	// Repository identifies the git repository independent of the protocol it is cloned with, e.g. github.com/org/repo.
+	// It is empty for remote files.
+	Repository string
+	// CloneURL is the url git clones the repository from.
+	CloneURL string
+	// Ref is the branch, tag or commit of the repository, the default branch is cloned if empty.
+	Ref string
+	// Path is the kustomization root within the repository.
+	Path string
+}
+
+// IsFile returns true if the remote is a file fetched over http instead of a git repository.
+func (r Remote) IsFile() bool {
+	return r.Repository == ""
+}
+
+// Pinned returns true if the ref of the repository is a full commit hash.
+func (r Remote) Pinned() bool {
+	return commitPattern.MatchString(r.Ref)
+}
+
+var (
+	commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	userPattern   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*)@`)
+)
+
+// Parse parses a resource of a kustomization like kustomize does and returns false if it is not remote.
+// Git repositories are declared like github.com/org/repo//path?ref=v1, http urls which are not clearly a git
+// repository are remote files.
+func Parse(s string) (Remote, bool) {
+	r := Remote{URL: s}
+	n, query, _ := strings.Cut(s, "?")
+	n, _ = trimPrefixFold(n, "git::")
+
+	values, _ := url.ParseQuery(query)
+	r.Ref = values.Get("version")
+	if ref := values.Get("ref"); ref != "" {
+		r.Ref = ref
+	}
+
+	// The file protocol declares the absolute path of a local repository
+	if rest, ok := trimPrefixFold(n, "file://"); ok {
+		repoPath, kustPath, explicit := splitRepoPath(rest)
+		if !explicit {
+			repoPath, kustPath = rest, ""
+		}
+
+		return r.withRepository("file://"+repoPath, "file"+path.Clean("/"+strings.TrimSuffix(repoPath, ".git")), kustPath)
+	}
+
+	var scheme string
+	for _, prefix := range []string{"ssh://", "https://", "http://"} {
+		if rest, ok := trimPrefixFold(n, prefix); ok {
+			scheme, n = prefix, rest
+			break
+		}
+	}
+
+	var user string
+	if m := userPattern.FindStringSubmatch(n); m != nil {
+		user, n = m[1]+"@", n[len(m[1])+1:]
+	}
+
+	github := strings.HasPrefix(strings.ToLower(n), "github.com/") || strings.HasPrefix(strings.ToLower(n), "github.com:")
+	scp := scheme == "" && (user != "" || github)
+	if scheme == "" && !scp {
+		return Remote{}, false
+	}
+
+	sep := strings.Index(n, "/")
+	if colon := strings.Index(n, ":"); scp && colon > 0 && (sep == -1 || colon < sep) {
+		sep = colon
+	}
+
+	if sep <= 0 {
+		return Remote{}, false
+	}
+
+	host, rest := n[:sep], n[sep+1:]
+	repoPath, kustPath, explicit := splitRepoPath(rest)
+	if (scheme == "http://" || scheme == "https://") && !explicit && r.Ref == "" && !github {
+		return Remote{URL: s}, true
+	}
+
+	if repoPath == "" {
+		return Remote{}, false
+	}
+
+	var cloneURL string
+	switch {
+	case github && scp && user == "":
+		// github.com/org/repo is cloned over https like kustomize does
+		cloneURL = "https://github.com/" + repoPath
+	case scp:
+		cloneURL = user + host + ":" + repoPath
+	default:
+		cloneURL = scheme + user + host + "/" + repoPath
+	}
+
+	return r.withRepository(cloneURL, strings.ToLower(host)+"/"+strings.TrimSuffix(repoPath, ".git"), kustPath)
+}
+
+// withRepository returns the remote of the repository, false if the kustomization root is outside of it.
+func (r Remote) withRepository(cloneURL, repository, kustPath string) (Remote, bool) {
+	kustPath = strings.Trim(kustPath, "/")
+	if kustPath != "" && strings.HasPrefix(path.Clean(kustPath), "..") {
+		return Remote{}, false
+	}
+
+	r.CloneURL, r.Repository = cloneURL, repository
+	if kustPath != "" && path.Clean(kustPath) != "." {
+		r.Path = path.Clean(kustPath)
+	}
+
+	return r, true
+}
+
+// splitRepoPath splits the repository from the kustomization root by the explicit markers _git/, // or .git,
+// or otherwise after the org/repo segments. explicit is false if no marker was found.
+func splitRepoPath(n string) (repoPath, kustPath string, explicit bool) {
+	if i := strings.Index(n, "_git/"); i >= 0 {
+		repo, sub, _ := strings.Cut(n[i+len("_git/"):], "/")
+		return n[:i+len("_git/")] + repo, sub, true
+	}
+
+	if i := strings.Index(n, "//"); i >= 0 {
+		return n[:i], n[i+2:], true
+	}
+
+	if i := strings.Index(n, ".git"); i >= 0 {
+		return n[:i+len(".git")], n[i+len(".git"):], true
+	}
+
+	segments := strings.Split(n, "/")
+	if len(segments) < 2 {
+		return "", "", false
+	}
+
+	return strings.Join(segments[:2], "/"), strings.Join(segments[2:], "/"), false
+}
+
+func trimPrefixFold(s, prefix string) (string, bool) {
+	if len(prefix) <= len(s) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+
+	return s, false
+}
@@ -0,0 +1,318 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		url      string
+		remote   bool
+		expected Remote
+	}{
+		{
+			url:      "github.com/org/repo//deploy/base?ref=v1.0.0",
+			remote:   true,
+			expected: Remote{Repository: "github.com/org/repo", CloneURL: "https://github.com/org/repo", Ref: "v1.0.0", Path: "deploy/base"},
+		},
+		{
+			url:      "https://github.com/org/repo/deploy/base?ref=v1.0.0",
+			remote:   true,
+			expected: Remote{Repository: "github.com/org/repo", CloneURL: "https://github.com/org/repo", Ref: "v1.0.0", Path: "deploy/base"},
+		},
+		{
+			url:      "git@github.com:org/repo.git/deploy?version=main",
+			remote:   true,
+			expected: Remote{Repository: "github.com/org/repo", CloneURL: "git@github.com:org/repo.git", Ref: "main", Path: "deploy"},
+		},
+		{
+			url:      "ssh://git@gitlab.example.com/group/sub/repo.git//base?ref=0123456789abcdef0123456789abcdef01234567",
+			remote:   true,
+			expected: Remote{Repository: "gitlab.example.com/group/sub/repo", CloneURL: "ssh://git@gitlab.example.com/group/sub/repo.git", Ref: "0123456789abcdef0123456789abcdef01234567", Path: "base"},
+		},
+		{
+			url:      "https://dev.azure.com/org/project/_git/repo/base",
+			remote:   true,
+			expected: Remote{Repository: "dev.azure.com/org/project/_git/repo", CloneURL: "https://dev.azure.com/org/project/_git/repo", Path: "base"},
+		},
+		{
+			url:      "git::https://gitlab.com/org/repo//base",
+			remote:   true,
+			expected: Remote{Repository: "gitlab.com/org/repo", CloneURL: "https://gitlab.com/org/repo", Path: "base"},
+		},
+		{
+			url:      "file:///srv/git/repo//base?ref=v1",
+			remote:   true,
+			expected: Remote{Repository: "file/srv/git/repo", CloneURL: "file:///srv/git/repo", Ref: "v1", Path: "base"},
+		},
+		{
+			url:    "https://raw.githubusercontent.com/org/repo/v1/deploy/crds.yaml",
+			remote: true,
+		},
+		{
+			url: "github.com/org/repo//../escape",
+		},
+		{
+			url: "../base",
+		},
+		{
+			url: "deployment.yaml",
+		},
+		{
+			url: "example.com/org/repo//base",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, ok := Parse(test.url)
+			g.Expect(ok).To(Equal(test.remote))
+			if !test.remote {
+				return
+			}
+
+			test.expected.URL = test.url
+			g.Expect(r).To(Equal(test.expected))
+		})
+	}
+}
+
+func TestPinned(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(Remote{Repository: "github.com/org/repo", Ref: "0123456789abcdef0123456789abcdef01234567"}.Pinned()).To(BeTrue())
+	g.Expect(Remote{Repository: "github.com/org/repo", Ref: "v1.0.0"}.Pinned()).To(BeFalse())
+	g.Expect(Remote{Repository: "github.com/org/repo"}.Pinned()).To(BeFalse())
+}
+
+func TestParseMirrors(t *testing.T) {
+	g := NewWithT(t)
+
+	mirrors, err := ParseMirrors([]string{"github.com/org/repo?ref=v1=/mirrors/repo", "https://example.com/manifests/=/mirrors/manifests"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mirrors).To(HaveLen(2))
+	g.Expect(mirrors[0].Remote.Repository).To(Equal("github.com/org/repo"))
+	g.Expect(mirrors[0].Remote.Ref).To(Equal("v1"))
+	g.Expect(mirrors[0].Dir).To(Equal("/mirrors/repo"))
+	g.Expect(mirrors[1].Remote.IsFile()).To(BeTrue())
+
+	_, err = ParseMirrors([]string{"github.com/org/repo"})
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = ParseMirrors([]string{"../base=/mirrors/base"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	vendored := filepath.Join(dir, "vendor", "github.com", "org", "vendored", "v2", "base")
+	NewWithT(t).Expect(os.MkdirAll(vendored, 0755)).To(Succeed())
+
+	resolver := &Resolver{
+		Mirrors: []Mirror{
+			{Remote: mustParse(t, "github.com/org/repo?ref=v1"), Dir: "/mirrors/repo"},
+			{Remote: mustParse(t, "github.com/org/other//deploy?ref=v1"), Dir: "/mirrors/deploy"},
+			{Remote: mustParse(t, "https://example.com/manifests/"), Dir: "/mirrors/manifests"},
+		},
+		VendorDir: filepath.Join(dir, "vendor"),
+	}
+
+	tests := []struct {
+		url    string
+		path   string
+		source Source
+	}{
+		{url: "github.com/org/repo//base?ref=v1", path: "/mirrors/repo/base", source: SourceMirror},
+		{url: "https://github.com/org/repo?ref=v1", path: "/mirrors/repo", source: SourceMirror},
+		{url: "github.com/org/other//deploy/overlay?ref=v1", path: "/mirrors/deploy/overlay", source: SourceMirror},
+		{url: "https://example.com/manifests/crds.yaml", path: "/mirrors/manifests/crds.yaml", source: SourceMirror},
+		{url: "github.com/org/vendored//base?ref=v2", path: vendored, source: SourceVendor},
+		{url: "github.com/org/repo//base?ref=v2"},
+		{url: "github.com/org/other//base?ref=v1"},
+		{url: "github.com/org/vendored//missing?ref=v2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			path, source := resolver.Resolve(mustParse(t, test.url))
+			g.Expect(path).To(Equal(test.path))
+			g.Expect(source).To(Equal(test.source))
+		})
+	}
+}
+
+func TestVendorFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/crds.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: remote\n"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mirror := filepath.Join(dir, "mirror")
+	files := map[string]string{
+		"app/kustomization.yaml":         "resources:\n- ../base\n- github.com/org/repo//base?ref=v1\n",
+		"base/kustomization.yaml":        "resources:\n- " + srv.URL + "/crds.yaml\n",
+		"mirror/base/kustomization.yaml": "resources:\n- " + srv.URL + "/crds.yaml\n- " + srv.URL + "/missing.yaml\n",
+	}
+
+	for name, content := range files {
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	v := &Vendorer{
+		Resolver: &Resolver{
+			Mirrors:   []Mirror{{Remote: mustParse(t, "github.com/org/repo?ref=v1"), Dir: mirror}},
+			VendorDir: filepath.Join(dir, "vendor"),
+		},
+		Logger: logr.Discard(),
+	}
+
+	rep := &Report{}
+	err := v.Vendor(context.TODO(), filepath.Join(dir, "app"), rep)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to vendor " + srv.URL + "/missing.yaml: unexpected status code 404"))
+
+	rep.Sort()
+	g.Expect(rep.Bases).To(Equal([]Base{
+		{
+			URL:          "github.com/org/repo//base?ref=v1",
+			Repository:   "github.com/org/repo",
+			Ref:          "v1",
+			Path:         "base",
+			Source:       SourceMirror,
+			Dir:          filepath.Join(mirror, "base"),
+			ReferencedBy: []string{filepath.Join(dir, "app", "kustomization.yaml")},
+		},
+		{
+			URL:          srv.URL + "/crds.yaml",
+			Source:       SourceVendor,
+			Dir:          mustVendorPath(t, v.Resolver, srv.URL+"/crds.yaml"),
+			ReferencedBy: []string{filepath.Join(dir, "base", "kustomization.yaml"), filepath.Join(mirror, "base", "kustomization.yaml")},
+		},
+	}))
+
+	b, err := os.ReadFile(mustVendorPath(t, v.Resolver, srv.URL+"/crds.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(b)).To(ContainSubstring("name: remote"))
+
+	// Vendored remotes are not fetched again
+	requests = 0
+	v = &Vendorer{Resolver: v.Resolver, Logger: logr.Discard()}
+	g.Expect(v.Vendor(context.TODO(), filepath.Join(dir, "base"), &Report{})).To(Succeed())
+	g.Expect(requests).To(Equal(0))
+}
+
+func TestVendorPathEscape(t *testing.T) {
+	resolver := &Resolver{VendorDir: "/vendor"}
+
+	tests := []struct {
+		url  string
+		path string
+		err  string
+	}{
+		{url: "github.com/org/repo//base?ref=v1", path: "/vendor/github.com/org/repo/v1/base"},
+		{url: "https://example.com/manifests/crds.yaml?v=1", path: "/vendor/example.com/manifests/crds.yaml@v=1"},
+		{url: "https://evil.example/../../../../home/user/.ssh/authorized_keys", err: `invalid path "/../../../../home/user/.ssh/authorized_keys"`},
+		{url: "https://evil.example/manifests/./crds.yaml", err: `must not contain "."`},
+		{url: "github.com/org/repo//base?ref=..", err: `invalid path "..", it must not contain ".."`},
+		{url: "github.com/org/repo?ref=.", err: `must not contain "."`},
+		{url: "https://evil.example/org/..//base?ref=v1", err: `invalid path "evil.example/org/.."`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			path, err := resolver.VendorPath(mustParse(t, test.url))
+			if test.err != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(test.err)))
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(path).To(Equal(filepath.FromSlash(test.path)))
+		})
+	}
+}
+
+func TestVendorEscape(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: remote\n"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/kustomization.yaml": "resources:\n- " + srv.URL + "/../../victim/file.yaml\n",
+		"victim/file.yaml":       "keep",
+	}
+
+	for name, content := range files {
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	v := &Vendorer{
+		Resolver: &Resolver{
+			Mirrors:   []Mirror{{Remote: mustParse(t, srv.URL+"/mirror/"), Dir: filepath.Join(dir, "mirror")}},
+			VendorDir: filepath.Join(dir, "vendor", "remotes"),
+		},
+		Logger: logr.Discard(),
+	}
+
+	err := v.Vendor(context.TODO(), filepath.Join(dir, "app"), &Report{})
+	g.Expect(err).To(MatchError(ContainSubstring("must not contain")))
+	g.Expect(requests).To(Equal(0))
+
+	b, err := os.ReadFile(filepath.Join(dir, "victim", "file.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(b)).To(Equal("keep"))
+
+	// Mirrored urls do not resolve outside of the mirror either
+	path, _ := v.Resolver.Resolve(mustParse(t, srv.URL+"/mirror/../../victim/file.yaml"))
+	g.Expect(path).To(BeEmpty())
+}
+
+func mustVendorPath(t *testing.T, r *Resolver, url string) string {
+	path, err := r.VendorPath(mustParse(t, url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func mustParse(t *testing.T, url string) Remote {
+	r, ok := Parse(url)
+	if !ok {
+		t.Fatalf("%s is not a remote", url)
+	}
+
+	return r
+}
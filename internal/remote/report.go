@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// RecordFile is the file within the vendor directory recording the vendored remotes.
+const RecordFile = "remotes.json"
+
+// Base is a remote base or file referenced by at least one kustomization.
+type Base struct {
+	URL        string `json:"url"`
+	Repository string `json:"repository,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+	Path       string `json:"path,omitempty"`
+	// Pinned is true if the ref is a full commit hash.
+	Pinned bool `json:"pinned"`
+	// Commit is the commit the ref resolved to when the repository was vendored.
+	Commit string `json:"commit,omitempty"`
+	Source Source `json:"source"`
+	// Dir is the local directory or file the remote is resolved to.
+	Dir string `json:"dir"`
+	// ReferencedBy are the kustomization files referencing the remote.
+	ReferencedBy []string `json:"referencedBy"`
+}
+
+// Report lists the remote bases in use. It is safe for concurrent use.
+type Report struct {
+	mu    sync.Mutex
+	Bases []Base `json:"bases"`
+}
+
+// Add adds a base to the report, the referencing kustomizations of bases with the same url are merged.
+func (r *Report) Add(base Base) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, b := range r.Bases {
+		if b.URL != base.URL {
+			continue
+		}
+
+		for _, file := range base.ReferencedBy {
+			if !contains(b.ReferencedBy, file) {
+				r.Bases[i].ReferencedBy = append(r.Bases[i].ReferencedBy, file)
+			}
+		}
+
+		return
+	}
+
+	r.Bases = append(r.Bases, base)
+}
+
+// Commit returns the recorded commit of the remote url.
+func (r *Report) Commit(url string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.Bases {
+		if b.URL == url {
+			return b.Commit
+		}
+	}
+
+	return ""
+}
+
+// Sort orders the bases by url.
+func (r *Report) Sort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.SliceStable(r.Bases, func(i, j int) bool {
+		return r.Bases[i].URL < r.Bases[j].URL
+	})
+
+	for _, b := range r.Bases {
+		sort.Strings(b.ReferencedBy)
+	}
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Bases == nil {
+		r.Bases = []Base{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// ReadReport reads the report at path, an empty report is returned if it does not exist.
+func ReadReport(path string) (*Report, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Report{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{}
+	return r, json.Unmarshal(b, r)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
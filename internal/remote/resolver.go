@@ -0,0 +1,184 @@
+package remote
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source is where a remote is resolved from.
+type Source string
+
+const (
+	// SourceMirror resolves the remote from a local directory mapped to the remote.
+	SourceMirror Source = "mirror"
+	// SourceVendor resolves the remote from the vendor directory.
+	SourceVendor Source = "vendor"
+)
+
+// Mirror maps a remote to a local directory or file.
+// Git repositories are mapped including their ref, the kustomization root of a remote is resolved within the mirror.
+type Mirror struct {
+	Remote Remote
+	Dir    string
+}
+
+// ParseMirrors parses mirrors in the format url=dir, e.g. github.com/org/repo?ref=v1=./mirrors/repo.
+// Relative directories are resolved against the working directory.
+func ParseMirrors(mappings []string) ([]Mirror, error) {
+	var mirrors []Mirror
+	for _, mapping := range mappings {
+		i := strings.LastIndex(mapping, "=")
+		if i <= 0 || i == len(mapping)-1 {
+			return nil, fmt.Errorf("invalid remote mirror %q, expected url=dir", mapping)
+		}
+
+		r, ok := Parse(mapping[:i])
+		if !ok {
+			return nil, fmt.Errorf("invalid remote mirror %q, %s is not a remote url", mapping, mapping[:i])
+		}
+
+		dir, err := filepath.Abs(mapping[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		mirrors = append(mirrors, Mirror{Remote: r, Dir: dir})
+	}
+
+	return mirrors, nil
+}
+
+// Resolver resolves remotes to local mirrors or the vendor directory.
+type Resolver struct {
+	Mirrors []Mirror
+	// VendorDir is the directory remotes are vendored into, vendored remotes are resolved if set.
+	VendorDir string
+	// Offline fails remotes which can not be resolved locally instead of fetching them.
+	Offline bool
+}
+
+// Resolve returns the local path of the remote and where it was resolved from.
+// An empty path is returned if the remote is neither mirrored nor vendored.
+func (r *Resolver) Resolve(remote Remote) (string, Source) {
+	for _, m := range r.Mirrors {
+		if path, ok := m.resolve(remote); ok {
+			return path, SourceMirror
+		}
+	}
+
+	if r.VendorDir == "" {
+		return "", ""
+	}
+
+	path, err := r.VendorPath(remote)
+	if err != nil {
+		return "", ""
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", ""
+	}
+
+	return path, SourceVendor
+}
+
+// VendorPath returns the path of the remote within the vendor directory.
+// Repositories are vendored at <host>/<repository>/<ref> and remote files at <host>/<path>.
+func (r *Resolver) VendorPath(remote Remote) (string, error) {
+	dir, err := r.VendorRoot(remote)
+	if err != nil || remote.IsFile() || remote.Path == "" {
+		return dir, err
+	}
+
+	return join(dir, remote.Path)
+}
+
+// VendorRoot returns the directory the repository of the remote is vendored into, or the file of a remote file.
+// An error is returned if the remote would be vendored outside of its place within the vendor directory.
+func (r *Resolver) VendorRoot(remote Remote) (string, error) {
+	if !remote.IsFile() {
+		ref := remote.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+
+		return join(r.VendorDir, remote.Repository, url.PathEscape(ref))
+	}
+
+	u, err := url.Parse(remote.URL)
+	if err != nil {
+		return join(r.VendorDir, url.PathEscape(remote.URL))
+	}
+
+	path, err := join(r.VendorDir, strings.ToLower(u.Host), u.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if u.RawQuery != "" {
+		path += "@" + url.PathEscape(u.RawQuery)
+	}
+
+	return path, nil
+}
+
+// join joins the slash separated elements to dir. Elements must not contain . or .. segments which would
+// move the path to another place within dir or outside of it, an empty element is rejected as it resolves to dir itself.
+func join(dir string, elems ...string) (string, error) {
+	path := dir
+	for _, elem := range elems {
+		var segments int
+		for _, segment := range strings.Split(elem, "/") {
+			switch segment {
+			case "":
+				continue
+			case ".", "..":
+				return "", fmt.Errorf("invalid path %q, it must not contain %q", elem, segment)
+			}
+
+			segments++
+		}
+
+		if segments == 0 {
+			return "", fmt.Errorf("invalid empty path %q", elem)
+		}
+
+		path = filepath.Join(path, filepath.FromSlash(elem))
+	}
+
+	return path, nil
+}
+
+// resolve returns the path of the remote within the mirror. Repositories match if they are equal at the same ref and
+// the kustomization root is within the mirrored path, files match by url or within a mirrored url ending with a slash.
+func (m Mirror) resolve(remote Remote) (string, bool) {
+	if remote.IsFile() != m.Remote.IsFile() {
+		return "", false
+	}
+
+	if remote.IsFile() {
+		switch {
+		case remote.URL == m.Remote.URL:
+			return m.Dir, true
+		case strings.HasSuffix(m.Remote.URL, "/") && strings.HasPrefix(remote.URL, m.Remote.URL):
+			path, err := join(m.Dir, strings.TrimPrefix(remote.URL, m.Remote.URL))
+			return path, err == nil
+		}
+
+		return "", false
+	}
+
+	if remote.Repository != m.Remote.Repository || remote.Ref != m.Remote.Ref {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(filepath.FromSlash("/"+m.Remote.Path), filepath.FromSlash("/"+remote.Path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.Join(m.Dir, rel), true
+}
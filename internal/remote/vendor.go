@@ -0,0 +1,250 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/doodlescheduling/flux-build/internal/git"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Vendorer fetches the remotes of kustomizations into the vendor directory of the resolver.
+// Remotes which are mirrored or have been vendored before are not fetched again.
+type Vendorer struct {
+	Resolver *Resolver
+	Logger   logr.Logger
+	// Client fetches remote files, defaults to http.DefaultClient.
+	Client *http.Client
+	// Record holds the previously vendored remotes, the commits of remotes which are not fetched again are taken from it.
+	Record  *Report
+	visited map[string]bool
+}
+
+// Vendor fetches all remotes referenced by the kustomizations within path including the remotes of remote bases
+// and adds them to the report. Errors of single remotes do not abort vendoring the others.
+func (v *Vendorer) Vendor(ctx context.Context, path string, rep *Report) error {
+	if v.visited == nil {
+		v.visited = make(map[string]bool)
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if kfile := kustomizationFile(path); kfile != "" {
+		return v.kustomization(ctx, kfile, rep)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil || !stat.IsDir() {
+		return err
+	}
+
+	// Paths without a kustomization include all directories with a kustomization
+	var errs []error
+	err = filepath.WalkDir(path, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if kfile := kustomizationFile(dir); kfile != "" {
+			errs = append(errs, v.kustomization(ctx, kfile, rep))
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return errors.Join(append(errs, err)...)
+}
+
+// kustomization vendors the remotes of the kustomization file and follows its local and remote bases.
+func (v *Vendorer) kustomization(ctx context.Context, kfile string, rep *Report) error {
+	if v.visited[kfile] {
+		return nil
+	}
+
+	v.visited[kfile] = true
+	b, err := os.ReadFile(kfile)
+	if err != nil {
+		return err
+	}
+
+	var kus kustypes.Kustomization
+	if err := yaml.Unmarshal(b, &kus); err != nil {
+		return fmt.Errorf("%s: %w", kfile, err)
+	}
+
+	dir := filepath.Dir(kfile)
+	var errs []error
+	for _, entry := range append(append(kus.Resources, kus.Components...), kus.Bases...) {
+		local := filepath.Join(dir, entry)
+		r, ok := Parse(entry)
+		if !ok || exists(local) {
+			if base := kustomizationFile(local); base != "" {
+				errs = append(errs, v.kustomization(ctx, base, rep))
+			}
+
+			continue
+		}
+
+		path, err := v.vendor(ctx, r, kfile, rep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to vendor %s: %w", kfile, r.URL, err))
+			continue
+		}
+
+		if base := kustomizationFile(path); base != "" {
+			errs = append(errs, v.kustomization(ctx, base, rep))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// vendor fetches the remote unless it can be resolved locally and returns its local path.
+func (v *Vendorer) vendor(ctx context.Context, r Remote, kfile string, rep *Report) (string, error) {
+	base := Base{
+		URL:          r.URL,
+		Repository:   r.Repository,
+		Ref:          r.Ref,
+		Path:         r.Path,
+		Pinned:       r.Pinned(),
+		ReferencedBy: []string{kfile},
+	}
+
+	path, source := v.Resolver.Resolve(r)
+	if path == "" {
+		v.Logger.Info("vendor remote", "url", r.URL)
+		commit, err := v.fetch(ctx, r)
+		if err != nil {
+			return "", err
+		}
+
+		path, err = v.Resolver.VendorPath(r)
+		if err != nil {
+			return "", err
+		}
+
+		source = SourceVendor
+		if !exists(path) {
+			return "", fmt.Errorf("path %s does not exist in the repository", r.Path)
+		}
+
+		base.Commit = commit
+	} else if source == SourceVendor && v.Record != nil {
+		base.Commit = v.Record.Commit(r.URL)
+	}
+
+	base.Source, base.Dir = source, path
+	rep.Add(base)
+	return path, nil
+}
+
+// fetch fetches the remote into the vendor directory and returns the commit of a repository.
+// The remote is fetched into a temporary directory first to not leave partial checkouts behind.
+func (v *Vendorer) fetch(ctx context.Context, r Remote) (string, error) {
+	if v.Resolver.VendorDir == "" {
+		return "", errors.New("no vendor directory configured")
+	}
+
+	dst, err := v.Resolver.VendorRoot(r)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), ".fetch-")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.RemoveAll(tmp)
+
+	var commit string
+	src := filepath.Join(tmp, "remote")
+	if r.IsFile() {
+		err = v.download(ctx, r.URL, src)
+	} else {
+		commit, err = git.Export(ctx, r.CloneURL, r.Ref, src)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// The checkout of the repository may exist without the kustomization root of this remote
+	if err := os.RemoveAll(dst); err != nil {
+		return "", err
+	}
+
+	return commit, os.Rename(src, dst)
+}
+
+func (v *Vendorer) download(ctx context.Context, url, dst string) error {
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, res.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// kustomizationFile returns the kustomization file within dir or an empty string if there is none.
+func kustomizationFile(dir string) string {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		path := filepath.Join(dir, name)
+		if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+			return path
+		}
+	}
+
+	return ""
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,140 @@
+package rendercache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+)
+
+// Mode defines how the render cache is used.
+type Mode string
+
+const (
+	// ModeNone disables the render cache.
+	ModeNone Mode = "none"
+	// ModeReadWrite serves cached output and stores newly rendered output.
+	ModeReadWrite Mode = "readwrite"
+	// ModeRefresh bypasses cached output but stores newly rendered output.
+	ModeRefresh Mode = "refresh"
+	// ModeVerify renders all HelmReleases and reports cached output which differs from the rendered output.
+	// The cache is not modified.
+	ModeVerify Mode = "verify"
+)
+
+// ParseMode converts a string into a Mode. An empty string equals ModeNone.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeNone:
+		return ModeNone, nil
+	case ModeReadWrite, ModeRefresh, ModeVerify:
+		return Mode(s), nil
+	}
+
+	return ModeNone, fmt.Errorf("unsupported render cache mode %q, expected one of none, readwrite, refresh, verify", s)
+}
+
+// Inputs are everything the rendered output of a HelmRelease depends on.
+type Inputs struct {
+	// ChartDigest is the sha256 digest of the chart tarball including its subcharts.
+	ChartDigest string `json:"chartDigest"`
+	// Values are the values of the HelmRelease merged with the chart defaults.
+	Values map[string]interface{} `json:"values"`
+	// Name and Namespace of the HelmRelease, they are added as labels to all objects.
+	Name             string   `json:"name"`
+	Namespace        string   `json:"namespace"`
+	ReleaseName      string   `json:"releaseName"`
+	ReleaseNamespace string   `json:"releaseNamespace"`
+	KubeVersion      string   `json:"kubeVersion"`
+	APIVersions      []string `json:"apiVersions"`
+	IncludeHelmHooks bool     `json:"includeHelmHooks"`
+	// Install are the install options of the HelmRelease, e.g. the CRD policy.
+	Install       *helmv2.Install       `json:"install,omitempty"`
+	PostRenderers []helmv2.PostRenderer `json:"postRenderers,omitempty"`
+}
+
+// Cache stores the rendered output of HelmReleases on disk keyed by a hash of the render inputs.
+// It is safe for concurrent use.
+type Cache struct {
+	dir     string
+	mode    Mode
+	version string
+}
+
+// New creates a cache within dir. Entries of other flux-build versions are never used.
+func New(dir string, mode Mode, version string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		dir:     dir,
+		mode:    mode,
+		version: version,
+	}, nil
+}
+
+// Mode returns the mode of the cache.
+func (c *Cache) Mode() Mode {
+	return c.mode
+}
+
+// Key returns the cache key of the inputs.
+func (c *Cache) Key(inputs Inputs) (string, error) {
+	b, err := json.Marshal(struct {
+		Version string `json:"version"`
+		Inputs
+	}{
+		Version: c.version,
+		Inputs:  inputs,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode render cache inputs: %w", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+// Get returns the cached output of key.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	b, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Set stores the output of key.
+func (c *Cache) Set(key string, output []byte) error {
+	f, err := os.CreateTemp(c.dir, "."+key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(output); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	// Concurrent writers of the same key write the same output, the last rename wins
+	return os.Rename(f.Name(), c.path(key))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".yaml")
+}
@@ -0,0 +1,119 @@
+package rendercache
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		expected  Mode
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			mode:     "",
+			expected: ModeNone,
+		},
+		{
+			name:     "none",
+			mode:     "none",
+			expected: ModeNone,
+		},
+		{
+			name:     "readwrite",
+			mode:     "readwrite",
+			expected: ModeReadWrite,
+		},
+		{
+			name:     "refresh",
+			mode:     "refresh",
+			expected: ModeRefresh,
+		},
+		{
+			name:     "verify",
+			mode:     "verify",
+			expected: ModeVerify,
+		},
+		{
+			name:      "unsupported",
+			mode:      "write",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mode, err := ParseMode(test.mode)
+			if test.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(mode).To(Equal(test.expected))
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := New(t.TempDir(), ModeReadWrite, "v1.0.0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	inputs := Inputs{
+		ChartDigest: "sha256:abc",
+		Values: map[string]interface{}{
+			"replicas": 1,
+			"image":    map[string]interface{}{"tag": "v1", "repository": "nginx"},
+		},
+		Name:        "podinfo",
+		Namespace:   "apps",
+		KubeVersion: "1.31.0",
+	}
+
+	key, err := c.Key(inputs)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	reordered := inputs
+	reordered.Values = map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "v1"},
+		"replicas": 1,
+	}
+
+	g.Expect(c.Key(reordered)).To(Equal(key))
+
+	changed := inputs
+	changed.KubeVersion = "1.30.0"
+	g.Expect(c.Key(changed)).ToNot(Equal(key))
+
+	other, err := New(t.TempDir(), ModeReadWrite, "v1.1.0")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(other.Key(inputs)).ToNot(Equal(key))
+}
+
+func TestGetSet(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := New(t.TempDir(), ModeReadWrite, "v1.0.0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, ok, err := c.Get("missing")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	output := []byte("apiVersion: v1\nkind: ConfigMap\n")
+	g.Expect(c.Set("key", output)).To(Succeed())
+	g.Expect(c.Set("key", output)).To(Succeed())
+
+	cached, ok, err := c.Get("key")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(cached).To(Equal(output))
+}
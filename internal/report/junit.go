@@ -0,0 +1,163 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr,omitempty"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	Classname string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped  `xml:"skipped,omitempty"`
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Each build target is a test case,
+// errors are reported as failures and warnings as system output of the test case. Skipped targets are skipped test cases.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name: "flux-build",
+	}
+
+	seen := make(map[*Error]bool)
+	addCase := func(name, classname string, errs []*Error) {
+		tc := junitTestCase{
+			Name:      name,
+			Classname: classname,
+		}
+
+		var warnings []string
+		for _, err := range errs {
+			seen[err] = true
+			if err.Severity == SeverityWarning {
+				warnings = append(warnings, fmt.Sprintf("[%s] %s", err.Phase, err.Message))
+				continue
+			}
+
+			tc.Failures = append(tc.Failures, junitFailure{
+				Message: err.Message,
+				Type:    err.Phase,
+				Content: formatError(err),
+			})
+		}
+
+		tc.SystemOut = strings.Join(warnings, "\n")
+		suite.Tests++
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	for _, t := range r.Targets {
+		addCase(t.String(), classname(t), r.TargetErrors(t))
+	}
+
+	for _, t := range r.Skipped {
+		suite.Tests++
+		suite.Skipped++
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      t.String(),
+			Classname: classname(t),
+			Skipped:   &junitSkipped{Message: "not affected by the changed files"},
+		})
+	}
+
+	// Errors which can not be assigned to a build target
+	for _, err := range r.Errors {
+		if seen[err] {
+			continue
+		}
+
+		name := err.Target()
+		if name == "" {
+			name = err.Phase
+		}
+
+		addCase(name, err.Phase, []*Error{err})
+	}
+
+	suites := junitTestSuites{
+		Name:     "flux-build",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func classname(t Target) string {
+	if t.HelmRelease != "" {
+		return "helmrelease"
+	}
+
+	return "path"
+}
+
+func formatError(err *Error) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "phase: %s\n", err.Phase)
+	if err.Path != "" {
+		fmt.Fprintf(&s, "path: %s\n", err.Path)
+	}
+	if err.HelmRelease != "" {
+		fmt.Fprintf(&s, "helmrelease: %s\n", err.HelmRelease)
+	}
+	if err.Source != "" {
+		fmt.Fprintf(&s, "source: %s\n", err.Source)
+	}
+	if err.Chart != "" {
+		fmt.Fprintf(&s, "chart: %s\n", err.Chart)
+	}
+	if err.File != "" && err.Line > 0 {
+		fmt.Fprintf(&s, "file: %s:%d\n", err.File, err.Line)
+	} else if err.File != "" {
+		fmt.Fprintf(&s, "file: %s\n", err.File)
+	}
+	fmt.Fprintf(&s, "message: %s\n", err.Message)
+	return s.String()
+}
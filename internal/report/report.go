@@ -0,0 +1,185 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Severity of a reported error.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Error is a single failure with the context it occurred in.
+type Error struct {
+	Severity Severity `json:"severity"`
+	// Phase is the build step which failed, e.g. kustomize, fetch, values, render or postrender.
+	Phase string `json:"phase"`
+	// Path is the input path which was built.
+	Path string `json:"path,omitempty"`
+	// HelmRelease is the namespace/name of the HelmRelease which was built.
+	HelmRelease string `json:"helmRelease,omitempty"`
+	// Source is the kind/namespace/name of the chart source of the HelmRelease.
+	Source string `json:"source,omitempty"`
+	// Chart is the chart name and requested version of the HelmRelease.
+	Chart string `json:"chart,omitempty"`
+	// File is the manifest file which declares the failed object.
+	File string `json:"file,omitempty"`
+	// Line is the line within File at which the failed object is declared.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Target returns a human readable identity of what was built.
+func (e *Error) Target() string {
+	switch {
+	case e.HelmRelease != "":
+		return fmt.Sprintf("helmrelease/%s", e.HelmRelease)
+	case e.Path != "":
+		return fmt.Sprintf("path/%s", e.Path)
+	}
+
+	return ""
+}
+
+// Target is a single build input, either an input path or a HelmRelease.
+type Target struct {
+	Path        string `json:"path,omitempty"`
+	HelmRelease string `json:"helmRelease,omitempty"`
+}
+
+func (t Target) String() string {
+	if t.HelmRelease != "" {
+		return fmt.Sprintf("helmrelease/%s", t.HelmRelease)
+	}
+
+	return fmt.Sprintf("path/%s", t.Path)
+}
+
+// Report collects all errors of a build. It is safe for concurrent use.
+type Report struct {
+	mu      sync.Mutex
+	Targets []Target `json:"targets"`
+	Errors  []*Error `json:"errors"`
+	// Skipped are the build targets which have not been built because they are not affected by the changed files.
+	Skipped []Target `json:"skipped,omitempty"`
+}
+
+// New creates an empty report.
+func New() *Report {
+	return &Report{
+		Targets: []Target{},
+		Errors:  []*Error{},
+	}
+}
+
+// AddTarget records a build input.
+func (r *Report) AddTarget(t Target) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Targets = append(r.Targets, t)
+}
+
+// Skip records a build target which has not been built.
+func (r *Report) Skip(t Target) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Skipped = append(r.Skipped, t)
+}
+
+// Add records an error.
+func (r *Report) Add(err *Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err.Severity == "" {
+		err.Severity = SeverityError
+	}
+
+	r.Errors = append(r.Errors, err)
+}
+
+// Merge adds all targets and errors of other to r.
+func (r *Report) Merge(other *Report) {
+	for _, t := range other.Targets {
+		r.AddTarget(t)
+	}
+
+	for _, err := range other.Errors {
+		r.Add(err)
+	}
+
+	for _, t := range other.Skipped {
+		r.Skip(t)
+	}
+}
+
+// HasErrors returns true if at least one error with severity error was reported.
+func (r *Report) HasErrors() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, err := range r.Errors {
+		if err.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sort orders targets and errors for a stable output.
+func (r *Report) Sort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.SliceStable(r.Targets, func(i, j int) bool {
+		return r.Targets[i].String() < r.Targets[j].String()
+	})
+
+	sort.SliceStable(r.Skipped, func(i, j int) bool {
+		return r.Skipped[i].String() < r.Skipped[j].String()
+	})
+
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		return r.Errors[i].Target() < r.Errors[j].Target()
+	})
+}
+
+// TargetErrors returns all errors which belong to the given target.
+func (r *Report) TargetErrors(t Target) []*Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []*Error
+	for _, err := range r.Errors {
+		if (t.HelmRelease != "" && err.HelmRelease == t.HelmRelease) || (t.HelmRelease == "" && err.HelmRelease == "" && err.Path == t.Path) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// WriteTable writes a human readable summary table of all errors.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tPHASE\tTARGET\tCHART\tMESSAGE")
+
+	for _, err := range r.Errors {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", err.Severity, err.Phase, err.Target(), err.Chart, strings.ReplaceAll(err.Message, "\n", " "))
+	}
+
+	return tw.Flush()
+}
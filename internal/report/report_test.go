@@ -0,0 +1,174 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func testReport() *Report {
+	r := New()
+	r.AddTarget(Target{Path: "apps"})
+	r.AddTarget(Target{HelmRelease: "apps/podinfo"})
+	r.AddTarget(Target{HelmRelease: "apps/redis"})
+	r.Add(&Error{
+		Phase:       "fetch",
+		HelmRelease: "apps/podinfo",
+		Source:      "HelmRepository/flux-system/podinfo",
+		Chart:       "podinfo@9.9.9",
+		Message:     "no chart version found",
+	})
+	r.Add(&Error{
+		Severity:    SeverityWarning,
+		Phase:       "values",
+		HelmRelease: "apps/redis",
+		Message:     "deprecated values",
+	})
+	r.Add(&Error{
+		Phase:   "kustomize",
+		Path:    "missing",
+		Message: "no such file or directory",
+	})
+	r.Sort()
+	return r
+}
+
+func TestHasErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	r := New()
+	g.Expect(r.HasErrors()).To(BeFalse())
+
+	r.Add(&Error{Severity: SeverityWarning, Message: "warning"})
+	g.Expect(r.HasErrors()).To(BeFalse())
+
+	r.Add(&Error{Message: "error"})
+	g.Expect(r.HasErrors()).To(BeTrue())
+	g.Expect(r.Errors[1].Severity).To(Equal(SeverityError))
+}
+
+func TestTargetErrorsConcurrent(t *testing.T) {
+	g := NewWithT(t)
+
+	r := New()
+	target := Target{HelmRelease: "apps/podinfo"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.Add(&Error{HelmRelease: target.HelmRelease, Message: "error"})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = r.TargetErrors(target)
+		}()
+	}
+
+	wg.Wait()
+	g.Expect(r.TargetErrors(target)).To(HaveLen(10))
+}
+
+func TestWriteTable(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(testReport().WriteTable(&buf)).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`SEVERITY  PHASE      TARGET                    CHART          MESSAGE
+error     fetch      helmrelease/apps/podinfo  podinfo@9.9.9  no chart version found
+warning   values     helmrelease/apps/redis                   deprecated values
+error     kustomize  path/missing                             no such file or directory
+`))
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(testReport().WriteJSON(&buf)).To(Succeed())
+
+	var r Report
+	g.Expect(json.Unmarshal(buf.Bytes(), &r)).To(Succeed())
+	g.Expect(r.Targets).To(HaveLen(3))
+	g.Expect(r.Errors).To(HaveLen(3))
+	g.Expect(*r.Errors[0]).To(Equal(Error{
+		Severity:    SeverityError,
+		Phase:       "fetch",
+		HelmRelease: "apps/podinfo",
+		Source:      "HelmRepository/flux-system/podinfo",
+		Chart:       "podinfo@9.9.9",
+		Message:     "no chart version found",
+	}))
+}
+
+func TestWriteJUnit(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(testReport().WriteJUnit(&buf)).To(Succeed())
+
+	var suites junitTestSuites
+	g.Expect(xml.Unmarshal(buf.Bytes(), &suites)).To(Succeed())
+	g.Expect(suites.Tests).To(Equal(4))
+	g.Expect(suites.Failures).To(Equal(2))
+
+	cases := suites.Suites[0].TestCases
+	g.Expect(cases).To(HaveLen(4))
+	g.Expect(cases[0].Name).To(Equal("helmrelease/apps/podinfo"))
+	g.Expect(cases[0].Failures).To(HaveLen(1))
+	g.Expect(cases[0].Failures[0].Type).To(Equal("fetch"))
+	g.Expect(cases[1].Name).To(Equal("helmrelease/apps/redis"))
+	g.Expect(cases[1].Failures).To(BeEmpty())
+	g.Expect(cases[1].SystemOut).To(Equal("[values] deprecated values"))
+	g.Expect(cases[2].Name).To(Equal("path/apps"))
+	g.Expect(cases[2].Failures).To(BeEmpty())
+	g.Expect(cases[3].Name).To(Equal("path/missing"))
+	g.Expect(cases[3].Failures).To(HaveLen(1))
+}
+
+func TestWriteJUnitSkipped(t *testing.T) {
+	g := NewWithT(t)
+
+	r := testReport()
+	r.Skip(Target{HelmRelease: "apps/unchanged"})
+
+	var buf bytes.Buffer
+	g.Expect(r.WriteJUnit(&buf)).To(Succeed())
+
+	var suites junitTestSuites
+	g.Expect(xml.Unmarshal(buf.Bytes(), &suites)).To(Succeed())
+	g.Expect(suites.Tests).To(Equal(5))
+	g.Expect(suites.Skipped).To(Equal(1))
+
+	cases := suites.Suites[0].TestCases
+	g.Expect(cases[3].Name).To(Equal("helmrelease/apps/unchanged"))
+	g.Expect(cases[3].Skipped).ToNot(BeNil())
+	g.Expect(cases[3].Failures).To(BeEmpty())
+}
+
+func TestWriteSARIF(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(testReport().WriteSARIF(&buf)).To(Succeed())
+
+	var log map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &log)).To(Succeed())
+	g.Expect(log["version"]).To(Equal("2.1.0"))
+
+	run := log["runs"].([]interface{})[0].(map[string]interface{})
+	results := run["results"].([]interface{})
+	g.Expect(results).To(HaveLen(3))
+
+	first := results[0].(map[string]interface{})
+	g.Expect(first["ruleId"]).To(Equal("fetch"))
+	g.Expect(first["level"]).To(Equal("error"))
+
+	second := results[1].(map[string]interface{})
+	g.Expect(second["level"]).To(Equal("warning"))
+}
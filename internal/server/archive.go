@@ -0,0 +1,139 @@
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// maxArchiveEntries is the maximum number of entries of an uploaded archive.
+const maxArchiveEntries = 100000
+
+// errArchiveTooLarge is returned if an archive exceeds the extraction limits.
+var errArchiveTooLarge = errors.New("archive too large")
+
+// extract writes the tar or gzip compressed tar archive to dir.
+// Entries and symlinks pointing outside of dir are rejected, other entry types than files, directories and symlinks are skipped.
+// Archives with more than maxArchiveEntries entries or files larger than maxSize bytes in total are rejected, the size is
+// not limited if maxSize is 0. Extraction stops once ctx is done.
+func extract(ctx context.Context, r io.Reader, dir string, maxSize int64) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	var entries int
+	var size int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if entries++; entries > maxArchiveEntries {
+			return fmt.Errorf("%w: more than %d entries", errArchiveTooLarge, maxArchiveEntries)
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			if size += hdr.Size; maxSize > 0 && size > maxSize {
+				return fmt.Errorf("%w: files exceed %d bytes", errArchiveTooLarge, maxSize)
+			}
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("entry %s is outside of the archive", hdr.Name)
+		}
+
+		path, err := securejoin.SecureJoin(dir, name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(path, &contextReader{ctx: ctx, r: tr}); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := hdr.Linkname
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+
+			if rel, err := filepath.Rel(dir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("symlink %s points outside of the archive", hdr.Name)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// contextReader fails reads once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
@@ -0,0 +1,489 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/doodlescheduling/flux-build/internal/action"
+	"github.com/doodlescheduling/flux-build/internal/charts"
+	"github.com/doodlescheduling/flux-build/internal/filter"
+	"github.com/doodlescheduling/flux-build/internal/redact"
+	"github.com/doodlescheduling/flux-build/internal/remote"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// Options configure the server.
+type Options struct {
+	// Defaults is the action each request is built with, requests override its paths, kube version, api versions,
+	// variables, redaction and filters. Its chart cache and chart repositories are shared by all requests.
+	// Requests are always built with the rootOnly load restrictor, without environment variables, exec functions
+	// and remote bases which are neither mirrored nor vendored.
+	Defaults action.Action
+	// Root is the directory request paths are resolved against, paths outside of it can not be built.
+	Root string
+	// MaxConcurrent is the number of builds running at the same time, further requests wait for a free slot.
+	MaxConcurrent int
+	// Timeout is the maximum duration of a request including the time waiting for a free slot.
+	Timeout time.Duration
+	// MaxUploadSize is the maximum size in bytes of a request body, including uploaded archives.
+	// It also limits the total size of the files extracted from an uploaded archive.
+	MaxUploadSize int64
+	// RedactKey is the key of hash redaction requested by requests, values are replaced with the placeholder if empty.
+	RedactKey []byte
+}
+
+// Request is the body of a build or render request.
+type Request struct {
+	// Path is the repository directory relative to the server root or to the root of the uploaded archive.
+	Path string `json:"path,omitempty"`
+	// Paths are the kustomize paths to build relative to Path, defaults to Path itself.
+	Paths []string `json:"paths,omitempty"`
+	// HelmRelease is the namespace/name of the HelmRelease to render, only used by render requests.
+	HelmRelease      string            `json:"helmRelease,omitempty"`
+	KubeVersion      string            `json:"kubeVersion,omitempty"`
+	APIVersions      []string          `json:"apiVersions,omitempty"`
+	IncludeHelmHooks bool              `json:"includeHelmHooks,omitempty"`
+	Variables        map[string]string `json:"variables,omitempty"`
+	// Redact is the redaction mode of v1.Secret values, one of none, placeholder or hash.
+	Redact      string   `json:"redact,omitempty"`
+	RedactRules []string `json:"redactRules,omitempty"`
+	Filter      Filter   `json:"filter,omitempty"`
+	// Timeout shortens the server timeout for this request, e.g. 30s.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Filter restricts the HelmReleases rendered and objects returned by a request.
+type Filter struct {
+	HelmReleases []string `json:"helmReleases,omitempty"`
+	Namespaces   []string `json:"namespaces,omitempty"`
+	Kinds        []string `json:"kinds,omitempty"`
+	ExcludeKinds []string `json:"excludeKinds,omitempty"`
+	Selector     string   `json:"selector,omitempty"`
+}
+
+// Response is the result of a build or render request.
+type Response struct {
+	// Manifests are the rendered objects as multi document YAML.
+	Manifests string `json:"manifests"`
+	// Report contains the build targets and all errors.
+	Report *report.Report `json:"report"`
+	// Releases are the charts the HelmReleases have been rendered from.
+	Releases []charts.Chart `json:"releases"`
+}
+
+// errorResponse is the body of requests which could not be built at all.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// requestError is an invalid request.
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{err: fmt.Errorf(format, args...)}
+}
+
+// Server builds and renders kustomize paths over HTTP.
+type Server struct {
+	opts  Options
+	slots chan struct{}
+}
+
+// New creates a server.
+func New(opts Options) *Server {
+	if opts.MaxConcurrent < 1 {
+		opts.MaxConcurrent = 1
+	}
+
+	if root, err := filepath.Abs(opts.Root); err == nil {
+		opts.Root = root
+	}
+
+	return &Server{
+		opts:  opts,
+		slots: make(chan struct{}, opts.MaxConcurrent),
+	}
+}
+
+// Handler returns the http handler serving the API:
+//
+//	POST /v1/build   builds all paths of the request
+//	POST /v1/render  renders the HelmRelease of the request only
+//	GET  /healthz    returns 200 once the server is running
+//
+// Requests are either a JSON encoded Request or multipart/form-data with the Request in the field request
+// and a tar or tar.gz archive of the repository in the field archive.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/build", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, false)
+	})
+	mux.HandleFunc("POST /v1/render", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, true)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return mux
+}
+
+// ListenAndServe serves the API on addr until ctx is done, running requests are given the request timeout to finish.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.opts.Defaults.Logger.Info("serving api", "address", addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := s.opts.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, render bool) {
+	logger := s.opts.Defaults.Logger.WithValues("remote", r.RemoteAddr, "uri", r.RequestURI)
+
+	if s.opts.MaxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUploadSize)
+	}
+
+	// Uploads are only read and extracted within the timeout and a slot
+	ctx := r.Context()
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		writeError(w, ctx.Err())
+		return
+	}
+
+	req, root, cleanup, err := s.decode(ctx, r)
+	defer cleanup()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			writeError(w, badRequest("invalid timeout %q: %w", req.Timeout, err))
+			return
+		}
+
+		if s.opts.Timeout == 0 || d < s.opts.Timeout {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+	}
+
+	a, err := s.action(req, root, render)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var out bytes.Buffer
+	a.Output = &out
+	a.Logger = logger
+	inventory := a.Charts
+
+	logger.Info("build request", "paths", a.Paths, "helmRelease", req.HelmRelease)
+	rep := a.Run(ctx)
+	relativePaths(rep, root)
+	inventory.Sort()
+
+	status := http.StatusOK
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
+	if inventory.Charts == nil {
+		inventory.Charts = []charts.Chart{}
+	}
+
+	writeJSON(w, status, &Response{
+		Manifests: out.String(),
+		Report:    rep,
+		Releases:  inventory.Charts,
+	})
+}
+
+// decode reads the request and extracts an uploaded archive into a temporary directory.
+// It returns the directory request paths are resolved against and a func removing the temporary directory.
+func (s *Server) decode(ctx context.Context, r *http.Request) (*Request, string, func(), error) {
+	cleanup := func() {}
+	req := &Request{}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return nil, "", cleanup, badRequest("invalid request: %w", err)
+		}
+
+		return req, s.opts.Root, cleanup, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", cleanup, badRequest("invalid multipart request: %w", err)
+	}
+
+	var root string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, "", cleanup, badRequest("invalid multipart request: %w", err)
+		}
+
+		switch part.FormName() {
+		case "request":
+			if err := json.NewDecoder(part).Decode(req); err != nil {
+				return nil, "", cleanup, badRequest("invalid request: %w", err)
+			}
+		case "archive":
+			if root != "" {
+				return nil, "", cleanup, badRequest("multiple archives uploaded")
+			}
+
+			root, err = os.MkdirTemp("", "flux-build-serve")
+			if err != nil {
+				return nil, "", cleanup, err
+			}
+
+			dir := root
+			cleanup = func() {
+				_ = os.RemoveAll(dir)
+			}
+
+			if err := extract(ctx, part, root, s.opts.MaxUploadSize); err != nil {
+				return nil, "", cleanup, badRequest("invalid archive: %w", err)
+			}
+		}
+	}
+
+	if root == "" {
+		return nil, "", cleanup, badRequest("multipart request without archive")
+	}
+
+	return req, root, cleanup, nil
+}
+
+// action returns the action building the request.
+func (s *Server) action(req *Request, root string, render bool) (*action.Action, error) {
+	a := s.opts.Defaults
+
+	base, err := securejoin.SecureJoin(root, req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := req.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	a.Paths = nil
+	for _, p := range paths {
+		if filepath.IsAbs(p) {
+			return nil, badRequest("path %q must be relative", p)
+		}
+
+		path, err := securejoin.SecureJoin(base, p)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			return nil, badRequest("path %s not found", filepath.Join(req.Path, p))
+		}
+
+		a.Paths = append(a.Paths, path)
+	}
+
+	if req.KubeVersion != "" {
+		v, err := chartutil.ParseKubeVersion(req.KubeVersion)
+		if err != nil {
+			return nil, badRequest("invalid kube version: %w", err)
+		}
+
+		a.KubeVersion = v
+	}
+
+	if len(req.APIVersions) > 0 {
+		a.APIVersions = req.APIVersions
+	}
+
+	if req.IncludeHelmHooks {
+		a.IncludeHelmHooks = true
+	}
+
+	// Requests can not read the environment, files outside of their root, run executables or fetch remote bases
+	a.Variables = req.Variables
+	a.IgnoreEnv = true
+	a.Kustomize.LoadRestrictor = kustypes.LoadRestrictionsRootOnly
+	a.Kustomize.ExecFunctions = nil
+	remotes := remote.Resolver{}
+	if a.Kustomize.Remotes != nil {
+		remotes = *a.Kustomize.Remotes
+	}
+
+	remotes.Offline = true
+	a.Kustomize.Remotes = &remotes
+
+	if req.Redact != "" || len(req.RedactRules) > 0 {
+		mode, err := redact.ParseMode(req.Redact)
+		if err != nil {
+			return nil, badRequest("%w", err)
+		}
+
+		rules, err := redact.ParseRules(req.RedactRules)
+		if err != nil {
+			return nil, badRequest("%w", err)
+		}
+
+		switch {
+		case mode == redact.ModeNone && len(rules) == 0:
+			a.Redactor = nil
+		case mode == redact.ModeNone:
+			a.Redactor = redact.New(redact.ModePlaceholder, rules, nil)
+		default:
+			a.Redactor = redact.New(mode, rules, s.opts.RedactKey)
+		}
+	}
+
+	f := req.Filter
+	if render {
+		namespace, name, ok := strings.Cut(req.HelmRelease, "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, badRequest("helmRelease namespace/name required")
+		}
+
+		// Only the objects rendered by the HelmRelease carry its origin labels
+		f.HelmReleases = []string{req.HelmRelease}
+		selector := fmt.Sprintf("%s/name=%s,%s/namespace=%s", helmv2.GroupVersion.Group, name, helmv2.GroupVersion.Group, namespace)
+		if f.Selector != "" {
+			selector = fmt.Sprintf("%s,%s", f.Selector, selector)
+		}
+
+		f.Selector = selector
+	}
+
+	if len(f.HelmReleases) > 0 || len(f.Namespaces) > 0 || len(f.Kinds) > 0 || len(f.ExcludeKinds) > 0 || f.Selector != "" {
+		resourceFilter, err := filter.New(f.HelmReleases, f.Namespaces, f.Kinds, f.ExcludeKinds, f.Selector)
+		if err != nil {
+			return nil, badRequest("%w", err)
+		}
+
+		a.Filter = resourceFilter
+	}
+
+	// Inventories are collected per request
+	a.Images = nil
+	a.Charts = &charts.Inventory{}
+	return &a, nil
+}
+
+// relativePaths rewrites the paths of the report relative to root to not expose the directories of the server.
+func relativePaths(rep *report.Report, root string) {
+	rel := func(path string) string {
+		if path == "" || !filepath.IsAbs(path) {
+			return path
+		}
+
+		if r, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(r, "..") {
+			return r
+		}
+
+		return path
+	}
+
+	for i := range rep.Targets {
+		rep.Targets[i].Path = rel(rep.Targets[i].Path)
+	}
+
+	for _, e := range rep.Errors {
+		e.Path = rel(e.Path)
+		e.File = rel(e.File)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	var maxBytesErr *http.MaxBytesError
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, errArchiveTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &reqErr):
+		status = http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doodlescheduling/flux-build/internal/action"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+)
+
+const configMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: apps
+data:
+  key: value
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+  namespace: apps
+stringData:
+  password: secret
+`
+
+func testServer(t *testing.T, root string) *httptest.Server {
+	srv := New(Options{
+		Defaults: action.Action{
+			Logger:  logr.Discard(),
+			Workers: 1,
+		},
+		Root:          root,
+		MaxConcurrent: 1,
+		MaxUploadSize: 1 << 20,
+	})
+
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func post(t *testing.T, url, contentType string, body []byte) (int, map[string]interface{}) {
+	res, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+	result := make(map[string]interface{})
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, result
+}
+
+func TestBuild(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(root, "repo", "apps"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "repo", "apps", "app.yaml"), []byte(configMap), 0644)).To(Succeed())
+	ts := testServer(t, root)
+
+	status, result := post(t, ts.URL+"/v1/build", "application/json", []byte(`{"path":"repo","paths":["apps"],"redact":"placeholder","filter":{"kinds":["Secret"]}}`))
+	g.Expect(status).To(Equal(http.StatusOK))
+	g.Expect(result["manifests"]).To(ContainSubstring("kind: Secret"))
+	g.Expect(result["manifests"]).ToNot(ContainSubstring("kind: ConfigMap"))
+	g.Expect(result["manifests"]).ToNot(ContainSubstring("password: secret"))
+	g.Expect(result["report"]).To(HaveKeyWithValue("targets", []interface{}{map[string]interface{}{"path": "repo/apps"}}))
+	g.Expect(result["releases"]).To(BeEmpty())
+
+	status, result = post(t, ts.URL+"/v1/build", "application/json", []byte(`{"path":"../..","paths":["etc"]}`))
+	g.Expect(status).To(Equal(http.StatusBadRequest))
+	g.Expect(result["error"]).To(Equal("path ../../etc not found"))
+
+	status, result = post(t, ts.URL+"/v1/render", "application/json", []byte(`{"path":"repo","paths":["apps"]}`))
+	g.Expect(status).To(Equal(http.StatusBadRequest))
+	g.Expect(result["error"]).To(Equal("helmRelease namespace/name required"))
+
+	status, _ = post(t, ts.URL+"/v1/build", "application/json", []byte(`{"timeout":"never"}`))
+	g.Expect(status).To(Equal(http.StatusBadRequest))
+}
+
+// upload returns a multipart request body with the request and a tar.gz archive of the files.
+func upload(t *testing.T, request string, files map[string]string) (string, []byte) {
+	g := NewWithT(t)
+
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+	for name, content := range files {
+		g.Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte(content))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	g.Expect(tw.Close()).To(Succeed())
+	g.Expect(zw.Close()).To(Succeed())
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	g.Expect(mw.WriteField("request", request)).To(Succeed())
+	fw, err := mw.CreateFormFile("archive", "repo.tgz")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = fw.Write(archive.Bytes())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mw.Close()).To(Succeed())
+
+	return mw.FormDataContentType(), body.Bytes()
+}
+
+func TestBuildArchive(t *testing.T) {
+	g := NewWithT(t)
+
+	contentType, body := upload(t, `{"paths":["apps"]}`, map[string]string{"apps/app.yaml": configMap})
+	ts := testServer(t, t.TempDir())
+	status, result := post(t, ts.URL+"/v1/build", contentType, body)
+	g.Expect(status).To(Equal(http.StatusOK))
+	g.Expect(result["manifests"]).To(ContainSubstring("kind: ConfigMap"))
+	g.Expect(result["report"]).To(HaveKeyWithValue("targets", []interface{}{map[string]interface{}{"path": "apps"}}))
+
+	status, result = post(t, ts.URL+"/v1/build", "application/json", []byte(`{"path":"`+strings.Repeat("x", 2<<20)+`"}`))
+	g.Expect(status).To(Equal(http.StatusRequestEntityTooLarge))
+	g.Expect(result["error"]).To(ContainSubstring("request body too large"))
+}
+
+func TestBuildArchiveTooLarge(t *testing.T) {
+	g := NewWithT(t)
+
+	// The compressed archive is well below the upload size limit
+	contentType, body := upload(t, `{"paths":["apps"]}`, map[string]string{
+		"apps/app.yaml":  configMap,
+		"apps/zero.yaml": strings.Repeat("\x00", 8<<20),
+	})
+	g.Expect(len(body)).To(BeNumerically("<", 1<<20))
+
+	root := t.TempDir()
+	ts := testServer(t, root)
+	status, result := post(t, ts.URL+"/v1/build", contentType, body)
+	g.Expect(status).To(Equal(http.StatusRequestEntityTooLarge))
+	g.Expect(result["error"]).To(ContainSubstring("archive too large: files exceed 1048576 bytes"))
+
+	entries, err := os.ReadDir(root)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+}
+
+func TestBuildArchiveWaitsForSlot(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	srv := New(Options{
+		Defaults: action.Action{
+			Logger:  logr.Discard(),
+			Workers: 1,
+		},
+		Root:          root,
+		MaxConcurrent: 1,
+		Timeout:       100 * time.Millisecond,
+		MaxUploadSize: 1 << 20,
+	})
+
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	// The only slot is taken, the upload must not be extracted while waiting for it
+	srv.slots <- struct{}{}
+	defer func() { <-srv.slots }()
+
+	contentType, body := upload(t, `{"paths":["apps"]}`, map[string]string{"apps/app.yaml": configMap})
+	status, _ := post(t, ts.URL+"/v1/build", contentType, body)
+	g.Expect(status).To(Equal(http.StatusGatewayTimeout))
+
+	entries, err := os.ReadDir(root)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+}
+
+func TestBuildArchiveRestricted(t *testing.T) {
+	g := NewWithT(t)
+
+	contentType, body := upload(t, `{"paths":["apps"]}`, map[string]string{
+		"apps/kustomization.yaml": "resources:\n- ../app.yaml\n",
+		"app.yaml":                configMap,
+	})
+
+	ts := testServer(t, t.TempDir())
+	status, result := post(t, ts.URL+"/v1/build", contentType, body)
+	g.Expect(status).To(Equal(http.StatusOK))
+	g.Expect(result["manifests"]).To(BeEmpty())
+	g.Expect(result["report"]).To(HaveKey("errors"))
+
+	errs := result["report"].(map[string]interface{})["errors"].([]interface{})
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errs[0]).To(HaveKeyWithValue("message", ContainSubstring("is not in or below")))
+
+	// Remote bases are not fetched
+	contentType, body = upload(t, `{"paths":["apps"]}`, map[string]string{
+		"apps/kustomization.yaml": "resources:\n- github.com/org/repo//base?ref=v1\n",
+	})
+
+	status, result = post(t, ts.URL+"/v1/build", contentType, body)
+	g.Expect(status).To(Equal(http.StatusOK))
+	errs = result["report"].(map[string]interface{})["errors"].([]interface{})
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errs[0]).To(HaveKeyWithValue("message", ContainSubstring("is neither mirrored nor vendored")))
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		hdr  tar.Header
+		err  string
+	}{
+		{
+			name: "file",
+			hdr:  tar.Header{Name: "dir/file.yaml", Typeflag: tar.TypeReg},
+		},
+		{
+			name: "relative symlink",
+			hdr:  tar.Header{Name: "dir/link", Linkname: "../file.yaml", Typeflag: tar.TypeSymlink},
+		},
+		{
+			name: "entry outside of archive",
+			hdr:  tar.Header{Name: "../file.yaml", Typeflag: tar.TypeReg},
+			err:  "entry ../file.yaml is outside of the archive",
+		},
+		{
+			name: "symlink outside of archive",
+			hdr:  tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink},
+			err:  "symlink link points outside of the archive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var archive bytes.Buffer
+			tw := tar.NewWriter(&archive)
+			g.Expect(tw.WriteHeader(&test.hdr)).To(Succeed())
+			g.Expect(tw.Close()).To(Succeed())
+
+			dir := t.TempDir()
+			err := extract(context.TODO(), &archive, dir, 0)
+			if test.err != "" {
+				g.Expect(err).To(MatchError(test.err))
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			_, err = os.Lstat(filepath.Join(dir, strings.TrimSuffix(test.hdr.Name, "/")))
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package sourceignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fluxcd/pkg/sourceignore"
+	"github.com/fluxcd/pkg/sourceignore/gitignore"
+)
+
+// Filter decides which files the source-controller excludes from the artifact of a git repository.
+type Filter struct {
+	root    string
+	matcher gitignore.Matcher
+}
+
+// NewFilter creates the filter for the files within dir. The .sourceignore files are read from the root of the
+// git repository containing dir down to dir and within dir, the repository root is dir itself if it is not
+// within a git repository. ignore are additional patterns like the spec.ignore of a GitRepository.
+// As the source-controller the default patterns are only used if neither .sourceignore files nor ignore
+// patterns exist.
+func NewFilter(dir string, ignore []string) (*Filter, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root := repositoryRoot(dir)
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ps []gitignore.Pattern
+	var domain []string
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if name == "." {
+			break
+		}
+
+		parent, err := sourceignore.ReadIgnoreFile(filepath.Join(root, filepath.Join(domain...), sourceignore.IgnoreFile), domain)
+		if err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, parent...)
+		domain = append(domain, name)
+	}
+
+	within, err := sourceignore.LoadIgnorePatterns(dir, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	ps = append(ps, within...)
+	ps = append(ps, sourceignore.ReadPatterns(strings.NewReader(strings.Join(ignore, "\n")), nil)...)
+
+	f := &Filter{
+		root:    root,
+		matcher: sourceignore.NewDefaultMatcher(nil, nil),
+	}
+
+	if len(ps) > 0 {
+		f.matcher = sourceignore.NewMatcher(append(sourceignore.VCSPatterns(nil), ps...))
+	}
+
+	return f, nil
+}
+
+// Ignore returns true if the file at path is excluded from the artifact.
+func (f *Filter) Ignore(path string, isDir bool) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	return f.matcher.Match(strings.Split(rel, string(filepath.Separator)), isDir)
+}
+
+// repositoryRoot returns the closest parent directory of dir containing a .git directory or file, or dir itself.
+func repositoryRoot(dir string) string {
+	for parent := dir; ; {
+		if _, err := os.Stat(filepath.Join(parent, ".git")); err == nil {
+			return parent
+		}
+
+		next := filepath.Dir(parent)
+		if next == parent {
+			return dir
+		}
+
+		parent = next
+	}
+}
@@ -0,0 +1,100 @@
+package sourceignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		dir      string
+		ignore   []string
+		ignored  []string
+		included []string
+	}{
+		{
+			name: "default patterns",
+			files: map[string]string{
+				".git/HEAD":                  "",
+				"apps/release.yaml":          "",
+				"apps/logo.png":              "",
+				"apps/.sops.yaml":            "",
+				".github/workflows/ci.yaml":  "",
+				"apps/.github/workflow.yaml": "",
+			},
+			ignored:  []string{"apps/logo.png", "apps/.sops.yaml", ".github", "apps/.github/workflow.yaml"},
+			included: []string{"apps/release.yaml"},
+		},
+		{
+			name: "sourceignore files replace the default patterns",
+			files: map[string]string{
+				".git/HEAD":             "",
+				".sourceignore":         "*.txt\n# comment\n",
+				"apps/.sourceignore":    "/tests/\n!keep.txt\n",
+				"apps/release.yaml":     "",
+				"apps/logo.png":         "",
+				"apps/notes.txt":        "",
+				"apps/keep.txt":         "",
+				"apps/tests/test.yaml":  "",
+				"other/tests/test.yaml": "",
+			},
+			dir:      "apps",
+			ignored:  []string{"apps/notes.txt", "apps/tests", "apps/tests/test.yaml"},
+			included: []string{"apps/release.yaml", "apps/logo.png", "apps/keep.txt", "other/tests/test.yaml"},
+		},
+		{
+			name: "ignore patterns",
+			files: map[string]string{
+				".git/HEAD":                 "",
+				"clusters/prod/apps.yaml":   "",
+				"clusters/prod/README.yaml": "",
+				"docs/example.yaml":         "",
+			},
+			dir:      "clusters/prod",
+			ignore:   []string{"/*", "!/clusters", "**/README.yaml"},
+			ignored:  []string{"docs/example.yaml", "clusters/prod/README.yaml"},
+			included: []string{"clusters/prod/apps.yaml"},
+		},
+		{
+			name: "not within a git repository",
+			files: map[string]string{
+				".sourceignore": "/apps.yaml\n",
+				"apps.yaml":     "",
+				"sub/apps.yaml": "",
+			},
+			ignored:  []string{"apps.yaml"},
+			included: []string{"sub/apps.yaml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			root := t.TempDir()
+			for name, content := range test.files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(root, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			f, err := NewFilter(filepath.Join(root, test.dir), test.ignore)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			for _, name := range test.ignored {
+				path := filepath.Join(root, name)
+				stat, err := os.Stat(path)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(f.Ignore(path, stat.IsDir())).To(BeTrue(), name)
+			}
+
+			for _, name := range test.included {
+				g.Expect(f.Ignore(filepath.Join(root, name), false)).To(BeFalse(), name)
+			}
+		})
+	}
+}
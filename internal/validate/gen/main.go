@@ -0,0 +1,569 @@
+// Command gen generates the OpenAPI v3 schemas of the built-in kubernetes types for each supported kubernetes version.
+//
+// The schemas are derived from the API types of the required k8s.io/api module the same way openapi-gen derives the
+// published kubernetes OpenAPI schemas. Fields are required unless they are marked +optional or are omitted if empty.
+// A kind is part of the schemas of a kubernetes version if the API has been introduced and has not been removed at that
+// version according to its prerelease lifecycle.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+const refPrefix = "#/components/schemas/"
+
+var (
+	output   = flag.String("output", "schemas", "Directory to write the schemas to")
+	minMinor = flag.Int("min-minor", 16, "Oldest kubernetes 1.x minor version to generate schemas for")
+)
+
+type document struct {
+	OpenAPI    string     `json:"openapi"`
+	Info       info       `json:"info"`
+	Components components `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type components struct {
+	Schemas map[string]definition `json:"schemas"`
+}
+
+// definition is a named schema, kinds are tagged with their group version kind like in the kubernetes OpenAPI schemas.
+type definition struct {
+	apiextensionsv1.JSONSchemaProps
+	GroupVersionKind []groupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+type groupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+type lifecycleIntroduced interface {
+	APILifecycleIntroduced() (major, minor int)
+}
+
+type lifecycleRemoved interface {
+	APILifecycleRemoved() (major, minor int)
+}
+
+type openAPISchemaType interface {
+	OpenAPISchemaType() []string
+}
+
+type openAPISchemaFormat interface {
+	OpenAPISchemaFormat() string
+}
+
+type openAPIV3OneOfTypes interface {
+	OpenAPIV3OneOfTypes() []string
+}
+
+var (
+	jsonMarshaler = reflect.TypeFor[json.Marshaler]()
+	objectMeta    = reflect.TypeFor[interface{ GetName() string }]()
+	listMeta      = reflect.TypeFor[interface{ GetContinue() string }]()
+)
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+	utilruntime.Must(helmv2.AddToScheme(scheme))
+	utilruntime.Must(sourcev1.AddToScheme(scheme))
+	utilruntime.Must(sourcev1beta2.AddToScheme(scheme))
+
+	maxMinor, err := apiMinor()
+	if err != nil {
+		return err
+	}
+
+	g := &generator{
+		definitions: make(map[string]definition),
+		markers:     make(map[string]map[string]fieldMarkers),
+	}
+
+	kinds := make(map[schema.GroupVersionKind]string)
+	for gvk, t := range scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || !isResource(t) {
+			continue
+		}
+
+		name, err := g.define(t)
+		if err != nil {
+			return err
+		}
+
+		kinds[gvk] = name
+	}
+
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		return err
+	}
+
+	for minor := *minMinor; minor <= maxMinor; minor++ {
+		doc := document{
+			OpenAPI: "3.0.0",
+			Info: info{
+				Title:   "Kubernetes",
+				Version: fmt.Sprintf("v1.%d", minor),
+			},
+			Components: components{
+				Schemas: make(map[string]definition),
+			},
+		}
+
+		for gvk, name := range kinds {
+			obj, err := scheme.New(gvk)
+			if err != nil {
+				return err
+			}
+
+			if !served(obj, minor) {
+				continue
+			}
+
+			g.collect(name, doc.Components.Schemas)
+
+			def := doc.Components.Schemas[name]
+			def.GroupVersionKind = append(def.GroupVersionKind, groupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind})
+			doc.Components.Schemas[name] = def
+		}
+
+		for name, def := range doc.Components.Schemas {
+			slices.SortFunc(def.GroupVersionKind, func(a, b groupVersionKind) int {
+				return strings.Compare(a.Group+"/"+a.Version+"/"+a.Kind, b.Group+"/"+b.Version+"/"+b.Kind)
+			})
+			doc.Components.Schemas[name] = def
+		}
+
+		if err := write(filepath.Join(*output, fmt.Sprintf("v1.%d.json.gz", minor)), doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apiMinor returns the kubernetes minor version of the k8s.io/api module.
+func apiMinor() (int, error) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return 0, fmt.Errorf("failed to read build info")
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != "k8s.io/api" {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimPrefix(dep.Version, "v"), ".")
+		if len(parts) < 2 {
+			break
+		}
+
+		return strconv.Atoi(parts[1])
+	}
+
+	return 0, fmt.Errorf("failed to detect version of k8s.io/api")
+}
+
+// isResource returns true for objects and lists, options and events of the API machinery are skipped.
+func isResource(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return p.Implements(objectMeta) || p.Implements(listMeta)
+}
+
+// served returns true if the API of the object is served at the kubernetes 1.x minor version.
+func served(obj runtime.Object, minor int) bool {
+	if o, ok := obj.(lifecycleIntroduced); ok {
+		if major, introduced := o.APILifecycleIntroduced(); major == 1 && introduced > minor {
+			return false
+		}
+	}
+
+	if o, ok := obj.(lifecycleRemoved); ok {
+		if major, removed := o.APILifecycleRemoved(); major == 1 && removed <= minor {
+			return false
+		}
+	}
+
+	return true
+}
+
+func write(path string, doc document) error {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+type fieldMarkers struct {
+	optional bool
+	required bool
+}
+
+type generator struct {
+	definitions map[string]definition
+	// markers holds the markers of the struct fields by package path and type name.field name
+	markers map[string]map[string]fieldMarkers
+}
+
+// collect adds a definition and all definitions it references to schemas.
+func (g *generator) collect(name string, schemas map[string]definition) {
+	if _, ok := schemas[name]; ok {
+		return
+	}
+
+	def := g.definitions[name]
+	schemas[name] = def
+	walkRefs(&def.JSONSchemaProps, func(ref string) {
+		g.collect(strings.TrimPrefix(ref, refPrefix), schemas)
+	})
+}
+
+func walkRefs(s *apiextensionsv1.JSONSchemaProps, fn func(ref string)) {
+	if s.Ref != nil {
+		fn(*s.Ref)
+	}
+
+	for _, p := range s.Properties {
+		walkRefs(&p, fn)
+	}
+
+	if s.Items != nil && s.Items.Schema != nil {
+		walkRefs(s.Items.Schema, fn)
+	}
+
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		walkRefs(s.AdditionalProperties.Schema, fn)
+	}
+}
+
+// definitionName returns the OpenAPI definition name of a type, k8s.io/api/core/v1.Pod is named io.k8s.api.core.v1.Pod.
+func definitionName(t reflect.Type) string {
+	host, path, _ := strings.Cut(t.PkgPath(), "/")
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(append(labels, strings.ReplaceAll(path, "/", "."), t.Name()), ".")
+}
+
+// define adds the definition of a named struct type and returns its name.
+func (g *generator) define(t reflect.Type) (string, error) {
+	name := definitionName(t)
+	if _, ok := g.definitions[name]; ok {
+		return name, nil
+	}
+
+	// Reserve the name for recursive types
+	g.definitions[name] = definition{}
+
+	s := apiextensionsv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: make(map[string]apiextensionsv1.JSONSchemaProps),
+	}
+
+	if err := g.fields(t, &s); err != nil {
+		return "", err
+	}
+
+	g.definitions[name] = definition{JSONSchemaProps: s}
+	return name, nil
+}
+
+// fields adds the properties of the struct fields, inlined structs are flattened.
+func (g *generator) fields(t reflect.Type, s *apiextensionsv1.JSONSchemaProps) error {
+	markers, err := g.structMarkers(t)
+	if err != nil {
+		return err
+	}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		if f.Anonymous && name == "" && (!hasTag || strings.Contains(opts, "inline")) {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				if err := g.fields(ft, s); err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		prop, err := g.schema(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t, f.Name, err)
+		}
+
+		// Go decodes null to the zero value
+		prop.Nullable = true
+		s.Properties[name] = prop
+
+		m := markers[f.Name]
+		omitEmpty := strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,")
+		if m.required || (!m.optional && !omitEmpty) {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return nil
+}
+
+// schema returns the schema of a type, named structs are referenced.
+func (g *generator) schema(t reflect.Type) (apiextensionsv1.JSONSchemaProps, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if s, ok := customSchema(t); ok {
+		return s, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return apiextensionsv1.JSONSchemaProps{Type: "boolean"}, nil
+	case reflect.String:
+		return apiextensionsv1.JSONSchemaProps{Type: "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return apiextensionsv1.JSONSchemaProps{Type: "integer", Format: "int32"}, nil
+	case reflect.Int64, reflect.Uint64:
+		return apiextensionsv1.JSONSchemaProps{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32, reflect.Float64:
+		return apiextensionsv1.JSONSchemaProps{Type: "number", Format: "double"}, nil
+	case reflect.Interface:
+		return apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: new(true)}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return apiextensionsv1.JSONSchemaProps{Type: "string", Format: "byte"}, nil
+		}
+
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return items, err
+		}
+
+		return apiextensionsv1.JSONSchemaProps{
+			Type:  "array",
+			Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &items},
+		}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return values, err
+		}
+
+		return apiextensionsv1.JSONSchemaProps{
+			Type:                 "object",
+			AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{Allows: true, Schema: &values},
+		}, nil
+	case reflect.Struct:
+		name, err := g.define(t)
+		if err != nil {
+			return apiextensionsv1.JSONSchemaProps{}, err
+		}
+
+		return apiextensionsv1.JSONSchemaProps{Ref: new(refPrefix + name)}, nil
+	}
+
+	return apiextensionsv1.JSONSchemaProps{}, fmt.Errorf("unsupported type %s", t)
+}
+
+// customSchema returns the schema of types with a custom JSON encoding.
+// Types without a declared OpenAPI type accept any value.
+func customSchema(t reflect.Type) (apiextensionsv1.JSONSchemaProps, bool) {
+	v := reflect.New(t)
+	if !t.Implements(jsonMarshaler) && !v.Type().Implements(jsonMarshaler) {
+		if _, ok := v.Interface().(openAPISchemaType); !ok {
+			return apiextensionsv1.JSONSchemaProps{}, false
+		}
+	}
+
+	if o, ok := v.Interface().(openAPIV3OneOfTypes); ok && len(o.OpenAPIV3OneOfTypes()) > 1 {
+		return apiextensionsv1.JSONSchemaProps{XIntOrString: true}, true
+	}
+
+	o, ok := v.Interface().(openAPISchemaType)
+	if !ok || len(o.OpenAPISchemaType()) != 1 || o.OpenAPISchemaType()[0] == "object" {
+		return apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: new(true)}, true
+	}
+
+	s := apiextensionsv1.JSONSchemaProps{Type: o.OpenAPISchemaType()[0]}
+	if f, ok := v.Interface().(openAPISchemaFormat); ok {
+		s.Format = f.OpenAPISchemaFormat()
+	}
+
+	return s, true
+}
+
+// structMarkers returns the markers of the fields of a struct type parsed from the source of its package.
+func (g *generator) structMarkers(t reflect.Type) (map[string]fieldMarkers, error) {
+	pkg, ok := g.markers[t.PkgPath()]
+	if !ok {
+		var err error
+		if pkg, err = parseMarkers(t.PkgPath()); err != nil {
+			return nil, err
+		}
+
+		g.markers[t.PkgPath()] = pkg
+	}
+
+	markers := make(map[string]fieldMarkers)
+	prefix := t.Name() + "."
+	for key, m := range pkg {
+		if field, ok := strings.CutPrefix(key, prefix); ok {
+			markers[field] = m
+		}
+	}
+
+	return markers, nil
+}
+
+func parseMarkers(pkgPath string) (map[string]fieldMarkers, error) {
+	dir, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkgPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate package %s: %w", pkgPath, err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(strings.TrimSpace(string(dir)), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	markers := make(map[string]fieldMarkers)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+
+			for _, field := range st.Fields.List {
+				m := commentMarkers(field.Doc)
+				for _, name := range field.Names {
+					markers[spec.Name.Name+"."+name.Name] = m
+				}
+
+				if len(field.Names) == 0 {
+					markers[spec.Name.Name+"."+embeddedName(field.Type)] = m
+				}
+			}
+
+			return false
+		})
+	}
+
+	return markers, nil
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	}
+
+	return ""
+}
+
+func commentMarkers(doc *ast.CommentGroup) fieldMarkers {
+	var m fieldMarkers
+	if doc == nil {
+		return m
+	}
+
+	for _, c := range doc.List {
+		switch strings.TrimSpace(strings.TrimPrefix(c.Text, "//")) {
+		case "+optional", "+kubebuilder:validation:Optional":
+			m.optional = true
+		case "+required", "+kubebuilder:validation:Required":
+			m.required = true
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,378 @@
+package validate
+
+import (
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	helmv2 "github.com/fluxcd/helm-controller/api/v2"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	structuralpruning "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	jsonserializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+//go:generate go run ./gen -output schemas
+
+// schemas holds the OpenAPI v3 schemas of the built-in types for each supported kubernetes minor version.
+//
+//go:embed schemas/*.json.gz
+var schemas embed.FS
+
+const refPrefix = "#/components/schemas/"
+
+var crdGroupKind = apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition").GroupKind()
+
+// ErrNoSchema is returned if neither a built-in schema nor a CustomResourceDefinition is known for an object.
+var ErrNoSchema = errors.New("no schema found")
+
+// Validator validates objects against the schemas of built-in kubernetes types
+// and the schemas of CustomResourceDefinitions. It works without any network access.
+type Validator struct {
+	scheme      *runtime.Scheme
+	decoder     runtime.Decoder
+	kubeVersion string
+	definitions map[string]apiextensionsv1.JSONSchemaProps
+	kinds       map[schema.GroupVersionKind]string
+	builtins    map[schema.GroupVersionKind]*objectSchema
+	crds        map[schema.GroupVersionKind]*objectSchema
+}
+
+type objectSchema struct {
+	structural *structuralschema.Structural
+	validator  validation.SchemaValidator
+}
+
+type document struct {
+	Components struct {
+		Schemas map[string]definition `json:"schemas"`
+	} `json:"components"`
+}
+
+type definition struct {
+	apiextensionsv1.JSONSchemaProps
+	GroupVersionKind []schema.GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// New creates a Validator which knows the schemas of all built-in kubernetes types served by the given kubernetes version
+// as well as the flux HelmRelease and source types. The bundled schemas of the latest kubernetes version which is not newer
+// than the given version are used, versions older than the bundled schemas are validated as the oldest bundled version.
+// The latest bundled schemas are used if no version is given.
+func New(kubeVersion string) (*Validator, error) {
+	versions, err := bundledVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := versions[len(versions)-1]
+	target := selected
+	if kubeVersion != "" {
+		current, err := semver.NewVersion(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubernetes version %q: %w", kubeVersion, err)
+		}
+
+		// Patch releases and prereleases serve the APIs of their minor version
+		target = semver.New(current.Major(), current.Minor(), 0, "", "")
+		if target.LessThan(versions[0]) {
+			target = versions[0]
+		}
+
+		selected = versions[0]
+		for _, version := range versions {
+			if !target.LessThan(version) {
+				selected = version
+			}
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+	utilruntime.Must(helmv2.AddToScheme(scheme))
+	utilruntime.Must(sourcev1.AddToScheme(scheme))
+	utilruntime.Must(sourcev1beta2.AddToScheme(scheme))
+
+	v := &Validator{
+		scheme: scheme,
+		decoder: jsonserializer.NewSerializerWithOptions(jsonserializer.DefaultMetaFactory, scheme, scheme, jsonserializer.SerializerOptions{
+			Strict: true,
+		}),
+		kubeVersion: fmt.Sprintf("v%d.%d", target.Major(), target.Minor()),
+		definitions: make(map[string]apiextensionsv1.JSONSchemaProps),
+		kinds:       make(map[schema.GroupVersionKind]string),
+		builtins:    make(map[schema.GroupVersionKind]*objectSchema),
+		crds:        make(map[schema.GroupVersionKind]*objectSchema),
+	}
+
+	if err := v.load(fmt.Sprintf("schemas/v%d.%d.json.gz", selected.Major(), selected.Minor())); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// bundledVersions returns the kubernetes versions of the bundled schemas in ascending order.
+func bundledVersions() ([]*semver.Version, error) {
+	files, err := schemas.ReadDir("schemas")
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*semver.Version
+	for _, file := range files {
+		version, err := semver.NewVersion(strings.TrimSuffix(file.Name(), ".json.gz"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schemas %s: %w", file.Name(), err)
+		}
+
+		versions = append(versions, version)
+	}
+
+	if len(versions) == 0 {
+		return nil, errors.New("no bundled schemas found")
+	}
+
+	slices.SortFunc(versions, func(a, b *semver.Version) int {
+		return a.Compare(b)
+	})
+
+	return versions, nil
+}
+
+// load reads the definitions and kinds of bundled schemas.
+func (v *Validator) load(file string) error {
+	f, err := schemas.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read schemas %s: %w", file, err)
+	}
+
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode schemas %s: %w", file, err)
+	}
+
+	for name, def := range doc.Components.Schemas {
+		v.definitions[name] = def.JSONSchemaProps
+		for _, gvk := range def.GroupVersionKind {
+			v.kinds[gvk] = name
+		}
+	}
+
+	return nil
+}
+
+// IsCRD returns true if the object is a CustomResourceDefinition.
+func IsCRD(res *resource.Resource) bool {
+	gvk := schema.FromAPIVersionAndKind(res.GetApiVersion(), res.GetKind())
+	return gvk.GroupKind() == crdGroupKind
+}
+
+// AddCRD registers the schemas of all versions of a CustomResourceDefinition.
+func (v *Validator) AddCRD(res *resource.Resource) error {
+	b, err := res.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	// Strict decoding errors are reported by Validate
+	obj, _, err := v.decoder.Decode(b, nil, &apiextensionsv1.CustomResourceDefinition{})
+	if err != nil && !runtime.IsStrictDecodingError(err) {
+		return fmt.Errorf("failed to decode CustomResourceDefinition %s: %w", res.GetName(), err)
+	}
+
+	crd, ok := obj.(*apiextensionsv1.CustomResourceDefinition)
+	if !ok {
+		return fmt.Errorf("failed to decode CustomResourceDefinition %s: unexpected type %T", res.GetName(), obj)
+	}
+
+	for _, version := range crd.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+
+		objSchema, err := newObjectSchema(version.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return fmt.Errorf("invalid schema of CustomResourceDefinition %s version %s: %w", crd.Name, version.Name, err)
+		}
+
+		gvk := schema.GroupVersionKind{
+			Group:   crd.Spec.Group,
+			Version: version.Name,
+			Kind:    crd.Spec.Names.Kind,
+		}
+
+		v.crds[gvk] = objSchema
+	}
+
+	return nil
+}
+
+// Validate validates an object and returns all violations.
+// It returns ErrNoSchema if no schema is known for the object.
+func (v *Validator) Validate(res *resource.Resource) ([]string, error) {
+	b, err := res.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	gvk := obj.GroupVersionKind()
+
+	if crd, ok := v.crds[gvk]; ok {
+		return v.validateSchema(crd, obj), nil
+	}
+
+	if _, ok := v.kinds[gvk]; ok {
+		builtin, err := v.builtinSchema(gvk)
+		if err != nil {
+			return nil, err
+		}
+
+		return v.validateSchema(builtin, obj), nil
+	}
+
+	if v.isBuiltin(gvk) {
+		return []string{fmt.Sprintf("%s %s is not served by Kubernetes %s", obj.GetAPIVersion(), obj.GetKind(), v.kubeVersion)}, nil
+	}
+
+	return nil, ErrNoSchema
+}
+
+// isBuiltin returns true if the kind is a built-in object or list of any kubernetes version.
+func (v *Validator) isBuiltin(gvk schema.GroupVersionKind) bool {
+	obj, err := v.scheme.New(gvk)
+	if err != nil {
+		return false
+	}
+
+	if meta.IsListType(obj) {
+		return true
+	}
+
+	_, err = meta.Accessor(obj)
+	return err == nil
+}
+
+// builtinSchema returns the schema of a built-in kind with all references of the bundled definitions resolved.
+func (v *Validator) builtinSchema(gvk schema.GroupVersionKind) (*objectSchema, error) {
+	if s, ok := v.builtins[gvk]; ok {
+		return s, nil
+	}
+
+	def := v.resolve(apiextensionsv1.JSONSchemaProps{Ref: new(refPrefix + v.kinds[gvk])}, nil)
+	s, err := newObjectSchema(&def)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema of %s: %w", gvk, err)
+	}
+
+	v.builtins[gvk] = s
+	return s, nil
+}
+
+// resolve replaces the references of a schema with the referenced definitions.
+// Recursive references accept any value, structural schemas can not be recursive.
+func (v *Validator) resolve(s apiextensionsv1.JSONSchemaProps, seen []string) apiextensionsv1.JSONSchemaProps {
+	if s.Ref != nil {
+		name := strings.TrimPrefix(*s.Ref, refPrefix)
+		if slices.Contains(seen, name) {
+			return apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: new(true), Nullable: s.Nullable}
+		}
+
+		resolved := v.resolve(v.definitions[name], append(slices.Clip(seen), name))
+		resolved.Nullable = s.Nullable
+		return resolved
+	}
+
+	if s.Properties != nil {
+		properties := make(map[string]apiextensionsv1.JSONSchemaProps, len(s.Properties))
+		for name, p := range s.Properties {
+			properties[name] = v.resolve(p, seen)
+		}
+
+		s.Properties = properties
+	}
+
+	if s.Items != nil && s.Items.Schema != nil {
+		items := v.resolve(*s.Items.Schema, seen)
+		s.Items = &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &items}
+	}
+
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		values := v.resolve(*s.AdditionalProperties.Schema, seen)
+		s.AdditionalProperties = &apiextensionsv1.JSONSchemaPropsOrBool{Allows: true, Schema: &values}
+	}
+
+	return s
+}
+
+func newObjectSchema(s *apiextensionsv1.JSONSchemaProps) (*objectSchema, error) {
+	var props apiextensions.JSONSchemaProps
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(s, &props, nil); err != nil {
+		return nil, err
+	}
+
+	structural, err := structuralschema.NewStructural(&props)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, _, err := validation.NewSchemaValidator(&props)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectSchema{
+		structural: structural,
+		validator:  validator,
+	}, nil
+}
+
+// validateSchema validates an object against a structural schema, built-in objects are validated the same way as custom resources.
+func (v *Validator) validateSchema(s *objectSchema, obj *unstructured.Unstructured) []string {
+	var violations []string
+
+	// Pruning a copy reveals fields which are not declared in the schema
+	unknown := structuralpruning.PruneWithOptions(obj.DeepCopy().Object, s.structural, true, structuralschema.UnknownFieldPathOptions{
+		TrackUnknownFieldPaths: true,
+	})
+
+	for _, path := range unknown {
+		violations = append(violations, fmt.Sprintf("unknown field %q", path))
+	}
+
+	for _, err := range validation.ValidateCustomResource(nil, obj.Object, s.validator) {
+		violations = append(violations, err.Error())
+	}
+
+	sort.Strings(violations)
+	return violations
+}
@@ -0,0 +1,211 @@
+package validate
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+)
+
+const manifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicaz: 1
+  selector: {}
+  template:
+    spec:
+      containers:
+      - name: app
+        imagee: nginx
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [size]
+            properties:
+              size:
+                type: integer
+                maximum: 10
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  size: 20
+  color: red
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: gadget
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: release
+spec:
+  interval: 1m
+  chart:
+    spec:
+      chart: podinfo
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+`
+
+func TestValidate(t *testing.T) {
+	g := NewWithT(t)
+
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	resources, err := rf.SliceFromBytes([]byte(manifests))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	v, err := New("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(IsCRD(resources[2])).To(BeTrue())
+	g.Expect(v.AddCRD(resources[2])).To(Succeed())
+
+	violations, err := v.Validate(resources[0])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(violations).To(Equal([]string{
+		`unknown field "spec.replicaz"`,
+		`unknown field "spec.template.spec.containers[0].imagee"`,
+	}))
+
+	violations, err = v.Validate(resources[1])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(violations).To(BeEmpty())
+
+	violations, err = v.Validate(resources[2])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(violations).To(BeEmpty())
+
+	violations, err = v.Validate(resources[3])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(violations).To(Equal([]string{
+		"spec.size: Invalid value: 20: spec.size in body should be less than or equal to 10",
+		`unknown field "spec.color"`,
+	}))
+
+	_, err = v.Validate(resources[4])
+	g.Expect(err).To(MatchError(ErrNoSchema))
+
+	violations, err = v.Validate(resources[5])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(violations).To(BeEmpty())
+}
+
+func TestValidateKubeVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeVersion string
+		manifest    string
+		violations  []string
+	}{
+		{
+			name:        "missing required fields",
+			kubeVersion: "1.31.0",
+			manifest:    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  replicas: 1\n",
+			violations: []string{
+				"spec.selector: Required value",
+				"spec.template: Required value",
+			},
+		},
+		{
+			name:        "invalid value",
+			kubeVersion: "1.31.0",
+			manifest:    "apiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  ports:\n  - port: http\n",
+			violations: []string{
+				`spec.ports[0].port: Invalid value: "string": spec.ports[0].port in body must be of type integer: "string"`,
+			},
+		},
+		{
+			name:        "kubernetes version newer than the bundled schemas",
+			kubeVersion: "1.99.0",
+			manifest:    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  selector: {}\n",
+			violations: []string{
+				"spec.template: Required value",
+			},
+		},
+		{
+			name:        "api served before its removal",
+			kubeVersion: "1.24.3",
+			manifest:    "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: job\nspec:\n  schedule: '* * * * *'\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers:\n          - name: job\n",
+		},
+		{
+			name:        "api removed at the kubernetes version",
+			kubeVersion: "1.25.0",
+			manifest:    "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: job\nspec:\n  schedule: '* * * * *'\n",
+			violations: []string{
+				"batch/v1beta1 CronJob is not served by Kubernetes v1.25",
+			},
+		},
+		{
+			name:        "api removed before the oldest bundled version",
+			kubeVersion: "v1.10",
+			manifest:    "apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: app\n",
+			violations: []string{
+				"extensions/v1beta1 Deployment is not served by Kubernetes v1.16",
+			},
+		},
+		{
+			name:        "api removed with the latest version",
+			kubeVersion: "",
+			manifest:    "apiVersion: policy/v1beta1\nkind: PodDisruptionBudget\nmetadata:\n  name: app\n",
+			violations: []string{
+				"policy/v1beta1 PodDisruptionBudget is not served by Kubernetes v1.36",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			rf := provider.NewDefaultDepProvider().GetResourceFactory()
+			resources, err := rf.SliceFromBytes([]byte(test.manifest))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			v, err := New(test.kubeVersion)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			violations, err := v.Validate(resources[0])
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(violations).To(Equal(test.violations))
+		})
+	}
+}
+
+func TestNewInvalidKubeVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := New("latest")
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid kubernetes version "latest"`)))
+}
@@ -0,0 +1,233 @@
+package values
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Origins maps the dot separated path of each leaf of merged values to the source which set it.
+type Origins map[string]string
+
+// Merge records source as origin of all leaves of values. Values are expected to be merged on top of the
+// previous ones: maps are merged recursively, any other value replaces the previous one including its origins.
+// Merge is a no-op on a nil Origins.
+func (o Origins) Merge(source string, values map[string]interface{}) {
+	if o == nil {
+		return
+	}
+
+	o.merge(source, values, "")
+}
+
+func (o Origins) merge(source string, values map[string]interface{}, prefix string) {
+	for key, value := range values {
+		p := join(prefix, key)
+		if m, ok := value.(map[string]interface{}); ok {
+			if len(m) > 0 {
+				// A map replaces a previous scalar or list
+				delete(o, p)
+				o.merge(source, m, p)
+				continue
+			}
+
+			// An empty map does not change a previous map
+			if o.hasChildren(p) {
+				continue
+			}
+		}
+
+		for child := range o {
+			if strings.HasPrefix(child, p+".") {
+				delete(o, child)
+			}
+		}
+
+		o[p] = source
+	}
+}
+
+func (o Origins) hasChildren(p string) bool {
+	for child := range o {
+		if strings.HasPrefix(child, p+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MergeChart records the default values of the chart and its subcharts. The defaults of a subchart are recorded
+// first as they have a lower priority than the values of its parent. valuesFiles are the files of the chart
+// the defaults have been composed of using spec.chart.spec.valuesFiles.
+func (o Origins) MergeChart(c *helmchart.Chart, valuesFiles []string) error {
+	if o == nil {
+		return nil
+	}
+
+	o.mergeChart(c, "")
+
+	for _, file := range valuesFiles {
+		name := filepath.Clean(file)
+		if name == chartutil.ValuesfileName {
+			continue
+		}
+
+		var data []byte
+		for _, f := range c.Files {
+			if f.Name == name {
+				data = f.Data
+				break
+			}
+		}
+
+		if data == nil {
+			return fmt.Errorf("no values file found at path '%s'", file)
+		}
+
+		values, err := chartutil.ReadValues(data)
+		if err != nil {
+			return fmt.Errorf("unable to read values file '%s': %w", file, err)
+		}
+
+		o.merge(fmt.Sprintf("valuesFiles %s", name), values, "")
+	}
+
+	return nil
+}
+
+func (o Origins) mergeChart(c *helmchart.Chart, prefix string) {
+	for _, sub := range c.Dependencies() {
+		var keys []string
+		if c.Metadata != nil {
+			for _, dep := range c.Metadata.Dependencies {
+				if dep.Name == sub.Name() && dep.Alias != "" {
+					keys = append(keys, dep.Alias)
+				}
+			}
+		}
+
+		if len(keys) == 0 {
+			keys = []string{sub.Name()}
+		}
+
+		for _, key := range keys {
+			o.mergeChart(sub, join(prefix, key))
+		}
+	}
+
+	source := fmt.Sprintf("chart %s %s", c.Name(), chartutil.ValuesfileName)
+	if prefix == "" {
+		o.merge(source, c.Values, "")
+		return
+	}
+
+	o.merge(source, map[string]interface{}{prefix: c.Values}, "")
+}
+
+// lookup returns the origin of the leaf at path. Globals copied into subcharts resolve to the origin of the global.
+func (o Origins) lookup(p string) (string, bool) {
+	if source, ok := o[p]; ok {
+		return source, true
+	}
+
+	if i := strings.Index(p, "."+globalKey+"."); i >= 0 {
+		source, ok := o[p[i+1:]]
+		return source, ok
+	}
+
+	return "", false
+}
+
+// Redact replaces the leaves of values which have been set by a Secret with the result of redact, values are
+// modified in place. Lists and maps are redacted item by item.
+func Redact(values map[string]interface{}, origins Origins, redact func(string) string) {
+	redactValues(values, origins, redact, "")
+}
+
+func redactValues(values map[string]interface{}, origins Origins, redact func(string) string, prefix string) {
+	for key, value := range values {
+		p := join(prefix, key)
+		if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+			redactValues(m, origins, redact, p)
+			continue
+		}
+
+		if source, ok := origins.lookup(p); ok && strings.HasPrefix(source, "Secret ") {
+			values[key] = redactLeaf(value, redact)
+		}
+	}
+}
+
+func redactLeaf(value interface{}, redact func(string) string) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = redactLeaf(item, redact)
+		}
+
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactLeaf(item, redact)
+		}
+
+		return v
+	}
+
+	return redact(fmt.Sprint(value))
+}
+
+// Write writes values as YAML. Each leaf is annotated with its origin as a line comment
+// unless origins is nil.
+func Write(w io.Writer, values map[string]interface{}, origins Origins) error {
+	node := &yaml.Node{}
+	if err := node.Encode(values); err != nil {
+		return err
+	}
+
+	if origins != nil {
+		annotate(node, origins, "")
+	}
+
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+func annotate(node *yaml.Node, origins Origins, prefix string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		p := join(prefix, key.Value)
+
+		if value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+			annotate(value, origins, p)
+			continue
+		}
+
+		source, ok := origins.lookup(p)
+		if !ok {
+			continue
+		}
+
+		if value.Kind == yaml.ScalarNode || value.Style&yaml.FlowStyle != 0 {
+			value.LineComment = source
+		} else {
+			key.LineComment = source
+		}
+	}
+}
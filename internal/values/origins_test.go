@@ -0,0 +1,148 @@
+package values
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+)
+
+func TestOriginsMerge(t *testing.T) {
+	g := NewWithT(t)
+
+	origins := make(Origins)
+	origins.Merge("defaults", parseValues(g, `
+image:
+  repository: nginx
+  tag: ""
+podAnnotations: {}
+tolerations: []
+`))
+	origins.Merge("configmap", parseValues(g, `
+image:
+  tag: v1
+podAnnotations:
+  a: b
+`))
+	origins.Merge("inline", parseValues(g, `
+image: nginx:v2
+podAnnotations: {}
+tolerations:
+- key: a
+`))
+
+	g.Expect(origins).To(Equal(Origins{
+		"image":            "inline",
+		"podAnnotations.a": "configmap",
+		"tolerations":      "inline",
+	}))
+
+	var nilOrigins Origins
+	g.Expect(func() { nilOrigins.Merge("inline", parseValues(g, `a: b`)) }).ToNot(Panic())
+}
+
+func TestOriginsMergeChart(t *testing.T) {
+	g := NewWithT(t)
+
+	c := testChart(g)
+	c.Files = []*helmchart.File{
+		{Name: "values-prod.yaml", Data: []byte("replicas: 3\n")},
+	}
+
+	origins := make(Origins)
+	g.Expect(origins.MergeChart(c, []string{"values.yaml", "./values-prod.yaml"})).To(Succeed())
+
+	g.Expect(origins).To(HaveKeyWithValue("image.repository", "chart app values.yaml"))
+	g.Expect(origins).To(HaveKeyWithValue("replicas", "valuesFiles values-prod.yaml"))
+	g.Expect(origins).To(HaveKeyWithValue("cache.auth.password", "chart redis values.yaml"))
+	g.Expect(origins).To(HaveKeyWithValue("cache.global.redis.password", "chart redis values.yaml"))
+	g.Expect(origins).ToNot(HaveKey("redis.auth.password"))
+
+	g.Expect(origins.MergeChart(c, []string{"values-dev.yaml"})).ToNot(Succeed())
+}
+
+func TestWrite(t *testing.T) {
+	g := NewWithT(t)
+
+	values := parseValues(g, `
+image:
+  tag: v1
+hosts:
+- a
+podAnnotations: {}
+redis:
+  global:
+    registry: ghcr.io
+global:
+  registry: ghcr.io
+`)
+
+	origins := Origins{
+		"image.tag":       "ConfigMap apps/values key values.yaml",
+		"hosts":           "HelmRelease apps/app spec.values",
+		"podAnnotations":  "chart app values.yaml",
+		"global.registry": "chart app values.yaml",
+	}
+
+	var buf bytes.Buffer
+	g.Expect(Write(&buf, values, origins)).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`global:
+  registry: ghcr.io # chart app values.yaml
+hosts: # HelmRelease apps/app spec.values
+- a
+image:
+  tag: v1 # ConfigMap apps/values key values.yaml
+podAnnotations: {} # chart app values.yaml
+redis:
+  global:
+    registry: ghcr.io # chart app values.yaml
+`))
+
+	buf.Reset()
+	g.Expect(Write(&buf, values, nil)).To(Succeed())
+	g.Expect(buf.String()).ToNot(ContainSubstring("#"))
+}
+
+func TestRedact(t *testing.T) {
+	g := NewWithT(t)
+
+	values := parseValues(g, `
+image:
+  tag: v1
+auth:
+  password: secret
+  port: 5432
+hosts:
+- a
+redis:
+  global:
+    token: secret
+global:
+  token: secret
+`)
+
+	origins := Origins{
+		"image.tag":     "ConfigMap apps/values key values.yaml",
+		"auth.password": "Secret apps/values key values.yaml",
+		"auth.port":     "Secret apps/values key values.yaml targetPath auth.port",
+		"hosts":         "Secret apps/values key values.yaml",
+		"global.token":  "Secret apps/values key values.yaml",
+	}
+
+	Redact(values, origins, func(s string) string { return "redacted " + s })
+	g.Expect(values).To(Equal(parseValues(g, `
+image:
+  tag: v1
+auth:
+  password: redacted secret
+  port: redacted 5432
+hosts:
+- redacted a
+redis:
+  global:
+    token: redacted secret
+global:
+  token: redacted secret
+`)))
+}
@@ -0,0 +1,436 @@
+package values
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+)
+
+// Mode defines how unknown values are reported.
+type Mode string
+
+const (
+	// ModeIgnore disables the unknown values check.
+	ModeIgnore Mode = "ignore"
+	// ModeWarn reports unknown values as warnings.
+	ModeWarn Mode = "warn"
+	// ModeFail reports unknown values as errors.
+	ModeFail Mode = "fail"
+)
+
+// ParseMode converts a string into a Mode. An empty string equals ModeIgnore.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeIgnore:
+		return ModeIgnore, nil
+	case ModeWarn, ModeFail:
+		return Mode(s), nil
+	}
+
+	return ModeIgnore, fmt.Errorf("unsupported unknown values mode %q, expected one of ignore, warn, fail", s)
+}
+
+const globalKey = "global"
+
+// Unknown returns the dot separated paths of all values which are neither declared in the default values
+// nor in the values schema of the chart or its subcharts. Values of subcharts are checked against the
+// subchart, global values against the globals of all charts.
+func Unknown(c *helmchart.Chart, values map[string]interface{}) ([]string, error) {
+	globals, err := globalScope(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	if err := walkChart(c, values, "", globals, make(map[string]bool), &unknown); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// scope is a level of the values tree as declared by the default values and the schema.
+type scope struct {
+	defaults interface{}
+	schema   *schema
+}
+
+func chartScope(c *helmchart.Chart) (scope, error) {
+	s, err := parseSchema(c.Schema)
+	if err != nil {
+		return scope{}, fmt.Errorf("invalid values schema of chart %s: %w", c.Name(), err)
+	}
+
+	return scope{defaults: c.Values, schema: s}, nil
+}
+
+// globalScope merges the global values and schemas of the chart and all its subcharts.
+// Charts which do not declare any globals are skipped.
+func globalScope(c *helmchart.Chart) ([]scope, error) {
+	s, err := chartScope(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []scope
+	if s.has(globalKey) {
+		scopes = append(scopes, s.child(globalKey))
+	}
+
+	for _, sub := range c.Dependencies() {
+		subScopes, err := globalScope(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		scopes = append(scopes, subScopes...)
+	}
+
+	return scopes, nil
+}
+
+// walkChart checks the values of a chart. Known are keys which are accepted in addition to the declared ones,
+// e.g. the conditions of the parent chart.
+func walkChart(c *helmchart.Chart, values map[string]interface{}, prefix string, globals []scope, known map[string]bool, unknown *[]string) error {
+	s, err := chartScope(c)
+	if err != nil {
+		return err
+	}
+
+	subcharts := make(map[string]*helmchart.Chart)
+	for _, sub := range c.Dependencies() {
+		subcharts[sub.Name()] = sub
+	}
+
+	subKnown := make(map[string]map[string]bool)
+	if c.Metadata != nil {
+		for _, dep := range c.Metadata.Dependencies {
+			if sub, ok := subcharts[dep.Name]; ok && dep.Alias != "" {
+				subcharts[dep.Alias] = sub
+			}
+
+			if len(dep.Tags) > 0 {
+				known["tags"] = true
+			}
+
+			for _, condition := range strings.Split(dep.Condition, ",") {
+				condition = strings.TrimSpace(condition)
+				if condition == "" {
+					continue
+				}
+
+				key, rest, ok := strings.Cut(condition, ".")
+				if !ok {
+					known[key] = true
+					continue
+				}
+
+				if subKnown[key] == nil {
+					subKnown[key] = make(map[string]bool)
+				}
+
+				subKnown[key][rest] = true
+			}
+		}
+	}
+
+	for key, value := range values {
+		switch {
+		case known[key]:
+		case key == globalKey:
+			walk(globals, value, join(prefix, key), unknown)
+		case subcharts[key] != nil:
+			subValues, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if subKnown[key] == nil {
+				subKnown[key] = make(map[string]bool)
+			}
+
+			if err := walkChart(subcharts[key], subValues, join(prefix, key), globals, subKnown[key], unknown); err != nil {
+				return err
+			}
+		default:
+			walk([]scope{s}, map[string]interface{}{key: value}, prefix, unknown)
+		}
+	}
+
+	return nil
+}
+
+// walk adds the paths of all values which are not declared in any of the scopes to unknown.
+func walk(scopes []scope, value interface{}, prefix string, unknown *[]string) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for _, s := range scopes {
+		if s.open() {
+			return
+		}
+	}
+
+	for key, v := range values {
+		var children []scope
+		for _, s := range scopes {
+			if s.has(key) {
+				children = append(children, s.child(key))
+			}
+		}
+
+		if len(children) == 0 {
+			*unknown = append(*unknown, join(prefix, key))
+			continue
+		}
+
+		walk(children, v, join(prefix, key), unknown)
+	}
+}
+
+// open returns true if any key is accepted at this level.
+// This is the case for values without structure in the defaults, e.g. `podAnnotations: {}`, unless a schema declares the keys.
+func (s scope) open() bool {
+	if s.schema != nil && s.schema.declared() {
+		return s.schema.open()
+	}
+
+	switch d := s.defaults.(type) {
+	case map[string]interface{}:
+		return len(d) == 0
+	case nil:
+		return s.schema == nil
+	}
+
+	// Scalars and lists are not walked into
+	return true
+}
+
+func (s scope) has(key string) bool {
+	if d, ok := s.defaults.(map[string]interface{}); ok {
+		if _, ok := d[key]; ok {
+			return true
+		}
+	}
+
+	return s.schema != nil && s.schema.has(key)
+}
+
+func (s scope) child(key string) scope {
+	var child scope
+	if d, ok := s.defaults.(map[string]interface{}); ok {
+		child.defaults = d[key]
+	}
+
+	if s.schema != nil {
+		child.schema = s.schema.child(key)
+	}
+
+	return child
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%s.%s", prefix, key)
+}
+
+// schema is the subset of a JSON schema which declares object properties.
+type schema struct {
+	root                 *schema
+	Ref                  string             `json:"$ref"`
+	Properties           map[string]*schema `json:"properties"`
+	PatternProperties    map[string]*schema `json:"patternProperties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	AllOf                []*schema          `json:"allOf"`
+	AnyOf                []*schema          `json:"anyOf"`
+	OneOf                []*schema          `json:"oneOf"`
+	Definitions          map[string]*schema `json:"definitions"`
+	Defs                 map[string]*schema `json:"$defs"`
+}
+
+// UnmarshalJSON accepts boolean schemas, true accepts any value, false none.
+func (s *schema) UnmarshalJSON(b []byte) error {
+	var accept bool
+	if err := json.Unmarshal(b, &accept); err == nil {
+		if accept {
+			s.AdditionalProperties = json.RawMessage("true")
+		} else {
+			s.AdditionalProperties = json.RawMessage("false")
+		}
+
+		return nil
+	}
+
+	type plain schema
+	return json.Unmarshal(b, (*plain)(s))
+}
+
+func parseSchema(b []byte) (*schema, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	s := &schema{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+
+	s.setRoot(s)
+	return s, nil
+}
+
+func (s *schema) setRoot(root *schema) {
+	if s == nil || s.root != nil {
+		return
+	}
+
+	s.root = root
+	for _, children := range [][]*schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, child := range children {
+			child.setRoot(root)
+		}
+	}
+
+	for _, children := range []map[string]*schema{s.Properties, s.PatternProperties, s.Definitions, s.Defs} {
+		for _, child := range children {
+			child.setRoot(root)
+		}
+	}
+}
+
+// resolve follows a local $ref.
+func (s *schema) resolve() *schema {
+	for i := 0; s != nil && s.root != nil && s.Ref != "" && i < 32; i++ {
+		name, ok := strings.CutPrefix(s.Ref, "#/definitions/")
+		defs := s.root.Definitions
+		if !ok {
+			name, ok = strings.CutPrefix(s.Ref, "#/$defs/")
+			defs = s.root.Defs
+		}
+
+		if !ok || defs[name] == nil {
+			return s
+		}
+
+		s = defs[name]
+	}
+
+	return s
+}
+
+// variants returns the schema and all schemas it is composed of.
+func (s *schema) variants() []*schema {
+	s = s.resolve()
+	if s == nil {
+		return nil
+	}
+
+	variants := []*schema{s}
+	for _, children := range [][]*schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, child := range children {
+			variants = append(variants, child.variants()...)
+		}
+	}
+
+	return variants
+}
+
+// declared returns true if the schema declares the properties of an object.
+func (s *schema) declared() bool {
+	for _, v := range s.variants() {
+		if v.Properties != nil || v.PatternProperties != nil || len(v.AdditionalProperties) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// open returns true if the schema accepts any property.
+func (s *schema) open() bool {
+	for _, v := range s.variants() {
+		if len(v.PatternProperties) > 0 {
+			return true
+		}
+
+		if len(v.AdditionalProperties) > 0 && string(v.AdditionalProperties) != "false" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *schema) has(key string) bool {
+	for _, v := range s.variants() {
+		if _, ok := v.Properties[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *schema) child(key string) *schema {
+	for _, v := range s.variants() {
+		if child, ok := v.Properties[key]; ok {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// Ignore matches unknown values which are intentionally set.
+type Ignore []ignorePattern
+
+type ignorePattern struct {
+	release string
+	path    string
+}
+
+// ParseIgnore parses glob patterns in the format namespace/name:path, e.g. apps/*:podLabels.*.
+func ParseIgnore(patterns []string) (Ignore, error) {
+	var ignore Ignore
+	for _, pattern := range patterns {
+		release, valuesPath, ok := strings.Cut(pattern, ":")
+		if !ok || release == "" || valuesPath == "" {
+			return nil, fmt.Errorf("invalid unknown values ignore pattern %q, expected namespace/name:path", pattern)
+		}
+
+		for _, s := range []string{release, valuesPath} {
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, fmt.Errorf("invalid unknown values ignore pattern %q: %w", pattern, err)
+			}
+		}
+
+		ignore = append(ignore, ignorePattern{release: release, path: valuesPath})
+	}
+
+	return ignore, nil
+}
+
+// Match returns true if the values path of the HelmRelease namespace/name is ignored.
+func (i Ignore) Match(release, valuesPath string) bool {
+	for _, p := range i {
+		if ok, _ := path.Match(p.release, release); !ok {
+			continue
+		}
+
+		if ok, _ := path.Match(p.path, valuesPath); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,203 @@
+package values
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/yaml"
+)
+
+func parseValues(g *WithT, s string) map[string]interface{} {
+	values := map[string]interface{}{}
+	g.Expect(yaml.Unmarshal([]byte(s), &values)).To(Succeed())
+	return values
+}
+
+func testChart(g *WithT) *helmchart.Chart {
+	c := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{
+			Name: "app",
+			Dependencies: []*helmchart.Dependency{
+				{Name: "redis", Condition: "redis.enabled,cache.enabled", Alias: "cache"},
+				{Name: "common", Tags: []string{"backend"}},
+			},
+		},
+		Values: parseValues(g, `
+image:
+  repository: nginx
+  tag: ""
+podAnnotations: {}
+resources: {}
+replicas: 1
+global:
+  imageRegistry: ""
+`),
+		Schema: []byte(`{
+  "type": "object",
+  "properties": {
+    "ingress": {
+      "type": "object",
+      "properties": {
+        "enabled": {"type": "boolean"},
+        "hosts": {"$ref": "#/definitions/hosts"}
+      },
+      "additionalProperties": false
+    },
+    "labels": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    },
+    "extra": true
+  },
+  "definitions": {
+    "hosts": {"type": "array"}
+  }
+}`),
+	}
+
+	redis := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{Name: "redis"},
+		Values: parseValues(g, `
+auth:
+  password: ""
+global:
+  redis:
+    password: ""
+`),
+	}
+
+	common := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{Name: "common"},
+	}
+
+	c.AddDependency(redis, common)
+	return c
+}
+
+func TestUnknown(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  string
+		unknown []string
+	}{
+		{
+			name: "declared values",
+			values: `
+image:
+  tag: v1.0.0
+replicas: 3
+podAnnotations:
+  prometheus.io/scrape: "true"
+resources:
+  limits:
+    cpu: 100m
+`,
+		},
+		{
+			name: "typos",
+			values: `
+imgae:
+  tag: v1.0.0
+image:
+  tga: v1.0.0
+replicas: 3
+`,
+			unknown: []string{"image.tga", "imgae"},
+		},
+		{
+			name: "schema",
+			values: `
+ingress:
+  enabled: true
+  hosts: []
+  annotations:
+    a: b
+labels:
+  team: a
+extra:
+  anything: true
+`,
+			unknown: []string{"ingress.annotations"},
+		},
+		{
+			name: "subchart",
+			values: `
+redis:
+  enabled: true
+  auth:
+    password: secret
+    username: admin
+cache:
+  enabled: true
+  auth:
+    pasword: secret
+`,
+			unknown: []string{"cache.auth.pasword", "redis.auth.username"},
+		},
+		{
+			name: "globals",
+			values: `
+global:
+  imageRegistry: registry.example.com
+  redis:
+    password: secret
+  storageClass: fast
+tags:
+  backend: true
+`,
+			unknown: []string{"global.storageClass"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			unknown, err := Unknown(testChart(g), parseValues(g, test.values))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(unknown).To(Equal(test.unknown))
+		})
+	}
+}
+
+func TestUnknownInvalidSchema(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{Name: "app"},
+		Schema:   []byte(`{`),
+	}
+
+	_, err := Unknown(c, parseValues(g, `a: b`))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestIgnore(t *testing.T) {
+	g := NewWithT(t)
+
+	ignore, err := ParseIgnore([]string{"apps/*:podLabels.*", "*/*:global.*"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(ignore.Match("apps/web", "podLabels.team")).To(BeTrue())
+	g.Expect(ignore.Match("monitoring/grafana", "global.imageRegistry")).To(BeTrue())
+	g.Expect(ignore.Match("monitoring/grafana", "podLabels.team")).To(BeFalse())
+
+	_, err = ParseIgnore([]string{"apps/web"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestParseMode(t *testing.T) {
+	g := NewWithT(t)
+
+	mode, err := ParseMode("")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mode).To(Equal(ModeIgnore))
+
+	mode, err = ParseMode("fail")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mode).To(Equal(ModeFail))
+
+	_, err = ParseMode("error")
+	g.Expect(err).To(HaveOccurred())
+}
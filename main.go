@@ -3,14 +3,37 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/doodlescheduling/flux-build/internal/action"
+	"github.com/doodlescheduling/flux-build/internal/build"
+	memcache "github.com/doodlescheduling/flux-build/internal/cache"
+	"github.com/doodlescheduling/flux-build/internal/changes"
+	"github.com/doodlescheduling/flux-build/internal/charts"
+	"github.com/doodlescheduling/flux-build/internal/conflict"
+	"github.com/doodlescheduling/flux-build/internal/filter"
+	"github.com/doodlescheduling/flux-build/internal/git"
+	"github.com/doodlescheduling/flux-build/internal/github"
 	chartcache "github.com/doodlescheduling/flux-build/internal/helm/chart/cache"
+	"github.com/doodlescheduling/flux-build/internal/images"
+	"github.com/doodlescheduling/flux-build/internal/outdated"
+	"github.com/doodlescheduling/flux-build/internal/profile"
+	"github.com/doodlescheduling/flux-build/internal/redact"
+	"github.com/doodlescheduling/flux-build/internal/remote"
+	"github.com/doodlescheduling/flux-build/internal/rendercache"
+	"github.com/doodlescheduling/flux-build/internal/report"
+	"github.com/doodlescheduling/flux-build/internal/server"
+	"github.com/doodlescheduling/flux-build/internal/values"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"github.com/sethvargo/go-envconfig"
@@ -34,10 +57,83 @@ type Config struct {
 	CacheEnabled     bool     `env:"CACHE_ENABLED"`
 	CacheDir         string   `env:"CACHE_DIR"`
 	Cache            string   `env:"CACHE"`
+	DiffBase         string   `env:"DIFF_BASE"`
+	Redact           string   `env:"REDACT"`
+	RedactRules      []string `env:"REDACT_RULES"`
+	RedactKey        string   `env:"REDACT_KEY"`
+	Conflicts        string   `env:"CONFLICTS"`
+	AllowConflicts   []string `env:"ALLOW_CONFLICTS"`
+	Validate         bool     `env:"VALIDATE"`
+	Policies         bool     `env:"POLICIES"`
+	PolicyDir        string   `env:"POLICY_DIR"`
+	Filter           struct {
+		HelmReleases []string `env:"FILTER_HELMRELEASE"`
+		Namespaces   []string `env:"FILTER_NAMESPACE"`
+		Kinds        []string `env:"FILTER_KIND"`
+		ExcludeKinds []string `env:"FILTER_EXCLUDE_KIND"`
+		Selector     string   `env:"FILTER_SELECTOR"`
+	}
+	GithubAnnotations bool `env:"GITHUB_ANNOTATIONS"`
+	Report            struct {
+		JSON  string `env:"REPORT_JSON"`
+		JUnit string `env:"REPORT_JUNIT"`
+		SARIF string `env:"REPORT_SARIF"`
+	}
+	CheckDeprecations  bool   `env:"CHECK_DEPRECATIONS"`
+	UpgradeKubeVersion string `env:"UPGRADE_KUBE_VERSION"`
+	Images             struct {
+		JSON            string   `env:"IMAGES_JSON"`
+		CSV             string   `env:"IMAGES_CSV"`
+		DenyLatest      bool     `env:"IMAGES_DENY_LATEST"`
+		AllowRegistries []string `env:"IMAGES_ALLOW_REGISTRIES"`
+	}
+	ChartsJSON          string   `env:"CHARTS_JSON"`
+	OutdatedFailOn      string   `env:"OUTDATED_FAIL_ON"`
+	UnknownValues       string   `env:"UNKNOWN_VALUES"`
+	IgnoreUnknownValues []string `env:"IGNORE_UNKNOWN_VALUES"`
+	ValuesPlain         bool     `env:"VALUES_PLAIN"`
+	KubeVersions        []string `env:"KUBE_VERSIONS"`
+	KubeVersionsOutput  string   `env:"KUBE_VERSIONS_OUTPUT_DIR"`
+	ConfigFile          string   `env:"CONFIG"`
+	Profiles            []string `env:"PROFILE"`
+	AllProfiles         bool     `env:"ALL_PROFILES"`
+	Watch               bool     `env:"WATCH"`
+	ChangedSince        string   `env:"CHANGED_SINCE"`
+	ChangedFiles        []string `env:"CHANGED_FILES"`
+	RenderCache         string   `env:"RENDER_CACHE"`
+	SourceIgnore        []string `env:"SOURCE_IGNORE"`
+	Kustomize           struct {
+		LoadRestrictor string   `env:"KUSTOMIZE_LOAD_RESTRICTOR"`
+		EnableHelm     bool     `env:"KUSTOMIZE_ENABLE_HELM"`
+		ExecFunctions  []string `env:"KUSTOMIZE_EXEC_FUNCTIONS"`
+		RemoteMirrors  []string `env:"KUSTOMIZE_REMOTE_MIRRORS"`
+		VendorDir      string   `env:"KUSTOMIZE_VENDOR_DIR"`
+		Offline        bool     `env:"KUSTOMIZE_OFFLINE"`
+	}
+	Serve struct {
+		Listen        string        `env:"LISTEN"`
+		Root          string        `env:"SERVE_ROOT"`
+		MaxConcurrent int           `env:"MAX_CONCURRENT_BUILDS"`
+		Timeout       time.Duration `env:"REQUEST_TIMEOUT"`
+		MaxUploadSize int64         `env:"MAX_UPLOAD_SIZE"`
+	}
 }
 
 var (
 	config = &Config{}
+	// version is set during the release build.
+	version = "dev"
+)
+
+const (
+	// commandOutdated reports HelmReleases whose chart is behind the latest version instead of building the paths.
+	commandOutdated = "outdated"
+	// commandValues prints the merged values of a single HelmRelease instead of building the paths.
+	commandValues = "values"
+	// commandServe serves an HTTP API building the paths of each request.
+	commandServe = "serve"
+	// commandVendor fetches the remote bases of the kustomizations within the paths into the vendor directory.
+	commandVendor = "vendor"
 )
 
 func getDefaultCacheDir() string {
@@ -49,7 +145,42 @@ func getDefaultCacheDir() string {
 	return filepath.Join(homeDir, ".cache", "flux-build")
 }
 
+// buildVersion returns the version of the binary, development builds are identified by their vcs revision.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+
+	if revision == "" {
+		return version
+	}
+
+	if modified == "true" {
+		return fmt.Sprintf("%s-%s-dirty", version, revision)
+	}
+
+	return fmt.Sprintf("%s-%s", version, revision)
+}
+
 func init() {
+	flag.StringVar(&config.ConfigFile, "config", profile.DefaultFile, "Path to the project configuration file defining profiles")
+	flag.StringSliceVar(&config.Profiles, "profile", nil, "Build the named profiles of the configuration file (Comma separated)")
+	flag.BoolVar(&config.AllProfiles, "all-profiles", false, "Build all profiles of the configuration file")
 	flag.StringVarP(&config.Log.Level, "log-level", "l", "", "Define the log level (default is warning) [debug,info,warn,error]")
 	flag.StringVarP(&config.Log.Encoding, "log-encoding", "e", "", "Define the log format (default is json) [json,console]")
 	flag.StringVarP(&config.Output, "output", "o", "", "Path to output")
@@ -58,9 +189,56 @@ func init() {
 	flag.BoolVar(&config.FailFast, "fail-fast", false, "Exit early if an error occurred")
 	flag.IntVar(&config.Workers, "workers", runtime.NumCPU(), "Workers used to parse manifests")
 	flag.StringVarP(&config.KubeVersion, "kube-version", "", "", "Kubernetes version (Some helm charts validate manifests against a specific kubernetes version)")
+	flag.StringSliceVar(&config.KubeVersions, "kube-versions", nil, "Kubernetes versions to render all HelmReleases with, writes a markdown summary of releases which fail or render differently between the versions to the output (Comma separated)")
+	flag.StringVar(&config.KubeVersionsOutput, "kube-versions-output-dir", "", "Directory to write the build output of each version of --kube-versions to")
 	flag.StringSliceVarP(&config.APIVersions, "api-versions", "", nil, "Kubernetes api versions used for Capabilities.APIVersions (Comma separated)")
 	flag.StringVar(&config.Cache, "cache", "inmemory", "Which Helm cache to use, one of none, inmemory, fs")
 	flag.StringVar(&config.CacheDir, "cache-dir", getDefaultCacheDir(), "Path to helm chart cache (only used in combination with cache=fs)")
+	flag.StringVar(&config.Redact, "redact", "", "Redact v1.Secret values and fields matching --redact-rules in the output, one of none, placeholder, hash")
+	flag.StringVar(&config.RedactKey, "redact-key", "", "Key of the HMAC-SHA256 values are replaced with by --redact=hash, values are replaced with the placeholder if no key is set")
+	flag.StringSliceVar(&config.RedactRules, "redact-rules", nil, "Additional fields to redact in the format [group/]Kind:path, e.g. ConfigMap:.data.connectionString (Comma separated)")
+	flag.StringSliceVar(&config.Filter.HelmReleases, "helmrelease", nil, "Only render HelmReleases matching namespace/name glob patterns (Comma separated)")
+	flag.StringSliceVar(&config.Filter.Namespaces, "namespace", nil, "Only render HelmReleases and output objects within the namespaces (Comma separated)")
+	flag.StringSliceVar(&config.Filter.Kinds, "kind", nil, "Only output objects of the given kinds (Comma separated)")
+	flag.StringSliceVar(&config.Filter.ExcludeKinds, "exclude-kind", nil, "Do not output objects of the given kinds (Comma separated)")
+	flag.StringVar(&config.Filter.Selector, "selector", "", "Only output objects matching the label selector")
+	flag.StringVar(&config.Report.JSON, "report-json", "", "Path to write a JSON report of all errors to")
+	flag.StringVar(&config.Report.JUnit, "report-junit", "", "Path to write a JUnit XML report of all errors to")
+	flag.StringVar(&config.Report.SARIF, "report-sarif", "", "Path to write a SARIF report of all errors to")
+	flag.StringVar(&config.Conflicts, "conflicts", "", "How to report objects which are produced by multiple paths or HelmReleases, one of ignore, warn, fail")
+	flag.StringSliceVar(&config.AllowConflicts, "allow-conflicts", nil, "Objects which may be produced more than once in the format [group/]Kind:[namespace/]name, supports globs (Comma separated)")
+	flag.BoolVar(&config.Validate, "validate", false, "Validate all output objects against the built-in kubernetes schemas and CustomResourceDefinitions found in the build")
+	flag.BoolVar(&config.Policies, "policies", false, "Evaluate ValidatingAdmissionPolicies found in the build against all output objects")
+	flag.StringVar(&config.PolicyDir, "policy-dir", "", "Directory with additional ValidatingAdmissionPolicies, bindings and params to evaluate, implies --policies")
+	flag.BoolVar(&config.CheckDeprecations, "check-deprecations", false, "Report objects using deprecated or removed Kubernetes APIs at the configured kubernetes version")
+	flag.StringVar(&config.UpgradeKubeVersion, "upgrade-kube-version", "", "Planned Kubernetes upgrade version, objects using APIs removed until this version are reported, implies --check-deprecations")
+	flag.StringVar(&config.Images.JSON, "images-json", "", "Path to write a JSON inventory of all container images used by the output workloads to")
+	flag.StringVar(&config.Images.CSV, "images-csv", "", "Path to write a CSV inventory of all container images used by the output workloads to")
+	flag.StringVar(&config.ChartsJSON, "charts-json", "", "Path to write a JSON inventory of the charts of all HelmReleases including resolved versions, digests and dependencies to")
+	flag.BoolVar(&config.Images.DenyLatest, "images-deny-latest", false, "Report images using the latest tag or no tag at all as error")
+	flag.StringSliceVar(&config.Images.AllowRegistries, "images-allow-registries", nil, "Glob patterns of registries images may be pulled from, images from other registries are reported as error (e.g. ghcr.io,*.dkr.ecr.*.amazonaws.com)")
+	flag.StringVar(&config.UnknownValues, "unknown-values", "", "How to report HelmRelease values which are not declared in the chart values or schema, one of ignore, warn, fail")
+	flag.StringSliceVar(&config.IgnoreUnknownValues, "ignore-unknown-values", nil, "Unknown values to ignore in the format namespace/name:path, supports globs (Comma separated)")
+	flag.StringVar(&config.OutdatedFailOn, "outdated-fail-on", "", "Report outdated charts as error if at least a patch, minor or major update is available, otherwise as warning (only used by the outdated command)")
+	flag.BoolVar(&config.ValuesPlain, "values-plain", false, "Print the merged values without annotating the origin of each value (only used by the values command)")
+	flag.BoolVar(&config.GithubAnnotations, "github-annotations", false, "Emit GitHub Actions workflow annotations for errors and write a job step summary")
+	flag.BoolVar(&config.Watch, "watch", false, "Watch the paths for changes, rebuild the affected kustomize paths and HelmReleases and write the changed objects as diff to the output")
+	flag.StringVar(&config.ChangedSince, "changed-since", "", "Only render HelmReleases depending on files changed since the merge base of the git ref")
+	flag.StringSliceVar(&config.ChangedFiles, "changed-files", nil, "Only render HelmReleases depending on the changed files (Comma separated)")
+	flag.StringSliceVar(&config.SourceIgnore, "source-ignore", nil, "Additional patterns of files excluded from paths without a kustomization, like the spec.ignore of a GitRepository (Comma separated)")
+	flag.StringVar(&config.Kustomize.LoadRestrictor, "kustomize-load-restrictor", "none", "Restrict the files kustomizations may load, one of none, rootOnly (kustomize-controller does not restrict them)")
+	flag.BoolVar(&config.Kustomize.EnableHelm, "kustomize-enable-helm", false, "Inflate the helmCharts of kustomizations with the chart cache and renderer used for HelmReleases, no helm binary is required")
+	flag.StringSliceVar(&config.Kustomize.ExecFunctions, "kustomize-exec-functions", nil, "Enable the alpha exec KRM functions of kustomize whose executable matches one of the glob patterns, other plugins are not allowed (Comma separated)")
+	flag.StringSliceVar(&config.Kustomize.RemoteMirrors, "kustomize-remote-mirrors", nil, "Local directories of remote kustomize bases in the format url=dir, repositories are mapped including their ref, e.g. github.com/org/repo?ref=v1=./mirrors/repo (Comma separated)")
+	flag.StringVar(&config.Kustomize.VendorDir, "kustomize-vendor-dir", "", "Directory the vendor command fetches remote kustomize bases into, vendored remotes are used instead of fetching them")
+	flag.BoolVar(&config.Kustomize.Offline, "kustomize-offline", false, "Fail kustomizations referencing remotes which are neither mirrored nor vendored instead of fetching them")
+	flag.StringVar(&config.RenderCache, "render-cache", "none", "Cache the rendered output of HelmReleases within the cache directory across runs, one of none, readwrite, refresh, verify")
+	flag.StringVar(&config.Serve.Listen, "listen", ":8080", "Address to serve the HTTP API on (only used by the serve command)")
+	flag.StringVar(&config.Serve.Root, "serve-root", ".", "Directory request paths are resolved against, paths outside of it can not be built (only used by the serve command)")
+	flag.IntVar(&config.Serve.MaxConcurrent, "max-concurrent-builds", 2, "Number of requests built at the same time, further requests wait for a free slot (only used by the serve command)")
+	flag.DurationVar(&config.Serve.Timeout, "request-timeout", 5*time.Minute, "Maximum duration of a request (only used by the serve command)")
+	flag.Int64Var(&config.Serve.MaxUploadSize, "max-upload-size", 100<<20, "Maximum size in bytes of a request including uploaded archives, also limits the total size of the files extracted from an archive (only used by the serve command)")
+	flag.StringVar(&config.DiffBase, "diff-base", "", "Git ref to build and compare against, writes a markdown summary of the differences to the output")
 }
 
 func must(err error) {
@@ -84,19 +262,263 @@ func main() {
 	logger, err := buildLogger()
 	must(err)
 
-	kubeVersion := &chartutil.KubeVersion{
-		Major:   "1",
-		Minor:   "31",
-		Version: "1.31.0",
+	paths := flag.Args()
+	var command, release string
+	if len(paths) > 0 && (paths[0] == commandOutdated || paths[0] == commandValues || paths[0] == commandServe || paths[0] == commandVendor) {
+		command = paths[0]
+		paths = paths[1:]
 	}
 
-	paths := flag.Args()
-	if len(paths) == 0 {
-		if os.Getenv("PATHS") != "" {
-			paths = strings.Split(os.Getenv("PATHS"), ",")
-		} else {
+	if command == commandValues {
+		if len(paths) == 0 || !strings.Contains(paths[0], "/") {
+			must(errors.New("helmrelease namespace/name required"))
+		}
+
+		release = paths[0]
+		paths = paths[1:]
+	}
+
+	if command == commandVendor && config.Kustomize.VendorDir == "" {
+		must(errors.New("--kustomize-vendor-dir required"))
+	}
+
+	s := &shared{
+		caches:       make(map[string]chartcache.Interface),
+		renderCaches: make(map[string]*rendercache.Cache),
+		repositories: memcache.New[build.CacheKey](),
+	}
+
+	if command == commandServe {
+		must(serve(ctx, logger, s))
+		return
+	}
+
+	profiles, err := loadProfiles()
+	must(err)
+
+	if len(paths) == 0 && os.Getenv("PATHS") != "" {
+		paths = strings.Split(os.Getenv("PATHS"), ",")
+	}
+
+	if config.Images.JSON != "" || config.Images.CSV != "" {
+		s.images = &images.Inventory{}
+	}
+
+	if config.ChartsJSON != "" {
+		s.charts = &charts.Inventory{}
+	}
+
+	var rep *report.Report
+	if len(profiles) == 0 {
+		if len(paths) == 0 {
 			must(errors.New("path to kustomize overlay required"))
 		}
+
+		rep = run(ctx, logger, command, release, paths, nil, s)
+	} else {
+		rep = report.New()
+		base := *config
+		for _, p := range profiles {
+			*config = base
+			applyProfile(p)
+
+			profilePaths := paths
+			if len(p.Paths) > 0 {
+				profilePaths = p.Paths
+			}
+
+			if len(profilePaths) == 0 {
+				must(fmt.Errorf("path to kustomize overlay required for profile %s", p.Name))
+			}
+
+			logger.Info("build profile", "profile", p.Name)
+			profileReport := run(ctx, logger, command, release, profilePaths, p.Variables, s)
+			if len(profiles) > 1 {
+				for _, e := range profileReport.Errors {
+					e.Message = fmt.Sprintf("[profile %s] %s", p.Name, e.Message)
+				}
+			}
+
+			rep.Merge(profileReport)
+		}
+
+		*config = base
+		rep.Sort()
+	}
+
+	must(writeReports(rep))
+	must(writeImages(s.images))
+	must(writeCharts(s.charts))
+
+	if config.GithubAnnotations {
+		must(writeGithub(rep))
+	}
+
+	printErrors(rep)
+
+	if len(rep.Skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "%d HelmRelease(s) skipped, not affected by the changed files\n", len(rep.Skipped))
+	}
+
+	if rep.HasErrors() && !config.AllowFailure {
+		os.Exit(1)
+	}
+}
+
+// printErrors writes a summary table of all errors to stderr.
+func printErrors(rep *report.Report) {
+	if len(rep.Errors) > 0 {
+		fmt.Fprintf(os.Stderr, "%d error(s) occurred:\n", len(rep.Errors))
+		must(rep.WriteTable(os.Stderr))
+	}
+}
+
+// shared holds the state shared by the builds of all profiles.
+type shared struct {
+	caches       map[string]chartcache.Interface
+	renderCaches map[string]*rendercache.Cache
+	repositories *memcache.Cache[build.CacheKey]
+	images       *images.Inventory
+	charts       *charts.Inventory
+}
+
+// cache returns the chart cache of the given type and directory, creating it on first use.
+func (s *shared) cache(cacheType, cacheDir string) (chartcache.Interface, error) {
+	key := fmt.Sprintf("%s:%s", cacheType, cacheDir)
+	if c, ok := s.caches[key]; ok {
+		return c, nil
+	}
+
+	c, err := chartcache.New(cacheType, cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s.caches[key] = c
+	return c, nil
+}
+
+// renderCache returns the render cache within the cache directory, creating it on first use.
+// It returns nil if the render cache is disabled.
+func (s *shared) renderCache(mode, cacheDir string) (*rendercache.Cache, error) {
+	m, err := rendercache.ParseMode(mode)
+	if err != nil || m == rendercache.ModeNone {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s:%s", m, cacheDir)
+	if c, ok := s.renderCaches[key]; ok {
+		return c, nil
+	}
+
+	c, err := rendercache.New(filepath.Join(cacheDir, "rendered"), m, buildVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	s.renderCaches[key] = c
+	return c, nil
+}
+
+// loadProfiles returns the profiles selected by --profile or --all-profiles.
+func loadProfiles() ([]*profile.Profile, error) {
+	if len(config.Profiles) == 0 && !config.AllProfiles {
+		return nil, nil
+	}
+
+	c, err := profile.Load(config.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	names := config.Profiles
+	if config.AllProfiles {
+		names = c.Names()
+	}
+
+	var profiles []*profile.Profile
+	for _, name := range names {
+		p, err := c.Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		profiles = append(profiles, p)
+	}
+
+	return profiles, nil
+}
+
+// applyProfile overrides the config with all settings defined by the profile.
+func applyProfile(p *profile.Profile) {
+	if p.KubeVersion != "" {
+		config.KubeVersion = p.KubeVersion
+	}
+
+	if len(p.APIVersions) > 0 {
+		config.APIVersions = p.APIVersions
+	}
+
+	if p.Cache != "" {
+		config.Cache = p.Cache
+	}
+
+	if p.CacheDir != "" {
+		config.CacheDir = p.CacheDir
+	}
+
+	if p.Output != "" {
+		config.Output = p.Output
+	}
+
+	if p.SourceIgnore != "" {
+		config.SourceIgnore = strings.Split(p.SourceIgnore, "\n")
+	}
+
+	if len(p.Filter.HelmReleases) > 0 {
+		config.Filter.HelmReleases = p.Filter.HelmReleases
+	}
+
+	if len(p.Filter.Namespaces) > 0 {
+		config.Filter.Namespaces = p.Filter.Namespaces
+	}
+
+	if len(p.Filter.Kinds) > 0 {
+		config.Filter.Kinds = p.Filter.Kinds
+	}
+
+	if len(p.Filter.ExcludeKinds) > 0 {
+		config.Filter.ExcludeKinds = p.Filter.ExcludeKinds
+	}
+
+	if p.Filter.Selector != "" {
+		config.Filter.Selector = p.Filter.Selector
+	}
+}
+
+// serve serves the HTTP API until interrupted, requests are built with the current config unless overridden by the request.
+func serve(ctx context.Context, logger logr.Logger, s *shared) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(server.Options{
+		Defaults:      newAction(logger, nil, nil, s),
+		Root:          config.Serve.Root,
+		MaxConcurrent: config.Serve.MaxConcurrent,
+		Timeout:       config.Serve.Timeout,
+		MaxUploadSize: config.Serve.MaxUploadSize,
+		RedactKey:     []byte(config.RedactKey),
+	})
+
+	return srv.ListenAndServe(ctx, config.Serve.Listen)
+}
+
+// newAction creates the action building the paths with the current config, without output.
+func newAction(logger logr.Logger, paths []string, variables map[string]string, s *shared) action.Action {
+	kubeVersion := &chartutil.KubeVersion{
+		Major:   "1",
+		Minor:   "31",
+		Version: "1.31.0",
 	}
 
 	if config.KubeVersion != "" {
@@ -108,28 +530,334 @@ func main() {
 		kubeVersion = v
 	}
 
-	cache, err := chartcache.New(config.Cache, config.CacheDir)
+	var upgradeKubeVersion *chartutil.KubeVersion
+	if config.UpgradeKubeVersion != "" {
+		var err error
+		upgradeKubeVersion, err = chartutil.ParseKubeVersion(config.UpgradeKubeVersion)
+		must(err)
+	}
+
+	cache, err := s.cache(config.Cache, config.CacheDir)
 	if err != nil {
 		must(err)
 	}
 
+	renderCache, err := s.renderCache(config.RenderCache, config.CacheDir)
+	must(err)
+
+	redactor, err := buildRedactor()
+	must(err)
+
+	resourceFilter, err := buildFilter()
+	must(err)
+
+	imagePolicy, err := buildImagePolicy()
+	must(err)
+
+	conflictMode, err := conflict.ParseMode(config.Conflicts)
+	must(err)
+
+	allowConflicts, err := conflict.ParseAllowlist(config.AllowConflicts)
+	must(err)
+
+	unknownValues, err := values.ParseMode(config.UnknownValues)
+	must(err)
+
+	ignoreUnknownValues, err := values.ParseIgnore(config.IgnoreUnknownValues)
+	must(err)
+
+	loadRestrictor, err := build.ParseLoadRestrictor(config.Kustomize.LoadRestrictor)
+	must(err)
+
+	remotes, err := buildRemoteResolver()
+	must(err)
+
+	return action.Action{
+		FailFast:            config.FailFast,
+		Workers:             config.Workers,
+		APIVersions:         config.APIVersions,
+		Paths:               paths,
+		KubeVersion:         kubeVersion,
+		IncludeHelmHooks:    config.IncludeHelmHooks,
+		Logger:              logger,
+		Cache:               cache,
+		Redactor:            redactor,
+		Filter:              resourceFilter,
+		ConflictMode:        conflictMode,
+		AllowConflicts:      allowConflicts,
+		Validate:            config.Validate,
+		Policies:            config.Policies,
+		PolicyDir:           config.PolicyDir,
+		CheckDeprecations:   config.CheckDeprecations,
+		UpgradeKubeVersion:  upgradeKubeVersion,
+		Images:              s.images,
+		ImagePolicy:         imagePolicy,
+		Charts:              s.charts,
+		UnknownValues:       unknownValues,
+		IgnoreUnknownValues: ignoreUnknownValues,
+		Variables:           variables,
+		Repositories:        s.repositories,
+		RenderCache:         renderCache,
+		Kustomize: build.KustomizeOpts{
+			Ignore:         config.SourceIgnore,
+			LoadRestrictor: loadRestrictor,
+			ExecFunctions:  config.Kustomize.ExecFunctions,
+			Remotes:        remotes,
+		},
+		KustomizeHelm: config.Kustomize.EnableHelm,
+	}
+}
+
+// run builds the paths with the current config.
+func run(ctx context.Context, logger logr.Logger, command, release string, paths []string, variables map[string]string, s *shared) *report.Report {
+	must(os.MkdirAll(filepath.Dir(config.Output), 0755))
+
 	out, err := os.OpenFile(config.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0775)
 	must(err)
+	defer out.Close()
+
+	a := newAction(logger, paths, variables, s)
+	a.Output = out
+	a.Changes, err = buildChanges(ctx, paths)
+	must(err)
+
+	var rep *report.Report
+	switch {
+	case command == commandOutdated:
+		failOn, err := outdated.ParseUpdate(config.OutdatedFailOn)
+		must(err)
+
+		var result *outdated.Report
+		result, rep = a.RunOutdated(ctx, failOn)
+		must(result.WriteJSON(out))
+	case command == commandVendor:
+		var result *remote.Report
+		result, rep = a.RunVendor(ctx)
+		must(result.WriteJSON(out))
+	case command == commandValues:
+		var result *build.ReleaseValues
+		result, rep = a.RunValues(ctx, release)
+		if result != nil {
+			origins := result.Origins
+			if config.ValuesPlain {
+				origins = nil
+			}
+
+			must(values.Write(out, result.Values, origins))
+		}
+	case len(config.KubeVersions) > 0:
+		var kubeVersions []*chartutil.KubeVersion
+		for _, version := range config.KubeVersions {
+			v, err := chartutil.ParseKubeVersion(version)
+			must(err)
+			kubeVersions = append(kubeVersions, v)
+		}
+
+		rep, err = a.RunMatrix(ctx, kubeVersions, config.KubeVersionsOutput)
+		must(err)
+	case config.Watch:
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Errors are printed after each rebuild
+		must(a.RunWatch(ctx, printErrors))
+		rep = report.New()
+	case config.DiffBase != "":
+		rep, err = a.RunDiff(ctx, config.DiffBase)
+		must(err)
+	default:
+		rep = a.Run(ctx)
+	}
+
+	return rep
+}
+
+func writeGithub(rep *report.Report) error {
+	if err := github.WriteAnnotations(os.Stderr, rep); err != nil {
+		return err
+	}
+
+	summary := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summary == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(summary, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := github.WriteStepSummary(f, rep); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeReports(rep *report.Report) error {
+	reports := []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{config.Report.JSON, rep.WriteJSON},
+		{config.Report.JUnit, rep.WriteJUnit},
+		{config.Report.SARIF, rep.WriteSARIF},
+	}
+
+	for _, r := range reports {
+		if err := writeFile(r.path, r.write); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeImages(inventory *images.Inventory) error {
+	if inventory == nil {
+		return nil
+	}
+
+	inventory.Sort()
+	if err := writeFile(config.Images.JSON, inventory.WriteJSON); err != nil {
+		return err
+	}
+
+	return writeFile(config.Images.CSV, inventory.WriteCSV)
+}
+
+func writeCharts(inventory *charts.Inventory) error {
+	if inventory == nil {
+		return nil
+	}
+
+	inventory.Sort()
+	return writeFile(config.ChartsJSON, inventory.WriteJSON)
+}
+
+// writeFile creates the file at path and writes to it. Nothing is written if path is empty.
+func writeFile(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// buildChanges returns the changed files of --changed-files and --changed-since, nil if neither is set.
+func buildChanges(ctx context.Context, paths []string) (*changes.Set, error) {
+	if config.ChangedSince == "" && len(config.ChangedFiles) == 0 {
+		return nil, nil
+	}
+
+	if config.DiffBase != "" || config.Watch {
+		return nil, errors.New("--changed-since and --changed-files can not be combined with --diff-base or --watch")
+	}
+
+	files := config.ChangedFiles
+	if config.ChangedSince != "" && len(paths) > 0 {
+		dir := paths[0]
+		if stat, err := os.Stat(dir); err == nil && !stat.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+
+		changed, err := git.ChangedFiles(ctx, dir, config.ChangedSince)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(append([]string{}, files...), changed...)
+	}
+
+	return changes.New(files), nil
+}
+
+func buildRedactor() (*redact.Redactor, error) {
+	mode, err := redact.ParseMode(config.Redact)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := redact.ParseRules(config.RedactRules)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == redact.ModeNone {
+		if len(rules) > 0 {
+			mode = redact.ModePlaceholder
+		} else {
+			return nil, nil
+		}
+	}
+
+	return redact.New(mode, rules, []byte(config.RedactKey)), nil
+}
+
+func buildImagePolicy() (*images.Policy, error) {
+	if !config.Images.DenyLatest && len(config.Images.AllowRegistries) == 0 {
+		return nil, nil
+	}
+
+	p := &images.Policy{
+		DenyLatest:      config.Images.DenyLatest,
+		AllowRegistries: config.Images.AllowRegistries,
+	}
+
+	if err := p.ValidateRegistries(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// buildRemoteResolver returns the resolver of remote kustomize bases, nil if remotes are neither mirrored, vendored nor offline.
+func buildRemoteResolver() (*remote.Resolver, error) {
+	if len(config.Kustomize.RemoteMirrors) == 0 && config.Kustomize.VendorDir == "" && !config.Kustomize.Offline {
+		return nil, nil
+	}
+
+	mirrors, err := remote.ParseMirrors(config.Kustomize.RemoteMirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	vendorDir := config.Kustomize.VendorDir
+	if vendorDir != "" {
+		if vendorDir, err = filepath.Abs(vendorDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &remote.Resolver{
+		Mirrors:   mirrors,
+		VendorDir: vendorDir,
+		Offline:   config.Kustomize.Offline,
+	}, nil
+}
+
+func buildFilter() (*filter.Filter, error) {
+	f, err := filter.New(config.Filter.HelmReleases, config.Filter.Namespaces, config.Filter.Kinds, config.Filter.ExcludeKinds, config.Filter.Selector)
+	if err != nil {
+		return nil, err
+	}
 
-	a := action.Action{
-		AllowFailure:     config.AllowFailure,
-		FailFast:         config.FailFast,
-		Workers:          config.Workers,
-		APIVersions:      config.APIVersions,
-		Paths:            paths,
-		KubeVersion:      kubeVersion,
-		Output:           out,
-		IncludeHelmHooks: config.IncludeHelmHooks,
-		Logger:           logger,
-		Cache:            cache,
+	if f.Empty() {
+		return nil, nil
 	}
 
-	must(a.Run(ctx))
+	return f, nil
 }
 
 func buildLogger() (logr.Logger, error) {